@@ -0,0 +1,242 @@
+package godid
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"sort"
+	"time"
+)
+
+// ArchiveResult reports what Archive and ApplyRetention changed
+type ArchiveResult struct {
+	// Archived is the number of entries moved to the archive store
+	Archived int
+	// Deleted is the number of entries deleted for good by the retention policies
+	Deleted int
+}
+
+// bucketEntry is an entry along with its parent bucket
+type bucketEntry struct {
+	parentBucketName string
+	entry
+}
+
+// collectEntries returns the entries of s for which match is true. They are read in full before anything gets moved,
+// the stores can't be written while being iterated.
+func collectEntries(s entryStore, match func(parentBucketName string, e entry) bool) ([]bucketEntry, error) {
+	result := make([]bucketEntry, 0)
+	err := s.ForEach(func(parentBucketName string, e entry) error {
+		if match(parentBucketName, e) {
+			result = append(result, bucketEntry{parentBucketName: parentBucketName, entry: e})
+		}
+		return nil
+	})
+	return result, err
+}
+
+// archiveEntries moves the entries from src to dst, keeping their ids and history. Each entry is copied before being
+// deleted, the entries already in dst, as left by an interrupted run, are only deleted.
+func archiveEntries(dst, src entryStore, entries []bucketEntry) (int, error) {
+	if len(entries) == 0 {
+		return 0, nil
+	}
+	archived := make(map[syncKey][]entry)
+	err := dst.ForEach(func(parentBucketName string, e entry) error {
+		k := newSyncKey(parentBucketName, e)
+		archived[k] = append(archived[k], e)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, e := range entries {
+		k := newSyncKey(e.parentBucketName, e.entry)
+		id := e.ID
+		// the entries archived before the ids were kept are found by their content
+		i := indexOfID(archived[k], e.ID)
+		if i < 0 {
+			i = indexOfSameEntry(archived[k], e.entry)
+		}
+		if i >= 0 {
+			id = archived[k][i].ID
+			archived[k] = append(archived[k][:i], archived[k][i+1:]...)
+		} else if id, err = dst.Put(e.parentBucketName, e.entry); err != nil {
+			return count, err
+		}
+		if err := moveHistory(dst, src, e.parentBucketName, e.ID, id); err != nil {
+			return count, err
+		}
+		if err := src.Delete(e.parentBucketName, e.ID); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// moveHistory copies the history of an entry of src to dst, where it has the id to
+func moveHistory(dst, src entryStore, parentBucketName, from, to string) error {
+	h, ok := src.(historian)
+	if !ok {
+		return nil
+	}
+	importer, ok := dst.(historyImporter)
+	if !ok {
+		return nil
+	}
+	revisions, err := h.History(parentBucketName, from)
+	if err != nil || len(revisions) == 0 {
+		return err
+	}
+	return importer.SetHistory(parentBucketName, to, revisions)
+}
+
+// deleteEntries deletes the entries from s for good, they don't go to the trash
+func deleteEntries(s entryStore, entries []bucketEntry) (int, error) {
+	for i, e := range entries {
+		if err := s.Delete(e.parentBucketName, e.ID); err != nil {
+			return i, err
+		}
+	}
+	return len(entries), nil
+}
+
+// archiveConfigFor returns the config of the archive store of cfg, which uses the same backend
+func archiveConfigFor(cfg config) (config, error) {
+	path, err := cfg.GetArchivePath()
+	if err != nil {
+		return config{}, err
+	}
+	if cfg.Backend == "" {
+		cfg.Backend = backendBolt
+	}
+	cfg.StorePath = path
+	return cfg, nil
+}
+
+// openArchive returns the archive store, opening it on first use. Without writable, nil is returned when there's no
+// archive yet rather than creating one.
+func openArchive(writable bool) (entryStore, error) {
+	if archive != nil && (!writable || !archiveReadOnly) {
+		return archive, nil
+	}
+	if archiveConfig == nil {
+		if !writable {
+			return nil, nil
+		}
+		return nil, didErrorf("the archive store isn't configured")
+	}
+	if !writable {
+		if _, err := os.Stat(archiveConfig.StorePath); errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+	}
+	if archive != nil {
+		archive.Close()
+		archive = nil
+	}
+	s, err := newStore(*archiveConfig, Options{Backend: archiveConfig.Backend, ReadOnly: !writable})
+	if err != nil {
+		return nil, err
+	}
+	archive, archiveReadOnly = s, !writable
+	return archive, nil
+}
+
+// archiveCutoff is kept in a file next to the archive, so the queries of the days after the newest archived entry can
+// leave the archive alone
+type archiveCutoff struct {
+	Newest time.Time `json:"newest"`
+}
+
+func archiveCutoffPath(archivePath string) string {
+	return archivePath + ".cutoff"
+}
+
+// readArchiveCutoff returns the time of the newest archived entry, ok being false when it isn't known
+func readArchiveCutoff(archivePath string) (newest time.Time, ok bool, err error) {
+	content, err := os.ReadFile(archiveCutoffPath(archivePath))
+	if errors.Is(err, os.ErrNotExist) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	var cutoff archiveCutoff
+	if err := json.Unmarshal(content, &cutoff); err != nil {
+		return time.Time{}, false, nil
+	}
+	return cutoff.Newest, true, nil
+}
+
+func writeArchiveCutoff(archivePath string, newest time.Time) error {
+	content, err := json.Marshal(archiveCutoff{Newest: newest})
+	if err != nil {
+		return err
+	}
+	return replaceFile(archiveCutoffPath(archivePath), func(tmp string) error {
+		return os.WriteFile(tmp, content, 0600)
+	})
+}
+
+// raiseArchiveCutoff records the entries about to be archived. It's called before they're moved, so an interrupted
+// run can't leave entries in the archive newer than the recorded ones.
+func raiseArchiveCutoff(entries []bucketEntry) error {
+	if archiveConfig == nil || len(entries) == 0 {
+		return nil
+	}
+	newest, _, err := readArchiveCutoff(archiveConfig.StorePath)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.Timestamp.After(newest) {
+			newest = e.Timestamp
+		}
+	}
+	return writeArchiveCutoff(archiveConfig.StorePath, newest)
+}
+
+// openArchiveFrom returns the archive store when it may hold entries logged from the day of start on, nil otherwise.
+// The cutoff of the archives written before it was recorded is found out on first use.
+func openArchiveFrom(start time.Time) (entryStore, error) {
+	if archiveConfig == nil {
+		return openArchive(false)
+	}
+	newest, ok, err := readArchiveCutoff(archiveConfig.StorePath)
+	if err != nil {
+		return nil, err
+	}
+	// the stores read whole days, so the archive is needed from the start of the day of the newest archived entry
+	if ok && dayIn(start, location).After(newest) {
+		return nil, nil
+	}
+	arch, err := openArchive(false)
+	if err != nil || arch == nil || ok {
+		return arch, err
+	}
+	err = arch.ForEach(func(_ string, e entry) error {
+		if e.Timestamp.After(newest) {
+			newest = e.Timestamp
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return arch, writeArchiveCutoff(archiveConfig.StorePath, newest)
+}
+
+// sortEntries sorts entries merged from several stores by time
+func sortEntries(entries []entry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Timestamp.Before(entries[j].Timestamp)
+	})
+}
+
+// retentionCutoff returns the start of the oldest day kept by a policy keeping entries for keep
+func retentionCutoff(reference time.Time, keep calendarDuration) time.Time {
+	return startOfDay(keep.before(reference))
+}
@@ -0,0 +1,276 @@
+package godid
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+const (
+	backupPrefix     = "did-"
+	backupTimeFormat = "20060102T150405"
+)
+
+// backuper is implemented by the stores able to snapshot themselves while in use
+type backuper interface {
+	// Backup writes a consistent snapshot of the store to path
+	Backup(path string) error
+	// Restore checks the snapshot at path and replaces the store with it
+	Restore(path string) error
+}
+
+func (s *boltStore) Backup(path string) error {
+	return replaceFile(path, func(tmp string) error {
+		f, err := os.OpenFile(tmp, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		err = s.db.View(func(tx *bolt.Tx) error {
+			_, err := tx.WriteTo(f)
+			return err
+		})
+		if err == nil {
+			err = f.Sync()
+		}
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	})
+}
+
+func (s *boltStore) Restore(path string) error {
+	storePath := s.db.Path()
+	if err := checkSnapshotPath(path, storePath); err != nil {
+		return err
+	}
+	if err := checkBoltSnapshot(path, s.loc); err != nil {
+		return err
+	}
+	restored, err := tempPath(storePath)
+	if err != nil {
+		return err
+	}
+	if err := copyFile(path, restored); err != nil {
+		os.Remove(restored)
+		return err
+	}
+	// the store is kept aside until the snapshot is opened in its place, so it can be put back on failure
+	original, err := tempPath(storePath)
+	if err != nil {
+		os.Remove(restored)
+		return err
+	}
+	if err := s.db.Close(); err != nil {
+		os.Remove(restored)
+		return err
+	}
+	if err := os.Rename(storePath, original); err != nil {
+		os.Remove(restored)
+		return s.reopen(storePath, err)
+	}
+	if err := os.Rename(restored, storePath); err != nil {
+		os.Remove(restored)
+		return s.putBack(original, storePath, err)
+	}
+	db, err := bolt.Open(storePath, 0600, s.opts)
+	if err != nil {
+		return s.putBack(original, storePath, err)
+	}
+	s.db = db
+	os.Remove(original)
+	return nil
+}
+
+// putBack moves the original store back in place after a failed restore and reopens it
+func (s *boltStore) putBack(original, storePath string, restoreErr error) error {
+	if err := os.Rename(original, storePath); err != nil {
+		return didErrorf("failed to restore the store: %v, the original store was left at %s: %v", restoreErr, original,
+			err)
+	}
+	return s.reopen(storePath, restoreErr)
+}
+
+// reopen opens the store again after a failed restore, returning restoreErr. The store can't be used anymore when
+// it can't be reopened, the error says so.
+func (s *boltStore) reopen(storePath string, restoreErr error) error {
+	db, err := bolt.Open(storePath, 0600, s.opts)
+	if err != nil {
+		return didErrorf("failed to restore the store: %v, and to reopen it: %v, run did again to reopen it",
+			restoreErr, err)
+	}
+	s.db = db
+	return restoreErr
+}
+
+// checkBoltSnapshot makes sure the bolt file at path is consistent, has a supported schema and holds valid entries
+func checkBoltSnapshot(path string, loc *time.Location) error {
+	db, err := bolt.Open(path, 0600, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		return invalidSnapshotError(path, err)
+	}
+	defer db.Close()
+	return db.View(func(tx *bolt.Tx) error {
+		var checkErr error
+		// the channel has to be drained for the check to finish
+		for err := range tx.Check() {
+			if checkErr == nil {
+				checkErr = err
+			}
+		}
+		if checkErr != nil {
+			return invalidSnapshotError(path, checkErr)
+		}
+		if _, err := getSchemaVersion(tx); err != nil {
+			return invalidSnapshotError(path, err)
+		}
+		err := forEachDayBucket(tx, func(_ []byte, b *bolt.Bucket) error {
+			return b.ForEach(func(k, v []byte) error {
+				_, err := decodeEntry(k, v, loc)
+				return err
+			})
+		})
+		if err != nil {
+			return invalidSnapshotError(path, err)
+		}
+		return nil
+	})
+}
+
+func checkSnapshotPath(path, storePath string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return invalidSnapshotError(path, err)
+	}
+	storeInfo, err := os.Stat(storePath)
+	if err == nil && os.SameFile(info, storeInfo) {
+		return didErrorf("%s is the store itself", path)
+	}
+	return nil
+}
+
+func invalidSnapshotError(path string, err error) DidError {
+	return didErrorf("invalid snapshot %s: %v", path, err)
+}
+
+// replaceFile writes path through a temporary file next to it, renamed over path once write succeeds, so path is
+// never left half written
+func replaceFile(path string, write func(tmp string) error) error {
+	tmp, err := tempPath(path)
+	if err != nil {
+		return err
+	}
+	if err := write(tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// tempPath returns an unused path next to path. The file isn't created, some writers refuse existing files.
+func tempPath(path string) (string, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	tmp := f.Name()
+	f.Close()
+	if err := os.Remove(tmp); err != nil {
+		return "", err
+	}
+	return tmp, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if err == nil {
+		err = out.Sync()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// rotateBackups takes a backup into the configured directory when the newest one is older than the configured
+// interval, then removes the oldest ones beyond the number to keep. ext is the extension of the backup files.
+func rotateBackups(cfg backupConfig, b backuper, ext string, now time.Time) error {
+	dir, err := cfg.GetDir()
+	if err != nil {
+		return err
+	}
+	every, err := cfg.GetEvery()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	backups, err := listBackups(dir, ext)
+	if err != nil {
+		return err
+	}
+	if len(backups) == 0 || !now.Before(backups[len(backups)-1].takenAt.Add(every)) {
+		path := filepath.Join(dir, backupPrefix+now.UTC().Format(backupTimeFormat)+ext)
+		if err := b.Backup(path); err != nil {
+			return err
+		}
+		backups = append(backups, backupFile{path: path, takenAt: now})
+	}
+	if cfg.Keep <= 0 || len(backups) <= cfg.Keep {
+		return nil
+	}
+	for _, old := range backups[:len(backups)-cfg.Keep] {
+		if err := os.Remove(old.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type backupFile struct {
+	path    string
+	takenAt time.Time
+}
+
+// listBackups returns the automatic backups in dir, oldest first
+func listBackups(dir, ext string) ([]backupFile, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]backupFile, 0)
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		takenAt, err := time.Parse(backupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, backupPrefix), ext))
+		if err != nil {
+			continue
+		}
+		result = append(result, backupFile{path: filepath.Join(dir, name), takenAt: takenAt})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].takenAt.Before(result[j].takenAt)
+	})
+	return result, nil
+}
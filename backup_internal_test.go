@@ -0,0 +1,83 @@
+package godid
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fileBackuper struct {
+	backups int
+}
+
+func (b *fileBackuper) Backup(path string) error {
+	b.backups++
+	return os.WriteFile(path, []byte("snapshot"), 0600)
+}
+
+func (b *fileBackuper) Restore(string) error {
+	return nil
+}
+
+func TestRotateBackups(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("keep me"), 0600))
+	cfg := backupConfig{Dir: dir, Keep: 2}
+	b := &fileBackuper{}
+	start := timeFromString(t, "2018-07-18T12:11:00Z")
+
+	testCases := []struct {
+		now             time.Time
+		expectedBackups int
+		expectedFiles   []string
+	}{
+		{
+			now:             start,
+			expectedBackups: 1,
+			expectedFiles:   []string{"did-20180718T121100.db", "notes.txt"},
+		},
+		{
+			now:             start.Add(23 * time.Hour),
+			expectedBackups: 1,
+			expectedFiles:   []string{"did-20180718T121100.db", "notes.txt"},
+		},
+		{
+			now:             start.Add(24 * time.Hour),
+			expectedBackups: 2,
+			expectedFiles:   []string{"did-20180718T121100.db", "did-20180719T121100.db", "notes.txt"},
+		},
+		{
+			now:             start.Add(72 * time.Hour),
+			expectedBackups: 3,
+			expectedFiles:   []string{"did-20180719T121100.db", "did-20180721T121100.db", "notes.txt"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.now.String(), func(t *testing.T) {
+			require.NoError(t, rotateBackups(cfg, b, ".db", tc.now))
+			assert.Equal(t, tc.expectedBackups, b.backups)
+			files, err := os.ReadDir(dir)
+			require.NoError(t, err)
+			names := make([]string, 0, len(files))
+			for _, f := range files {
+				names = append(names, f.Name())
+			}
+			assert.Equal(t, tc.expectedFiles, names)
+		})
+	}
+}
+
+func TestBackupConfigEvery(t *testing.T) {
+	_, err := backupConfig{Every: "daily"}.GetEvery()
+	assert.IsType(t, DidError{}, err)
+	every, err := backupConfig{Every: "12h"}.GetEvery()
+	require.NoError(t, err)
+	assert.Equal(t, 12*time.Hour, every)
+	every, err = backupConfig{}.GetEvery()
+	require.NoError(t, err)
+	assert.Equal(t, 24*time.Hour, every)
+}
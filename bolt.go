@@ -1,42 +1,80 @@
 package godid
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
+	"os"
+	"sort"
 	"time"
 
 	"github.com/boltdb/bolt"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	dayFormat = "2006-01-02"
 )
 
 type boltStore struct {
 	db *bolt.DB
+	// opts are the options the db was opened with
+	opts *bolt.Options
+	// loc is the home location, deciding the day buckets
+	loc *time.Location
 }
 
-const (
-	timeFormat = time.RFC3339
-)
-
-// newBoltStore creates new entryStore with boltdb as a backend
-func newBoltStore(cfg config) (*boltStore, error) {
+// newBoltStore creates new entryStore with boltdb as a backend. A read only store shares the file lock with the other
+// readers, a writable one holds it alone.
+func newBoltStore(cfg config, readOnly bool) (*boltStore, error) {
 	path, err := cfg.GetStorePath()
 	if err != nil {
 		return nil, err
 	}
-	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 10 * time.Second})
+	loc, err := cfg.GetLocation()
 	if err != nil {
 		return nil, err
 	}
+	timeout, err := cfg.GetLockTimeout()
+	if err != nil {
+		return nil, err
+	}
+	if readOnly {
+		// bolt would create the missing file, then fail to initialise it
+		if _, err := os.Stat(path); err != nil {
+			return nil, err
+		}
+	}
+	opts := &bolt.Options{Timeout: timeout, ReadOnly: readOnly}
+	db, err := bolt.Open(path, 0600, opts)
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, lockedError(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !readOnly {
+		// best effort, it only makes the error of the processes waiting for the store clearer
+		writeLockInfo(path)
+	}
 	return &boltStore{
-		db: db,
+		db:   db,
+		opts: opts,
+		loc:  loc,
 	}, nil
 }
 
-func (s *boltStore) Put(parentBucketName string, e entry) error {
-	bucketName, err := getBucketFromEntry(e)
+func (s *boltStore) Put(parentBucketName string, e entry) (string, error) {
+	if err := checkBucketPath(parentBucketName); err != nil {
+		return "", err
+	}
+	bucketName, err := getBucketFromEntry(e, s.loc)
 	if err != nil {
-		return err
+		return "", err
 	}
-	return s.db.Update(func(tx *bolt.Tx) error {
-		parentBucket, err := tx.CreateBucketIfNotExists([]byte(parentBucketName))
+	var key []byte
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		parentBucket, err := createParentBucket(tx, parentBucketName)
 		if err != nil {
 			return err
 		}
@@ -44,45 +82,140 @@ func (s *boltStore) Put(parentBucketName string, e entry) error {
 		if err != nil {
 			return err
 		}
-		return b.Put([]byte(e.Timestamp.Format(timeFormat)), e.Content)
+		key = keptKey(e)
+		if key == nil || b.Get(key) != nil {
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			key = encodeKey(e.Timestamp, seq)
+		} else if err := b.SetSequence(max(b.Sequence(), keySequence(key))); err != nil {
+			return err
+		}
+		v, err := encodeValue(e)
+		if err != nil {
+			return err
+		}
+		if err := b.Put(key, v); err != nil {
+			return err
+		}
+		return indexEntry(tx, parentBucketName, key, e)
 	})
+	if err != nil {
+		return "", err
+	}
+	return formatID(key), nil
 }
 
 func (s *boltStore) GetRange(parentBucketName string, start, end time.Time) ([]entry, error) {
-	buckets, err := getBucketRange(start, end)
+	r, err := newDayRange(start, end)
 	if err != nil {
 		return nil, err
 	}
+	first, last := r.bucketBounds(s.loc)
 	result := make([]entry, 0)
-	for _, bucket := range buckets {
-		err := s.db.View(func(tx *bolt.Tx) error {
-			parentBucket := tx.Bucket([]byte(parentBucketName))
-			if parentBucket == nil {
-				return nil
+	err = s.db.View(func(tx *bolt.Tx) error {
+		parentBucket := getParentBucket(tx, parentBucketName)
+		if parentBucket == nil {
+			return nil
+		}
+		// day bucket names sort chronologically, after the children, so only the existing buckets in range are visited
+		c := parentBucket.Cursor()
+		for k, v := c.Seek([]byte(first)); k != nil && bytes.Compare(k, []byte(last)) <= 0; k, v = c.Next() {
+			if v != nil {
+				continue
 			}
-			b := parentBucket.Bucket([]byte(bucket))
-			if b == nil {
-				return nil
+			bucketEntries, err := s.getBucketEntries(parentBucket.Bucket(k))
+			if err != nil {
+				return err
 			}
-			return b.ForEach(func(k, v []byte) error {
-				timestamp, err := time.Parse(timeFormat, string(k))
-				if err != nil {
-					return err
+			for _, e := range bucketEntries {
+				if r.contains(e.Timestamp) {
+					result = append(result, e)
 				}
-				result = append(result, entry{
-					Timestamp: timestamp,
-					Content:   v,
-				})
-				return nil
-			})
-		})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *boltStore) Get(parentBucketName string, id string) (entry, error) {
+	key, err := parseID(id)
+	if err != nil {
+		return entry{}, err
+	}
+	var result entry
+	err = s.db.View(func(tx *bolt.Tx) error {
+		b, err := s.findEntryBucket(tx, parentBucketName, key)
 		if err != nil {
-			return nil, err
+			return err
 		}
+		result, err = decodeEntry(key, b.Get(key), s.loc)
+		return err
+	})
+	if err != nil {
+		return entry{}, err
 	}
 	return result, nil
 }
 
+func (s *boltStore) Update(parentBucketName string, e entry) error {
+	key, err := parseID(e.ID)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := s.findEntryBucket(tx, parentBucketName, key)
+		if err != nil {
+			return err
+		}
+		old, err := decodeEntry(key, b.Get(key), s.loc)
+		if err != nil {
+			return err
+		}
+		if err := addRevision(tx, parentBucketName, key, b.Get(key), time.Now()); err != nil {
+			return err
+		}
+		e.Timestamp = old.Timestamp
+		v, err := encodeValue(e)
+		if err != nil {
+			return err
+		}
+		if err := b.Put(key, v); err != nil {
+			return err
+		}
+		if err := unindexEntry(tx, parentBucketName, key, old); err != nil {
+			return err
+		}
+		return indexEntry(tx, parentBucketName, key, e)
+	})
+}
+
+func (s *boltStore) Delete(parentBucketName string, id string) error {
+	key, err := parseID(id)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := s.findEntryBucket(tx, parentBucketName, key)
+		if err != nil {
+			return err
+		}
+		old, err := decodeEntry(key, b.Get(key), s.loc)
+		if err != nil {
+			return err
+		}
+		if err := b.Delete(key); err != nil {
+			return err
+		}
+		return unindexEntry(tx, parentBucketName, key, old)
+	})
+}
+
 func (s *boltStore) GetRangeWithAggregation(parentBucketName string, start, end time.Time, agg aggregationFunction) (any, error) {
 	if agg == nil {
 		return nil, errors.New("aggregation function is nil")
@@ -94,45 +227,172 @@ func (s *boltStore) GetRangeWithAggregation(parentBucketName string, start, end
 	return agg(entries)
 }
 
+func (s *boltStore) ForEach(fn func(parentBucketName string, e entry) error) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		return forEachDayBucket(tx, func(parentBucketName []byte, b *bolt.Bucket) error {
+			bucketEntries, err := s.getBucketEntries(b)
+			if err != nil {
+				return err
+			}
+			for _, e := range bucketEntries {
+				if err := fn(string(parentBucketName), e); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	})
+}
+
 func (s *boltStore) Close() error {
+	if !s.db.IsReadOnly() {
+		removeLockInfo(s.db.Path())
+	}
 	return s.db.Close()
 }
 
-func getBucketFromEntry(e entry) (string, error) {
-	return getBucketFromTime(e.Timestamp)
+// getBucketEntries returns all the entries of a day bucket sorted by time. The entries that can't be read are left
+// out, did fsck reporting them.
+func (s *boltStore) getBucketEntries(b *bolt.Bucket) ([]entry, error) {
+	result := make([]entry, 0)
+	err := b.ForEach(func(k, v []byte) error {
+		if v == nil {
+			return nil
+		}
+		e, err := decodeEntry(k, v, s.loc)
+		if err != nil {
+			getLogger().WithFields(logrus.Fields{
+				"component": "bolt",
+				"method":    "getBucketEntries",
+				"key":       fmt.Sprintf("%q", k),
+			}).WithError(err).Warn("skipped unreadable entry, run did fsck")
+			return nil
+		}
+		result = append(result, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// legacy keys don't share the ordering of the new ones
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Timestamp.Before(result[j].Timestamp)
+	})
+	return result, nil
+}
+
+// findEntryBucket returns the day bucket holding key. The bucket is derived from the key's timestamp, falling back
+// to searching all the day buckets of the parent for entries that were filed differently.
+func (s *boltStore) findEntryBucket(tx *bolt.Tx, parentBucketName string, key []byte) (*bolt.Bucket, error) {
+	notFound := entryNotFoundError(parentBucketName, formatID(key))
+	parentBucket := getParentBucket(tx, parentBucketName)
+	if parentBucket == nil {
+		return nil, notFound
+	}
+	timestamp, err := decodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	bucketName, err := getBucketFromTime(timestamp.In(s.loc))
+	if err != nil {
+		return nil, err
+	}
+	if b := parentBucket.Bucket([]byte(bucketName)); b != nil && b.Get(key) != nil {
+		return b, nil
+	}
+	var result *bolt.Bucket
+	c := parentBucket.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		if v != nil || isChildBucketKey(k) {
+			continue
+		}
+		if b := parentBucket.Bucket(k); b.Get(key) != nil {
+			result = b
+			break
+		}
+	}
+	if result == nil {
+		return nil, notFound
+	}
+	return result, nil
+}
+
+// forEachDayBucket calls fn for every day bucket of every parent bucket
+func forEachDayBucket(tx *bolt.Tx, fn func(parentBucketName []byte, b *bolt.Bucket) error) error {
+	return forEachParentBucket(tx, func(name string, parentBucket *bolt.Bucket) error {
+		parentBucketName := []byte(name)
+		dayBuckets := make([][]byte, 0)
+		err := parentBucket.ForEach(func(k, v []byte) error {
+			if v == nil && !isChildBucketKey(k) {
+				dayBuckets = append(dayBuckets, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, name := range dayBuckets {
+			if err := fn(parentBucketName, parentBucket.Bucket(name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func getBucketFromEntry(e entry, loc *time.Location) (string, error) {
+	return getBucketFromTime(e.Timestamp.In(loc))
 }
 
 func getBucketFromTime(t time.Time) (string, error) {
 	if t.IsZero() {
 		return "", errors.New("timestamp can't be zero")
 	}
-	return t.Format("2006-01-02"), nil
+	return t.Format(dayFormat), nil
 }
 
-func getBucketRange(start, end time.Time) ([]string, error) {
+// dayRange is the half-open interval covering whole days, each bound using its own location
+type dayRange struct {
+	start time.Time
+	end   time.Time
+}
+
+// newDayRange returns the range of the days from start to end, inclusive
+func newDayRange(start, end time.Time) (dayRange, error) {
 	if start.IsZero() || end.IsZero() {
-		return nil, errors.New("start and end must be set")
+		return dayRange{}, errors.New("start and end must be set")
 	}
 	if start.After(end) {
-		return nil, errors.New("start time is after end time")
+		return dayRange{}, errors.New("start time is after end time")
 	}
+	return dayRange{
+		start: startOfDay(start),
+		end:   startOfDay(end).AddDate(0, 0, 1),
+	}, nil
+}
 
-	truncate := func(t time.Time) time.Time {
-		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
-	}
+// bucketBounds returns the names of the first and last day buckets that can hold entries of the range when
+// the buckets are split by the days of loc
+func (r dayRange) bucketBounds(loc *time.Location) (string, string) {
+	return r.start.In(loc).Format(dayFormat), r.end.Add(-time.Nanosecond).In(loc).Format(dayFormat)
+}
 
-	strippedStart := truncate(start)
-	strippedEnd := truncate(end.AddDate(0, 0, 1))
-	buckets := make([]string, 0)
+func (r dayRange) contains(t time.Time) bool {
+	return !t.Before(r.start) && t.Before(r.end)
+}
 
-	for i := strippedStart; i.Before(strippedEnd); {
-		bucket, err := getBucketFromTime(i)
-		if err != nil {
-			return nil, err
-		}
-		i = i.AddDate(0, 0, 1)
-		buckets = append(buckets, bucket)
-	}
+func startOfDay(t time.Time) time.Time {
+	return dayStart(t.Year(), t.Month(), t.Day(), t.Location())
+}
 
-	return buckets, nil
+// dayStart returns the first instant of the day in loc, the date being normalized like time.Date does. Go moves a
+// midnight skipped by a DST change back into the previous day, such a day starting when the clocks go forward.
+func dayStart(year int, month time.Month, day int, loc *time.Location) time.Time {
+	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	t := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, loc)
+	if t.Day() != date.Day() {
+		_, offset := t.Zone()
+		t = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.FixedZone("", offset)).In(loc)
+	}
+	return t
 }
@@ -0,0 +1,517 @@
+package godid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+
+	"github.com/boltdb/bolt"
+)
+
+// The buckets nested in a parent bucket, e.g. work/payments, are bolt buckets inside the bolt bucket of their parent,
+// next to its day buckets. Their keys start with the path separator, which tells them apart from the day buckets.
+
+// childBucketKey returns the key of a child bucket inside the bolt bucket of its parent
+func childBucketKey(name string) []byte {
+	return []byte(bucketPathSeparator + name)
+}
+
+func isChildBucketKey(k []byte) bool {
+	return bytes.HasPrefix(k, []byte(bucketPathSeparator))
+}
+
+// getParentBucket returns the bolt bucket of a parent bucket path, nil when it doesn't exist
+func getParentBucket(tx *bolt.Tx, parentBucketName string) *bolt.Bucket {
+	segments := strings.Split(parentBucketName, bucketPathSeparator)
+	b := tx.Bucket([]byte(segments[0]))
+	for _, segment := range segments[1:] {
+		if b == nil {
+			return nil
+		}
+		b = b.Bucket(childBucketKey(segment))
+	}
+	return b
+}
+
+// createParentBucket returns the bolt bucket of a parent bucket path, creating it and its ancestors when missing
+func createParentBucket(tx *bolt.Tx, parentBucketName string) (*bolt.Bucket, error) {
+	segments := strings.Split(parentBucketName, bucketPathSeparator)
+	b, err := tx.CreateBucketIfNotExists([]byte(segments[0]))
+	if err != nil {
+		return nil, err
+	}
+	for _, segment := range segments[1:] {
+		if b, err = b.CreateBucketIfNotExists(childBucketKey(segment)); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// deleteParentBucket drops the bolt bucket of a parent bucket path along with its children
+func deleteParentBucket(tx *bolt.Tx, parentBucketName string) error {
+	parent, name, nested := cutBucketPath(parentBucketName)
+	if !nested {
+		return tx.DeleteBucket([]byte(name))
+	}
+	return getParentBucket(tx, parent).DeleteBucket(childBucketKey(name))
+}
+
+// forEachParentBucket calls fn for every parent bucket, the children coming after their parent
+func forEachParentBucket(tx *bolt.Tx, fn func(parentBucketName string, b *bolt.Bucket) error) error {
+	return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+		if isReservedBucket(name) {
+			return nil
+		}
+		return walkParentBucket(string(name), b, fn)
+	})
+}
+
+func walkParentBucket(parentBucketName string, b *bolt.Bucket, fn func(parentBucketName string, b *bolt.Bucket) error) error {
+	if err := fn(parentBucketName, b); err != nil {
+		return err
+	}
+	children := make([][]byte, 0)
+	err := b.ForEach(func(k, v []byte) error {
+		if v == nil && isChildBucketKey(k) {
+			children = append(children, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range children {
+		if err := walkParentBucket(parentBucketName+string(k), b.Bucket(k), fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Buckets walks the parent buckets, reading only the keys of the entries
+func (s *boltStore) Buckets() ([]BucketStats, error) {
+	result := make([]BucketStats, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return forEachParentBucket(tx, func(parentBucketName string, b *bolt.Bucket) error {
+			stats, err := s.bucketStats(b)
+			if err != nil {
+				return err
+			}
+			if stats.Entries > 0 {
+				stats.Name = parentBucketName
+				result = append(result, stats)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *boltStore) ChildBuckets(parentBucketName string) ([]string, error) {
+	result := make([]string, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := getParentBucket(tx, parentBucketName)
+		if b == nil {
+			return nil
+		}
+		return walkParentBucket(parentBucketName, b, func(name string, _ *bolt.Bucket) error {
+			if name != parentBucketName {
+				result = append(result, name)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// bucketStats counts the entries of the day buckets of a parent bucket, leaving its children out
+func (s *boltStore) bucketStats(parentBucket *bolt.Bucket) (BucketStats, error) {
+	var stats BucketStats
+	err := parentBucket.ForEach(func(day, v []byte) error {
+		if v != nil || isChildBucketKey(day) {
+			return nil
+		}
+		entries := stats.Entries
+		err := parentBucket.Bucket(day).ForEach(func(k, v []byte) error {
+			if v == nil {
+				return nil
+			}
+			// the unreadable keys are reported by did fsck
+			if t, err := decodeKey(k); err == nil {
+				stats.addEntry(t.In(s.loc))
+			}
+			return nil
+		})
+		if stats.Entries > entries {
+			stats.Days++
+		}
+		return err
+	})
+	return stats, err
+}
+
+// treeHasEntries tells whether a parent bucket or one of its children holds entries
+func (s *boltStore) treeHasEntries(parentBucketName string, b *bolt.Bucket) (bool, error) {
+	found := false
+	err := walkParentBucket(parentBucketName, b, func(_ string, b *bolt.Bucket) error {
+		stats, err := s.bucketStats(b)
+		found = found || stats.Entries > 0
+		return err
+	})
+	return found, err
+}
+
+// RenameBucket copies the day buckets over to the new parent bucket, children included, bolt having no way to rename a
+// bucket. The search postings, the trash and the history are moved along.
+func (s *boltStore) RenameBucket(oldName, newName string) error {
+	if err := checkBucketPath(newName); err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		oldParent := getParentBucket(tx, oldName)
+		if oldParent == nil || isReservedBucket([]byte(oldName)) {
+			return bucketNotFoundError(oldName)
+		}
+		if existing := getParentBucket(tx, newName); existing != nil {
+			found, err := s.treeHasEntries(newName, existing)
+			if err != nil {
+				return err
+			}
+			if found {
+				return bucketExistsError(newName)
+			}
+		}
+		if err := s.copyParentBucket(tx, oldName, newName, oldParent); err != nil {
+			return err
+		}
+		return deleteParentBucket(tx, oldName)
+	})
+}
+
+// copyParentBucket copies a parent bucket and its children to the new path, moving what's kept per parent bucket
+// elsewhere in the store along
+func (s *boltStore) copyParentBucket(tx *bolt.Tx, oldName, newName string, oldParent *bolt.Bucket) error {
+	newParent, err := createParentBucket(tx, newName)
+	if err != nil {
+		return err
+	}
+	children := make([][]byte, 0)
+	err = oldParent.ForEach(func(k, v []byte) error {
+		switch {
+		case v != nil:
+			return newParent.Put(k, v)
+		case isChildBucketKey(k):
+			children = append(children, append([]byte(nil), k...))
+			return nil
+		default:
+			return s.moveDayBucket(tx, oldName, newName, oldParent.Bucket(k), newParent, k)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{trashBucketName, historyBucketName} {
+		if err := renameRecordBucket(tx, name, oldName, newName); err != nil {
+			return err
+		}
+	}
+	for _, k := range children {
+		if err := s.copyParentBucket(tx, oldName+string(k), newName+string(k), oldParent.Bucket(k)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveDayBucket copies a day bucket to the new parent bucket, keeping the keys and the sequence
+func (s *boltStore) moveDayBucket(tx *bolt.Tx, oldName, newName string, b, newParent *bolt.Bucket, day []byte) error {
+	dst, err := newParent.CreateBucketIfNotExists(day)
+	if err != nil {
+		return err
+	}
+	if dst.Sequence() < b.Sequence() {
+		if err := dst.SetSequence(b.Sequence()); err != nil {
+			return err
+		}
+	}
+	return b.ForEach(func(k, v []byte) error {
+		if v == nil {
+			return nil
+		}
+		if err := dst.Put(k, v); err != nil {
+			return err
+		}
+		e, err := decodeEntry(k, v, s.loc)
+		if err != nil {
+			// unreadable entries aren't indexed
+			return nil
+		}
+		if err := unindexEntry(tx, oldName, k, e); err != nil {
+			return err
+		}
+		return indexEntry(tx, newName, k, e)
+	})
+}
+
+// renameRecordBucket moves the records kept per parent bucket in a reserved bucket, like the trash, to the new parent
+// bucket
+func renameRecordBucket(tx *bolt.Tx, reservedName, oldName, newName string) error {
+	reserved := tx.Bucket([]byte(reservedName))
+	if reserved == nil {
+		return nil
+	}
+	src := reserved.Bucket([]byte(oldName))
+	if src == nil {
+		return nil
+	}
+	dst, err := reserved.CreateBucketIfNotExists([]byte(newName))
+	if err != nil {
+		return err
+	}
+	if dst.Sequence() < src.Sequence() {
+		if err := dst.SetSequence(src.Sequence()); err != nil {
+			return err
+		}
+	}
+	err = src.ForEach(func(k, v []byte) error {
+		record, err := renameRecord(v, newName)
+		if err != nil {
+			return err
+		}
+		return dst.Put(k, record)
+	})
+	if err != nil {
+		return err
+	}
+	return reserved.DeleteBucket([]byte(oldName))
+}
+
+// MergeBucket moves the day buckets over like RenameBucket, into a parent bucket that may hold entries already. The
+// entries whose key is taken there, by an entry or a trashed one, get the next sequence number of their day, their
+// trash and history records following them.
+func (s *boltStore) MergeBucket(src, dst string) (int, error) {
+	if err := checkBucketPath(dst); err != nil {
+		return 0, err
+	}
+	moved := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		srcParent := getParentBucket(tx, src)
+		if srcParent == nil || isReservedBucket([]byte(src)) {
+			return bucketNotFoundError(src)
+		}
+		names := make([]string, 0)
+		err := walkParentBucket(src, srcParent, func(name string, _ *bolt.Bucket) error {
+			names = append(names, name)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			count, err := s.mergeParentBucket(tx, name, rebaseBucket(name, src, dst))
+			if err != nil {
+				return err
+			}
+			moved += count
+		}
+		if moved == 0 {
+			return bucketNotFoundError(src)
+		}
+		return deleteParentBucket(tx, src)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return moved, nil
+}
+
+// mergeParentBucket moves the day buckets of a single parent bucket, then its trash and history, returning how many
+// entries were moved
+func (s *boltStore) mergeParentBucket(tx *bolt.Tx, oldName, newName string) (int, error) {
+	oldParent := getParentBucket(tx, oldName)
+	newParent, err := createParentBucket(tx, newName)
+	if err != nil {
+		return 0, err
+	}
+	days := make([][]byte, 0)
+	err = oldParent.ForEach(func(k, v []byte) error {
+		switch {
+		case v != nil:
+			return newParent.Put(k, v)
+		case !isChildBucketKey(k):
+			days = append(days, append([]byte(nil), k...))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	// rekeyed maps the keys taken in the new parent bucket to the ones given instead
+	rekeyed := make(map[string][]byte)
+	moved := 0
+	for _, day := range days {
+		count, err := s.mergeDayBucket(tx, oldName, newName, oldParent.Bucket(day), newParent, day, rekeyed)
+		if err != nil {
+			return 0, err
+		}
+		moved += count
+	}
+	if err := s.mergeTrash(tx, oldName, newName, newParent, rekeyed); err != nil {
+		return 0, err
+	}
+	return moved, mergeHistory(tx, oldName, newName, rekeyed)
+}
+
+// mergeDayBucket copies a day bucket to the new parent bucket, giving the entries whose key is taken a new one
+func (s *boltStore) mergeDayBucket(tx *bolt.Tx, oldName, newName string, b, newParent *bolt.Bucket, day []byte,
+	rekeyed map[string][]byte) (int, error) {
+	dst, err := newParent.CreateBucketIfNotExists(day)
+	if err != nil {
+		return 0, err
+	}
+	if dst.Sequence() < b.Sequence() {
+		if err := dst.SetSequence(b.Sequence()); err != nil {
+			return 0, err
+		}
+	}
+	var trash *bolt.Bucket
+	if reserved := tx.Bucket([]byte(trashBucketName)); reserved != nil {
+		trash = reserved.Bucket([]byte(newName))
+	}
+	moved := 0
+	err = b.ForEach(func(k, v []byte) error {
+		if v == nil {
+			return nil
+		}
+		key := k
+		if dst.Get(k) != nil || (trash != nil && trash.Get(k) != nil) {
+			var err error
+			if key, err = nextKey(oldName, dst, k); err != nil {
+				return err
+			}
+			rekeyed[string(k)] = key
+		}
+		if err := dst.Put(key, v); err != nil {
+			return err
+		}
+		moved++
+		e, err := decodeEntry(k, v, s.loc)
+		if err != nil {
+			// unreadable entries aren't indexed
+			return nil
+		}
+		if err := unindexEntry(tx, oldName, k, e); err != nil {
+			return err
+		}
+		return indexEntry(tx, newName, key, e)
+	})
+	return moved, err
+}
+
+// mergeTrash moves the trash of a parent bucket, giving the trashed entries whose key is taken in the new parent
+// bucket a new one
+func (s *boltStore) mergeTrash(tx *bolt.Tx, oldName, newName string, newParent *bolt.Bucket, rekeyed map[string][]byte) error {
+	reserved := tx.Bucket([]byte(trashBucketName))
+	if reserved == nil || reserved.Bucket([]byte(oldName)) == nil {
+		return nil
+	}
+	src := reserved.Bucket([]byte(oldName))
+	dst, err := reserved.CreateBucketIfNotExists([]byte(newName))
+	if err != nil {
+		return err
+	}
+	err = src.ForEach(func(k, v []byte) error {
+		key, ok := rekeyed[string(k)]
+		if !ok {
+			key = k
+			if _, err := s.findEntryBucket(tx, newName, k); err == nil || dst.Get(k) != nil {
+				t, err := decodeKey(k)
+				if err != nil {
+					return err
+				}
+				day, err := getBucketFromTime(t.In(s.loc))
+				if err != nil {
+					return err
+				}
+				b, err := newParent.CreateBucketIfNotExists([]byte(day))
+				if err != nil {
+					return err
+				}
+				if key, err = nextKey(oldName, b, k); err != nil {
+					return err
+				}
+				rekeyed[string(k)] = key
+			}
+		}
+		id := ""
+		if !bytes.Equal(key, k) {
+			id = formatID(key)
+		}
+		record, err := rekeyRecord(v, newName, id)
+		if err != nil {
+			return err
+		}
+		return dst.Put(key, record)
+	})
+	if err != nil {
+		return err
+	}
+	return reserved.DeleteBucket([]byte(oldName))
+}
+
+// mergeHistory moves the history of a parent bucket after the revisions already kept in the new parent bucket,
+// following the entries given a new key
+func mergeHistory(tx *bolt.Tx, oldName, newName string, rekeyed map[string][]byte) error {
+	reserved := tx.Bucket([]byte(historyBucketName))
+	if reserved == nil || reserved.Bucket([]byte(oldName)) == nil {
+		return nil
+	}
+	src := reserved.Bucket([]byte(oldName))
+	dst, err := reserved.CreateBucketIfNotExists([]byte(newName))
+	if err != nil {
+		return err
+	}
+	err = src.ForEach(func(k, v []byte) error {
+		// the revision keys are the key of the entry followed by a sequence number
+		key, id := k[:len(k)-8], ""
+		if newKey, ok := rekeyed[string(key)]; ok {
+			key, id = newKey, formatID(newKey)
+		}
+		record, err := rekeyRecord(v, newName, id)
+		if err != nil {
+			return err
+		}
+		seq, err := dst.NextSequence()
+		if err != nil {
+			return err
+		}
+		revisionKey := make([]byte, len(key)+8)
+		copy(revisionKey, key)
+		binary.BigEndian.PutUint64(revisionKey[len(key):], seq)
+		return dst.Put(revisionKey, record)
+	})
+	if err != nil {
+		return err
+	}
+	return reserved.DeleteBucket([]byte(oldName))
+}
+
+// nextKey returns a new key for the entry logged at the time of k, taking the next sequence number of the day bucket b
+func nextKey(parentBucketName string, b *bolt.Bucket, k []byte) ([]byte, error) {
+	t, err := decodeKey(k)
+	if err != nil {
+		return nil, didErrorf("the key %q of bucket %s can't be read, run did fsck first", k, parentBucketName)
+	}
+	seq, err := b.NextSequence()
+	if err != nil {
+		return nil, err
+	}
+	return encodeKey(t, seq), nil
+}
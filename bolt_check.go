@@ -0,0 +1,266 @@
+package godid
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/boltdb/bolt"
+)
+
+const (
+	// quarantineBucketName holds the entries set aside by the repair, under their parent bucket and keyed by their
+	// day bucket and original key
+	quarantineBucketName = reservedBucketPrefix + "quarantine"
+)
+
+// checkAction is the repair of a problem found by the check
+type checkAction int
+
+const (
+	// checkFlag leaves the problem to the user
+	checkFlag checkAction = iota
+	checkMove
+	checkQuarantine
+	// checkDeleteDay drops a day bucket with an invalid name, once the repair has moved its entries out
+	checkDeleteDay
+)
+
+// boltCheckIssue is a problem found by the check along with what the repair does about it
+type boltCheckIssue struct {
+	problem CheckProblem
+	action  checkAction
+	parent  []byte
+	day     []byte
+	key     []byte
+	value   []byte
+	// target is the day bucket a misfiled entry belongs to
+	target string
+}
+
+// Check walks every parent and day bucket looking for entries that can't be read or are filed under the wrong day.
+// With repair the misfiled entries are moved to their day and the unreadable ones are quarantined, the search index
+// being rebuilt afterwards. The store may not be migrated yet, the legacy keys being valid ones.
+func (s *boltStore) Check(repair bool) ([]CheckProblem, error) {
+	var issues []boltCheckIssue
+	if !repair {
+		err := s.db.View(func(tx *bolt.Tx) error {
+			// a newer store may hold what this version can't tell from damage
+			if _, err := getSchemaVersion(tx); err != nil {
+				return err
+			}
+			var err error
+			issues, err = s.findIssues(tx)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return problemsOf(issues), nil
+	}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		if _, err := getSchemaVersion(tx); err != nil {
+			return err
+		}
+		var err error
+		issues, err = s.findIssues(tx)
+		if err != nil {
+			return err
+		}
+		repaired := false
+		for i := range issues {
+			if err := s.repairIssue(tx, &issues[i]); err != nil {
+				return err
+			}
+			repaired = repaired || issues[i].problem.Repaired
+		}
+		if err := deleteInvalidDayBuckets(tx, issues); err != nil {
+			return err
+		}
+		if !repaired {
+			return nil
+		}
+		_, err = migrateSearchIndex(tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return problemsOf(issues), nil
+}
+
+func problemsOf(issues []boltCheckIssue) []CheckProblem {
+	result := make([]CheckProblem, 0, len(issues))
+	for _, issue := range issues {
+		result = append(result, issue.problem)
+	}
+	return result
+}
+
+// findIssues collects the problems of the store without changing it, bolt not allowing changes while iterating
+func (s *boltStore) findIssues(tx *bolt.Tx) ([]boltCheckIssue, error) {
+	result := make([]boltCheckIssue, 0)
+	err := forEachParentBucket(tx, func(name string, parentBucket *bolt.Bucket) error {
+		parentBucketName := []byte(name)
+		return parentBucket.ForEach(func(day, v []byte) error {
+			if isChildBucketKey(day) {
+				return nil
+			}
+			if v != nil {
+				result = append(result, newCheckIssue(parentBucketName, nil, day, v, checkQuarantine, "value outside of a day bucket"))
+				return nil
+			}
+			if _, err := time.Parse(dayFormat, string(day)); err != nil {
+				result = append(result, newCheckIssue(parentBucketName, day, nil, nil, checkDeleteDay, "invalid day bucket name"))
+			}
+			dayIssues, err := s.findEntryIssues(parentBucketName, day, parentBucket.Bucket(day))
+			if err != nil {
+				return err
+			}
+			result = append(result, dayIssues...)
+			return nil
+		})
+	})
+	return result, err
+}
+
+func (s *boltStore) findEntryIssues(parentBucketName, day []byte, b *bolt.Bucket) ([]boltCheckIssue, error) {
+	result := make([]boltCheckIssue, 0)
+	err := b.ForEach(func(k, v []byte) error {
+		if v == nil {
+			result = append(result, newCheckIssue(parentBucketName, day, k, nil, checkFlag, "bucket inside a day bucket"))
+			return nil
+		}
+		timestamp, err := decodeKey(k)
+		if err != nil {
+			result = append(result, newCheckIssue(parentBucketName, day, k, v, checkQuarantine, "invalid key"))
+			return nil
+		}
+		p, _, err := decodeValue(v)
+		if err != nil {
+			result = append(result, newCheckIssue(parentBucketName, day, k, v, checkQuarantine, fmt.Sprintf("invalid value: %v", err)))
+			return nil
+		}
+		if target, err := getBucketFromTime(timestamp.In(s.loc)); err == nil && target != string(day) {
+			issue := newCheckIssue(parentBucketName, day, k, v, checkMove, fmt.Sprintf("filed under the wrong day, it belongs to %s", target))
+			issue.target = target
+			result = append(result, issue)
+		}
+		if p.Content == "" {
+			result = append(result, newCheckIssue(parentBucketName, day, k, v, checkFlag, "empty content"))
+		} else if !utf8.ValidString(p.Content) {
+			result = append(result, newCheckIssue(parentBucketName, day, k, v, checkFlag, "content isn't valid UTF-8"))
+		}
+		return nil
+	})
+	return result, err
+}
+
+func newCheckIssue(parentBucketName, day, key, value []byte, action checkAction, description string) boltCheckIssue {
+	printableKey := ""
+	if key != nil {
+		printableKey = fmt.Sprintf("%q", key)
+		if isKey(key) {
+			printableKey = formatID(key)
+		}
+	}
+	return boltCheckIssue{
+		problem: CheckProblem{
+			Bucket:      string(parentBucketName),
+			Day:         string(day),
+			Key:         printableKey,
+			Description: description,
+		},
+		action: action,
+		parent: append([]byte(nil), parentBucketName...),
+		day:    append([]byte(nil), day...),
+		key:    append([]byte(nil), key...),
+		value:  append([]byte(nil), value...),
+	}
+}
+
+func (s *boltStore) repairIssue(tx *bolt.Tx, issue *boltCheckIssue) error {
+	parentBucket := getParentBucket(tx, string(issue.parent))
+	var source *bolt.Bucket
+	if len(issue.day) == 0 {
+		source = parentBucket
+	} else {
+		source = parentBucket.Bucket(issue.day)
+	}
+	switch issue.action {
+	case checkQuarantine:
+		if err := quarantineEntry(tx, issue); err != nil {
+			return err
+		}
+	case checkMove:
+		// a quarantined entry is gone already
+		if source.Get(issue.key) == nil {
+			return nil
+		}
+		if err := moveEntry(parentBucket, source, issue); err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+	issue.problem.Repaired = true
+	return source.Delete(issue.key)
+}
+
+func quarantineEntry(tx *bolt.Tx, issue *boltCheckIssue) error {
+	quarantine, err := tx.CreateBucketIfNotExists([]byte(quarantineBucketName))
+	if err != nil {
+		return err
+	}
+	b, err := quarantine.CreateBucketIfNotExists(issue.parent)
+	if err != nil {
+		return err
+	}
+	return b.Put(bytes.Join([][]byte{issue.day, issue.key}, []byte("/")), issue.value)
+}
+
+// moveEntry files the entry under its day bucket. It gets a new sequence number when its key is taken there.
+func moveEntry(parentBucket, source *bolt.Bucket, issue *boltCheckIssue) error {
+	target, err := parentBucket.CreateBucketIfNotExists([]byte(issue.target))
+	if err != nil {
+		return err
+	}
+	key := issue.key
+	if existing := target.Get(key); existing != nil && !bytes.Equal(existing, issue.value) {
+		timestamp, err := decodeKey(key)
+		if err != nil {
+			return err
+		}
+		seq, err := target.NextSequence()
+		if err != nil {
+			return err
+		}
+		key = encodeKey(timestamp, seq)
+		issue.problem.Description += fmt.Sprintf(", moved with the new id %s", formatID(key))
+	}
+	return target.Put(key, source.Get(issue.key))
+}
+
+// deleteInvalidDayBuckets drops the day buckets with invalid names emptied by the repair
+func deleteInvalidDayBuckets(tx *bolt.Tx, issues []boltCheckIssue) error {
+	for i := range issues {
+		issue := &issues[i]
+		if issue.action != checkDeleteDay {
+			continue
+		}
+		parentBucket := getParentBucket(tx, string(issue.parent))
+		b := parentBucket.Bucket(issue.day)
+		if b == nil {
+			continue
+		}
+		if k, _ := b.Cursor().First(); k != nil {
+			continue
+		}
+		if err := parentBucket.DeleteBucket(issue.day); err != nil {
+			return err
+		}
+		issue.problem.Repaired = true
+	}
+	return nil
+}
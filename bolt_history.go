@@ -0,0 +1,101 @@
+package godid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// addRevision keeps the value an entry had before an edit. The revisions of an entry are stored under its key followed
+// by a sequence number, so they sort in the order they were made.
+func addRevision(tx *bolt.Tx, parentBucketName string, key, v []byte, replacedAt time.Time) error {
+	record, err := encodeRevisionRecord(parentBucketName, key, v, replacedAt)
+	if err != nil {
+		return err
+	}
+	history, err := tx.CreateBucketIfNotExists([]byte(historyBucketName))
+	if err != nil {
+		return err
+	}
+	b, err := history.CreateBucketIfNotExists([]byte(parentBucketName))
+	if err != nil {
+		return err
+	}
+	seq, err := b.NextSequence()
+	if err != nil {
+		return err
+	}
+	revisionKey := make([]byte, len(key)+8)
+	copy(revisionKey, key)
+	binary.BigEndian.PutUint64(revisionKey[len(key):], seq)
+	return b.Put(revisionKey, record)
+}
+
+func (s *boltStore) History(parentBucketName string, id string) ([]revision, error) {
+	key, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]revision, 0)
+	err = s.db.View(func(tx *bolt.Tx) error {
+		history := tx.Bucket([]byte(historyBucketName))
+		if history == nil {
+			return nil
+		}
+		b := history.Bucket([]byte(parentBucketName))
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Seek(key); k != nil && bytes.HasPrefix(k, key); k, v = c.Next() {
+			r, err := decodeRevisionRecord(v)
+			if err != nil {
+				return err
+			}
+			rev, err := r.revision(s.loc)
+			if err != nil {
+				return err
+			}
+			result = append(result, rev)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *boltStore) SetHistory(parentBucketName string, id string, revisions []revision) error {
+	key, err := parseID(id)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if history := tx.Bucket([]byte(historyBucketName)); history != nil && history.Bucket([]byte(parentBucketName)) != nil {
+			b := history.Bucket([]byte(parentBucketName))
+			stale := make([][]byte, 0)
+			c := b.Cursor()
+			for k, _ := c.Seek(key); k != nil && bytes.HasPrefix(k, key); k, _ = c.Next() {
+				stale = append(stale, append([]byte(nil), k...))
+			}
+			for _, k := range stale {
+				if err := b.Delete(k); err != nil {
+					return err
+				}
+			}
+		}
+		for _, r := range revisions {
+			v, err := encodeValue(r.entry)
+			if err != nil {
+				return err
+			}
+			if err := addRevision(tx, parentBucketName, key, v, r.replacedAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
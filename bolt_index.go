@@ -0,0 +1,243 @@
+package godid
+
+import (
+	"bytes"
+	"strconv"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/samber/lo"
+)
+
+// The full-text index lives in its own top level bucket. Every token of the indexed text of an entry gets a bucket
+// under terms, holding a posting per entry: the parent bucket name followed by the entry key.
+const (
+	indexBucketName      = reservedBucketPrefix + "index"
+	indexTermsBucketName = "terms"
+	indexDocumentsKey    = "documents"
+	// maxTermLength bounds the indexed tokens, longer ones can't be searched for
+	maxTermLength = 128
+)
+
+// indexEntry adds the postings of an entry stored under key in the parent bucket
+func indexEntry(tx *bolt.Tx, parentBucketName string, key []byte, e entry) error {
+	index, err := tx.CreateBucketIfNotExists([]byte(indexBucketName))
+	if err != nil {
+		return err
+	}
+	terms, err := index.CreateBucketIfNotExists([]byte(indexTermsBucketName))
+	if err != nil {
+		return err
+	}
+	posting := postingKey(parentBucketName, key)
+	for _, term := range indexedTerms(e) {
+		b, err := terms.CreateBucketIfNotExists([]byte(term))
+		if err != nil {
+			return err
+		}
+		if err := b.Put(posting, []byte{}); err != nil {
+			return err
+		}
+	}
+	return addIndexedDocuments(index, 1)
+}
+
+// unindexEntry removes the postings of an entry stored under key in the parent bucket, dropping the terms left empty
+func unindexEntry(tx *bolt.Tx, parentBucketName string, key []byte, e entry) error {
+	index := tx.Bucket([]byte(indexBucketName))
+	if index == nil {
+		return nil
+	}
+	terms := index.Bucket([]byte(indexTermsBucketName))
+	if terms == nil {
+		return nil
+	}
+	posting := postingKey(parentBucketName, key)
+	for _, term := range indexedTerms(e) {
+		b := terms.Bucket([]byte(term))
+		if b == nil {
+			continue
+		}
+		if err := b.Delete(posting); err != nil {
+			return err
+		}
+		if k, _ := b.Cursor().First(); k == nil {
+			if err := terms.DeleteBucket([]byte(term)); err != nil {
+				return err
+			}
+		}
+	}
+	return addIndexedDocuments(index, -1)
+}
+
+func (s *boltStore) Search(q searchQuery, f SearchFilter) ([]searchHit, error) {
+	result := make([]searchHit, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		index := tx.Bucket([]byte(indexBucketName))
+		if index == nil {
+			return nil
+		}
+		terms := index.Bucket([]byte(indexTermsBucketName))
+		if terms == nil {
+			return nil
+		}
+		total, err := getIndexedDocuments(index)
+		if err != nil {
+			return err
+		}
+		// the candidates hold all the words of the query, phrases are checked against the entries themselves
+		var candidates map[string]struct{}
+		df := make([]int, len(q))
+		for i, clause := range q {
+			for j, w := range clause {
+				postings := getPostings(terms, w)
+				if j == 0 || len(postings) < df[i] {
+					df[i] = len(postings)
+				}
+				candidates = intersectPostings(candidates, postings)
+			}
+		}
+		for posting := range candidates {
+			parentBucketName, key := splitPostingKey([]byte(posting))
+			if !f.containsBucket(parentBucketName) {
+				continue
+			}
+			timestamp, err := decodeKey(key)
+			if err != nil {
+				return err
+			}
+			if !f.contains(timestamp) {
+				continue
+			}
+			b, err := s.findEntryBucket(tx, parentBucketName, key)
+			if err != nil {
+				return err
+			}
+			e, err := decodeEntry(key, b.Get(key), s.loc)
+			if err != nil {
+				return err
+			}
+			counts, matched := q.counts(tokenize(indexedText(e)))
+			if !matched {
+				continue
+			}
+			result = append(result, searchHit{
+				parentBucketName: parentBucketName,
+				entry:            e,
+				score:            score(counts, df, total),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// getPostings returns the postings of the terms matching w
+func getPostings(terms *bolt.Bucket, w searchWord) map[string]struct{} {
+	result := make(map[string]struct{})
+	collect := func(b *bolt.Bucket) {
+		b.ForEach(func(k, _ []byte) error {
+			result[string(k)] = struct{}{}
+			return nil
+		})
+	}
+	if !w.prefix {
+		if b := terms.Bucket([]byte(w.text)); b != nil {
+			collect(b)
+		}
+		return result
+	}
+	c := terms.Cursor()
+	prefix := []byte(w.text)
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		if v == nil {
+			collect(terms.Bucket(k))
+		}
+	}
+	return result
+}
+
+// intersectPostings keeps the postings found in both sets, a nil set standing for all of them
+func intersectPostings(a, b map[string]struct{}) map[string]struct{} {
+	if a == nil {
+		return b
+	}
+	for posting := range a {
+		if _, ok := b[posting]; !ok {
+			delete(a, posting)
+		}
+	}
+	return a
+}
+
+// indexedTerms returns the distinct terms an entry is indexed under
+func indexedTerms(e entry) []string {
+	return lo.Filter(lo.Uniq(tokenize(indexedText(e))), func(term string, _ int) bool {
+		return len(term) <= maxTermLength
+	})
+}
+
+func postingKey(parentBucketName string, key []byte) []byte {
+	return append([]byte(parentBucketName), key...)
+}
+
+func splitPostingKey(posting []byte) (string, []byte) {
+	split := len(posting) - keyLength
+	return string(posting[:split]), posting[split:]
+}
+
+func getIndexedDocuments(index *bolt.Bucket) (int, error) {
+	v := index.Get([]byte(indexDocumentsKey))
+	if v == nil {
+		return 0, nil
+	}
+	return strconv.Atoi(string(v))
+}
+
+func addIndexedDocuments(index *bolt.Bucket, delta int) error {
+	documents, err := getIndexedDocuments(index)
+	if err != nil {
+		return err
+	}
+	return index.Put([]byte(indexDocumentsKey), []byte(strconv.Itoa(max(documents+delta, 0))))
+}
+
+// migrateSearchIndex indexes the entries logged before the full-text index was introduced, rebuilding it from scratch
+func migrateSearchIndex(tx *bolt.Tx) (int, error) {
+	if tx.Bucket([]byte(indexBucketName)) != nil {
+		if err := tx.DeleteBucket([]byte(indexBucketName)); err != nil {
+			return 0, err
+		}
+	}
+	// created upfront so the top level buckets don't change while walking them
+	if _, err := tx.CreateBucket([]byte(indexBucketName)); err != nil {
+		return 0, err
+	}
+	changes := 0
+	err := forEachDayBucket(tx, func(parentBucketName []byte, b *bolt.Bucket) error {
+		entries := make(map[string]entry)
+		err := b.ForEach(func(k, v []byte) error {
+			e, err := decodeEntry(k, v, time.UTC)
+			// the entries that can't be read can't be searched for either, did fsck reports them
+			if v == nil || err != nil {
+				return nil
+			}
+			entries[string(k)] = e
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for k, e := range entries {
+			if err := indexEntry(tx, string(parentBucketName), []byte(k), e); err != nil {
+				return err
+			}
+			changes++
+		}
+		return nil
+	})
+	return changes, err
+}
@@ -14,13 +14,22 @@ func timeFromString(t *testing.T, ts string) time.Time {
 	return result
 }
 
-func TestGetBucketRange(t *testing.T) {
+func requireEntriesEqual(t *testing.T, expected, actual []entry) {
+	require.Len(t, actual, len(expected))
+	for i := range expected {
+		require.True(t, expected[i].Timestamp.Equal(actual[i].Timestamp), "expected %s, got %s", expected[i].Timestamp, actual[i].Timestamp)
+		require.Equal(t, expected[i].Content, actual[i].Content)
+	}
+}
+
+func TestDayRange(t *testing.T) {
 	testCases := []struct {
-		name        string
-		start       time.Time
-		end         time.Time
-		expected    []string
-		shouldError bool
+		name          string
+		start         time.Time
+		end           time.Time
+		expectedFirst string
+		expectedLast  string
+		shouldError   bool
 	}{
 		{
 			name:        "empty",
@@ -33,50 +42,86 @@ func TestGetBucketRange(t *testing.T) {
 			shouldError: true,
 		},
 		{
-			name:     "same day",
-			start:    timeFromString(t, "2018-07-17T12:00:00Z"),
-			end:      timeFromString(t, "2018-07-17T13:00:00Z"),
-			expected: []string{"2018-07-17"},
+			name:          "same day",
+			start:         timeFromString(t, "2018-07-17T12:00:00Z"),
+			end:           timeFromString(t, "2018-07-17T13:00:00Z"),
+			expectedFirst: "2018-07-17",
+			expectedLast:  "2018-07-17",
 		},
 		{
-			name:     "one day",
-			start:    timeFromString(t, "2018-07-17T12:00:00Z"),
-			end:      timeFromString(t, "2018-07-18T13:00:00Z"),
-			expected: []string{"2018-07-17", "2018-07-18"},
+			name:          "one day",
+			start:         timeFromString(t, "2018-07-17T12:00:00Z"),
+			end:           timeFromString(t, "2018-07-18T13:00:00Z"),
+			expectedFirst: "2018-07-17",
+			expectedLast:  "2018-07-18",
 		},
 		{
-			name:     "one week",
-			start:    timeFromString(t, "2018-07-17T12:00:00Z"),
-			end:      timeFromString(t, "2018-07-24T23:00:00Z"),
-			expected: []string{"2018-07-17", "2018-07-18", "2018-07-19", "2018-07-20", "2018-07-21", "2018-07-22", "2018-07-23", "2018-07-24"},
+			name:          "one week",
+			start:         timeFromString(t, "2018-07-17T12:00:00Z"),
+			end:           timeFromString(t, "2018-07-24T23:00:00Z"),
+			expectedFirst: "2018-07-17",
+			expectedLast:  "2018-07-24",
 		},
 		{
-			name:     "lower bound is limit",
-			start:    timeFromString(t, "2018-07-17T00:00:00Z"),
-			end:      timeFromString(t, "2018-07-18T23:00:00Z"),
-			expected: []string{"2018-07-17", "2018-07-18"},
+			name:          "lower bound is limit",
+			start:         timeFromString(t, "2018-07-17T00:00:00Z"),
+			end:           timeFromString(t, "2018-07-18T23:00:00Z"),
+			expectedFirst: "2018-07-17",
+			expectedLast:  "2018-07-18",
 		},
 		{
-			name:     "upper bound is limit",
-			start:    timeFromString(t, "2018-07-17T01:00:00Z"),
-			end:      timeFromString(t, "2018-07-18T00:00:00Z"),
-			expected: []string{"2018-07-17", "2018-07-18"},
+			name:          "upper bound is limit",
+			start:         timeFromString(t, "2018-07-17T01:00:00Z"),
+			end:           timeFromString(t, "2018-07-18T00:00:00Z"),
+			expectedFirst: "2018-07-17",
+			expectedLast:  "2018-07-18",
+		},
+		{
+			name:          "across years",
+			start:         timeFromString(t, "2017-12-31T23:00:00Z"),
+			end:           timeFromString(t, "2018-01-01T01:00:00Z"),
+			expectedFirst: "2017-12-31",
+			expectedLast:  "2018-01-01",
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			actual, err := getBucketRange(tc.start, tc.end)
+			r, err := newDayRange(tc.start, tc.end)
 			if tc.shouldError {
 				require.Error(t, err)
 			} else {
 				require.NoError(t, err)
-				assert.Equal(t, tc.expected, actual)
+				first, last := r.bucketBounds(time.UTC)
+				assert.Equal(t, tc.expectedFirst, first)
+				assert.Equal(t, tc.expectedLast, last)
+				assert.True(t, r.contains(tc.start))
+				assert.True(t, r.contains(tc.end))
+				assert.False(t, r.contains(r.end))
+				assert.False(t, r.contains(r.start.Add(-time.Nanosecond)))
 			}
 		})
 	}
 }
 
+func TestDayRangeInOtherLocation(t *testing.T) {
+	bucharest, err := time.LoadLocation("Europe/Bucharest")
+	require.NoError(t, err)
+	start := timeFromString(t, "2018-07-17T12:00:00+03:00").In(bucharest)
+	end := timeFromString(t, "2018-07-18T12:00:00+03:00").In(bucharest)
+	r, err := newDayRange(start, end)
+	require.NoError(t, err)
+	assert.True(t, timeFromString(t, "2018-07-16T21:00:00Z").Equal(r.start))
+	assert.True(t, timeFromString(t, "2018-07-18T21:00:00Z").Equal(r.end))
+
+	first, last := r.bucketBounds(time.UTC)
+	assert.Equal(t, "2018-07-16", first)
+	assert.Equal(t, "2018-07-18", last)
+	first, last = r.bucketBounds(bucharest)
+	assert.Equal(t, "2018-07-17", first)
+	assert.Equal(t, "2018-07-18", last)
+}
+
 func TestGetBucketFromTime(t *testing.T) {
 	ts := timeFromString(t, "2018-07-18T00:00:00Z")
 	bucket, err := getBucketFromTime(ts)
@@ -93,11 +138,15 @@ func TestGetBucketFromEntry(t *testing.T) {
 		Timestamp: timeFromString(t, "2018-07-18T00:00:00Z"),
 		Content:   []byte("asdb"),
 	}
-	bucket, err := getBucketFromEntry(e)
+	bucket, err := getBucketFromEntry(e, time.UTC)
 	require.NoError(t, err)
 	assert.Equal(t, "2018-07-18", bucket)
 
+	bucket, err = getBucketFromEntry(e, time.FixedZone("PDT", -7*60*60))
+	require.NoError(t, err)
+	assert.Equal(t, "2018-07-17", bucket)
+
 	empty := entry{}
-	_, err = getBucketFromEntry(empty)
+	_, err = getBucketFromEntry(empty, time.UTC)
 	require.Error(t, err)
 }
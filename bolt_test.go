@@ -1,37 +1,29 @@
 package godid
 
 import (
-	"errors"
-	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/boltdb/bolt"
+	"github.com/samber/lo"
 
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
 type boltTestSuite struct {
-	suite.Suite
-	store          *boltStore
-	db             *bolt.DB
-	testBucketName string
-}
-
-func (s *boltTestSuite) SetupSuite() {
-	os.Remove("test.db")
+	storeTestSuite
+	db *bolt.DB
 }
 
 func (s *boltTestSuite) SetupTest() {
-	s.store = getTestBoltStore(s.T())
-	s.db = s.store.db
-	s.testBucketName = randString(10)
+	s.storeTestSuite.SetupTest()
+	s.db = s.boltStore().db
 }
 
-func (s *boltTestSuite) TearDownTest() {
-	s.store.Close()
-	s.store = nil
-	os.Remove("test.db")
+func (s *boltTestSuite) boltStore() *boltStore {
+	return s.store.(*boltStore)
 }
 
 func (s *boltTestSuite) TestPut() {
@@ -98,8 +90,9 @@ func (s *boltTestSuite) TestPut() {
 	}
 
 	for _, tc := range testCases {
+		s.testBucketName = randString(10)
 		for _, entry := range tc.entries {
-			err := s.store.Put(s.testBucketName, entry)
+			_, err := s.store.Put(s.testBucketName, entry)
 			if tc.shouldError {
 				s.Error(err)
 			} else {
@@ -119,158 +112,315 @@ func (s *boltTestSuite) TestPut() {
 	}
 }
 
-func (s *boltTestSuite) TestGetRange() {
-	entries := []entry{
-		{Timestamp: timeFromString(s.T(), "2018-07-18T12:11:00Z"), Content: []byte("msg1")},
+func (s *boltTestSuite) TestMigrateLegacyKeys() {
+	legacy := map[string]string{
+		"2018-07-18T14:21:00Z":      "msg3",
+		"2018-07-18T12:11:00Z":      "msg1",
+		"2018-07-18T15:32:00+02:00": "msg2",
+	}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		parentBucket, err := tx.CreateBucketIfNotExists([]byte(s.testBucketName))
+		s.Require().NoError(err)
+		b, err := parentBucket.CreateBucketIfNotExists([]byte("2018-07-18"))
+		s.Require().NoError(err)
+		for k, v := range legacy {
+			s.Require().NoError(b.Put([]byte(k), []byte(v)))
+		}
+		return nil
+	})
+	s.Require().NoError(err)
+
+	ts := timeFromString(s.T(), "2018-07-18T12:11:00Z")
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts.Add(time.Minute), Content: []byte("new")})
+	expected := []entry{
+		{Timestamp: ts, Content: []byte("msg1")},
+		{Timestamp: ts.Add(time.Minute), Content: []byte("new")},
 		{Timestamp: timeFromString(s.T(), "2018-07-18T13:32:00Z"), Content: []byte("msg2")},
 		{Timestamp: timeFromString(s.T(), "2018-07-18T14:21:00Z"), Content: []byte("msg3")},
-		{Timestamp: timeFromString(s.T(), "2018-07-19T09:11:00Z"), Content: []byte("msg4")},
-		{Timestamp: timeFromString(s.T(), "2018-07-19T23:15:00Z"), Content: []byte("msg5")},
-		{Timestamp: timeFromString(s.T(), "2018-07-20T10:11:00Z"), Content: []byte("msg6")},
-	}
-	for _, entry := range entries {
-		err := s.store.Put(s.testBucketName, entry)
-		s.NoError(err)
-	}
-	testCases := []struct {
-		start       time.Time
-		end         time.Time
-		shouldError bool
-		expected    []entry
-	}{
-		{
-			shouldError: true,
-		},
-		{
-			start:       time.Now().Add(1 * time.Hour),
-			end:         time.Now(),
-			shouldError: true,
-		},
-		{
-			start:    timeFromString(s.T(), "2018-06-20T10:11:00Z"),
-			end:      timeFromString(s.T(), "2018-06-21T10:11:00Z"),
-			expected: []entry{},
-		},
-		{
-			start:    timeFromString(s.T(), "2018-07-20T09:11:00Z"),
-			end:      timeFromString(s.T(), "2018-07-20T10:11:00Z"),
-			expected: []entry{{Timestamp: timeFromString(s.T(), "2018-07-20T10:11:00Z"), Content: []byte("msg6")}},
-		},
-		{
-			start: timeFromString(s.T(), "2018-07-18T12:11:00Z"),
-			end:   timeFromString(s.T(), "2018-07-19T09:11:00Z"),
-			expected: []entry{
-				{Timestamp: timeFromString(s.T(), "2018-07-18T12:11:00Z"), Content: []byte("msg1")},
-				{Timestamp: timeFromString(s.T(), "2018-07-18T13:32:00Z"), Content: []byte("msg2")},
-				{Timestamp: timeFromString(s.T(), "2018-07-18T14:21:00Z"), Content: []byte("msg3")},
-				{Timestamp: timeFromString(s.T(), "2018-07-19T09:11:00Z"), Content: []byte("msg4")},
-				{Timestamp: timeFromString(s.T(), "2018-07-19T23:15:00Z"), Content: []byte("msg5")},
-			},
-		},
-		{
-			start: timeFromString(s.T(), "2018-06-18T12:11:00Z"),
-			end:   timeFromString(s.T(), "2018-09-19T09:11:00Z"),
-			expected: []entry{
-				{Timestamp: timeFromString(s.T(), "2018-07-18T12:11:00Z"), Content: []byte("msg1")},
-				{Timestamp: timeFromString(s.T(), "2018-07-18T13:32:00Z"), Content: []byte("msg2")},
-				{Timestamp: timeFromString(s.T(), "2018-07-18T14:21:00Z"), Content: []byte("msg3")},
-				{Timestamp: timeFromString(s.T(), "2018-07-19T09:11:00Z"), Content: []byte("msg4")},
-				{Timestamp: timeFromString(s.T(), "2018-07-19T23:15:00Z"), Content: []byte("msg5")},
-				{Timestamp: timeFromString(s.T(), "2018-07-20T10:11:00Z"), Content: []byte("msg6")},
-			},
-		},
 	}
+	entries, err := s.store.GetRange(s.testBucketName, ts, ts)
+	s.NoError(err)
+	requireEntriesEqual(s.T(), expected, entries)
 
-	for _, tc := range testCases {
-		entries, err := s.store.GetRange(s.testBucketName, tc.start, tc.end)
-		if tc.shouldError {
-			s.Error(err)
-		} else {
-			s.NoError(err)
-			s.Equal(tc.expected, entries)
-		}
-	}
+	results, err := s.boltStore().Migrate(false)
+	s.NoError(err)
+	s.Equal([]MigrationResult{
+		{Version: 1, Description: boltMigrations[0].description, Changes: 3},
+		{Version: 2, Description: boltMigrations[1].description, Changes: 4},
+		{Version: 3, Description: boltMigrations[2].description, Changes: 0},
+		{Version: 4, Description: boltMigrations[3].description, Changes: 0},
+	}, results)
+	s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(s.testBucketName)).Bucket([]byte("2018-07-18")).ForEach(func(k, _ []byte) error {
+			s.True(isKey(k))
+			return nil
+		})
+	})
+	entries, err = s.store.GetRange(s.testBucketName, ts, ts)
+	s.NoError(err)
+	requireEntriesEqual(s.T(), expected, entries)
 }
 
-func (s *boltTestSuite) TestGetRangeWithAggregation() {
-	entries := []entry{
-		{Timestamp: timeFromString(s.T(), "2018-07-18T12:11:00Z"), Content: []byte("msg1")},
-		{Timestamp: timeFromString(s.T(), "2018-07-18T13:32:00Z"), Content: []byte("msg2")},
-		{Timestamp: timeFromString(s.T(), "2018-07-18T14:21:00Z"), Content: []byte("msg3")},
-		{Timestamp: timeFromString(s.T(), "2018-07-19T09:11:00Z"), Content: []byte("msg4")},
-		{Timestamp: timeFromString(s.T(), "2018-07-19T23:15:00Z"), Content: []byte("msg5")},
-		{Timestamp: timeFromString(s.T(), "2018-07-20T10:11:00Z"), Content: []byte("msg6")},
+func (s *boltTestSuite) TestFindMisfiledEntry() {
+	ts := timeFromString(s.T(), "2018-07-18T12:11:00Z")
+	key := encodeKey(ts, 1)
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		parentBucket, err := tx.CreateBucketIfNotExists([]byte(s.testBucketName))
+		s.Require().NoError(err)
+		b, err := parentBucket.CreateBucketIfNotExists([]byte("2018-07-19"))
+		s.Require().NoError(err)
+		return b.Put(key, []byte("msg1"))
+	})
+	s.Require().NoError(err)
+	s.NoError(s.store.Update(s.testBucketName, entry{ID: formatID(key), Content: []byte("msg2")}))
+	s.NoError(s.store.Delete(s.testBucketName, formatID(key)))
+}
+
+func (s *boltTestSuite) TestGetRangeSkipsOtherBuckets() {
+	ts := timeFromString(s.T(), "2018-07-18T12:11:00Z")
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("msg1")})
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts.AddDate(0, 0, 2), Content: []byte("msg2")})
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(s.testBucketName)).Put([]byte("2018-07-19"), []byte("not a bucket"))
+	})
+	s.Require().NoError(err)
+	entries, err := s.store.GetRange(s.testBucketName, ts, ts.AddDate(0, 0, 2))
+	s.NoError(err)
+	requireEntriesEqual(s.T(), []entry{
+		{Timestamp: ts, Content: []byte("msg1")},
+		{Timestamp: ts.AddDate(0, 0, 2), Content: []byte("msg2")},
+	}, entries)
+}
+
+func (s *boltTestSuite) TestSearchIndex() {
+	ts := timeFromString(s.T(), "2018-07-18T12:11:00Z")
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("billing billing migration")})
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("billing")})
+	terms := func() map[string]int {
+		result := make(map[string]int)
+		s.db.View(func(tx *bolt.Tx) error {
+			return tx.Bucket([]byte(indexBucketName)).Bucket([]byte(indexTermsBucketName)).ForEach(func(k, _ []byte) error {
+				result[string(k)] = tx.Bucket([]byte(indexBucketName)).Bucket([]byte(indexTermsBucketName)).Bucket(k).Stats().KeyN
+				return nil
+			})
+		})
+		return result
 	}
-	for _, entry := range entries {
-		err := s.store.Put(s.testBucketName, entry)
+	s.Equal(map[string]int{"billing": 2, "migration": 1}, terms())
+
+	id := formatID(encodeKey(ts, 1))
+	s.NoError(s.store.Update(s.testBucketName, entry{ID: id, Content: []byte("deploy"), Metadata: Metadata{Project: "payments"}}))
+	s.Equal(map[string]int{"billing": 1, "deploy": 1, "payments": 1}, terms())
+
+	s.NoError(s.store.Delete(s.testBucketName, id))
+	s.Equal(map[string]int{"billing": 1}, terms())
+	s.db.View(func(tx *bolt.Tx) error {
+		documents, err := getIndexedDocuments(tx.Bucket([]byte(indexBucketName)))
 		s.NoError(err)
+		s.Equal(1, documents)
+		return nil
+	})
+}
+
+func (s *boltTestSuite) TestRestoreInvalidEntries() {
+	ts := timeFromString(s.T(), "2018-07-18T12:11:00Z")
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("msg1")})
+	snapshot := filepath.Join(s.T().TempDir(), "snapshot.db")
+	s.Require().NoError(s.boltStore().Backup(snapshot))
+
+	db, err := bolt.Open(snapshot, 0600, nil)
+	s.Require().NoError(err)
+	err = db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(s.testBucketName)).Bucket([]byte("2018-07-18")).Put(encodeKey(ts, 2), []byte{valueVersionStructured, '{'})
+	})
+	s.Require().NoError(err)
+	s.Require().NoError(db.Close())
+
+	s.IsType(DidError{}, s.boltStore().Restore(snapshot))
+	s.IsType(DidError{}, s.boltStore().Restore(s.db.Path()))
+	entries, err := s.store.GetRange(s.testBucketName, ts, ts)
+	s.NoError(err)
+	s.Len(entries, 1)
+}
+
+func (s *boltTestSuite) TestRestoreSwap() {
+	ts := timeFromString(s.T(), "2018-07-18T12:11:00Z")
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("msg1")})
+	snapshot := filepath.Join(s.T().TempDir(), "snapshot.db")
+	s.Require().NoError(s.boltStore().Backup(snapshot))
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts.Add(time.Hour), Content: []byte("msg2")})
+
+	s.Require().NoError(s.boltStore().Restore(snapshot))
+	leftovers, err := filepath.Glob(s.db.Path() + ".*.tmp")
+	s.NoError(err)
+	s.Empty(leftovers, "the original store is only kept until the snapshot is opened")
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts.Add(2 * time.Hour), Content: []byte("msg3")})
+	entries, err := s.store.GetRange(s.testBucketName, ts, ts)
+	s.NoError(err)
+	s.Equal([]string{"msg1", "msg3"}, lo.Map(entries, func(e entry, _ int) string { return string(e.Content) }))
+}
+
+func (s *boltTestSuite) TestCheck() {
+	ts := timeFromString(s.T(), "2018-07-18T12:11:00Z")
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("msg1")})
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts.Add(time.Minute), Content: []byte{0xff, 0xfe}})
+	misfiled := encodeKey(ts.Add(time.Hour), 1)
+	taken := encodeKey(ts, 1)
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		parentBucket := tx.Bucket([]byte(s.testBucketName))
+		s.Require().NoError(parentBucket.Put([]byte("stray"), []byte("value")))
+		wrongDay, err := parentBucket.CreateBucket([]byte("2018-07-19"))
+		s.Require().NoError(err)
+		s.Require().NoError(wrongDay.Put(misfiled, []byte("misfiled")))
+		invalidDay, err := parentBucket.CreateBucket([]byte("yesterday"))
+		s.Require().NoError(err)
+		s.Require().NoError(invalidDay.Put(taken, []byte("taken")))
+		day := parentBucket.Bucket([]byte("2018-07-18"))
+		s.Require().NoError(day.Put([]byte("bad key"), []byte("msg")))
+		s.Require().NoError(day.Put(encodeKey(ts, 10), []byte{valueVersionStructured, '{'}))
+		return day.Put(encodeKey(ts, 11), []byte{})
+	})
+	s.Require().NoError(err)
+
+	entries, err := s.store.GetRange(s.testBucketName, ts, ts)
+	s.NoError(err, "unreadable entries shouldn't fail the queries")
+	s.Len(entries, 3)
+
+	c := s.store.(checker)
+	problems, err := c.Check(false)
+	s.Require().NoError(err)
+	s.ElementsMatch([]CheckProblem{
+		{Bucket: s.testBucketName, Key: `"stray"`, Description: "value outside of a day bucket"},
+		{Bucket: s.testBucketName, Day: "2018-07-18", Key: `"bad key"`, Description: "invalid key"},
+		{Bucket: s.testBucketName, Day: "2018-07-18", Key: formatID(encodeKey(ts, 10)), Description: "invalid value: invalid entry payload: unexpected end of JSON input"},
+		{Bucket: s.testBucketName, Day: "2018-07-18", Key: formatID(encodeKey(ts, 11)), Description: "empty content"},
+		{Bucket: s.testBucketName, Day: "2018-07-18", Key: formatID(encodeKey(ts.Add(time.Minute), 2)), Description: "content isn't valid UTF-8"},
+		{Bucket: s.testBucketName, Day: "2018-07-19", Key: formatID(misfiled), Description: "filed under the wrong day, it belongs to 2018-07-18"},
+		{Bucket: s.testBucketName, Day: "yesterday", Description: "invalid day bucket name"},
+		{Bucket: s.testBucketName, Day: "yesterday", Key: formatID(taken), Description: "filed under the wrong day, it belongs to 2018-07-18"},
+	}, problems)
+
+	problems, err = c.Check(true)
+	s.Require().NoError(err)
+	repaired := 0
+	for _, p := range problems {
+		if p.Repaired {
+			repaired++
+		}
 	}
-	testCases := []struct {
-		start       time.Time
-		end         time.Time
-		agg         aggregationFunction
-		shouldError bool
-		expected    any
-	}{
-		{
-			shouldError: true,
-		},
-		{
-			start:       time.Now().Add(1 * time.Hour),
-			end:         time.Now(),
-			shouldError: true,
-			agg: func(e []entry) (any, error) {
-				return nil, nil
-			},
-		},
-		{
-			start:       time.Now().Add(1 * time.Hour),
-			end:         time.Now(),
-			shouldError: true,
-		},
-		{
-			start:       timeFromString(s.T(), "2018-06-20T10:11:00Z"),
-			end:         timeFromString(s.T(), "2018-06-21T10:11:00Z"),
-			shouldError: true,
-		},
-		{
-			start: timeFromString(s.T(), "2018-06-20T10:11:00Z"),
-			end:   timeFromString(s.T(), "2018-06-21T10:11:00Z"),
-			agg: func(e []entry) (any, error) {
-				return nil, errors.New("BOOM")
-			},
-			shouldError: true,
-		},
-		{
-			start: timeFromString(s.T(), "2018-06-18T12:11:00Z"),
-			end:   timeFromString(s.T(), "2018-09-19T09:11:00Z"),
-			agg: func(e []entry) (any, error) {
-				return e, nil
-			},
-			expected: []entry{
-				{Timestamp: timeFromString(s.T(), "2018-07-18T12:11:00Z"), Content: []byte("msg1")},
-				{Timestamp: timeFromString(s.T(), "2018-07-18T13:32:00Z"), Content: []byte("msg2")},
-				{Timestamp: timeFromString(s.T(), "2018-07-18T14:21:00Z"), Content: []byte("msg3")},
-				{Timestamp: timeFromString(s.T(), "2018-07-19T09:11:00Z"), Content: []byte("msg4")},
-				{Timestamp: timeFromString(s.T(), "2018-07-19T23:15:00Z"), Content: []byte("msg5")},
-				{Timestamp: timeFromString(s.T(), "2018-07-20T10:11:00Z"), Content: []byte("msg6")},
+	s.Equal(6, repaired)
+	problems, err = c.Check(false)
+	s.Require().NoError(err)
+	s.Len(problems, 2, "only the content problems should be left")
+
+	entries, err = s.store.GetRange(s.testBucketName, ts, ts)
+	s.NoError(err)
+	s.Len(entries, 5)
+	contents := make([]string, 0)
+	for _, e := range entries {
+		contents = append(contents, string(e.Content))
+	}
+	s.Contains(contents, "misfiled")
+	s.Contains(contents, "taken", "the entry should get a new id when its key is taken")
+	hits, err := s.boltStore().Search(searchQuery{{{text: "misfiled"}}}, SearchFilter{})
+	s.NoError(err)
+	s.Len(hits, 1, "the moved entries should be searchable")
+	s.db.View(func(tx *bolt.Tx) error {
+		s.Nil(tx.Bucket([]byte(s.testBucketName)).Bucket([]byte("yesterday")))
+		quarantine := tx.Bucket([]byte(quarantineBucketName)).Bucket([]byte(s.testBucketName))
+		s.Equal([]byte("value"), quarantine.Get([]byte("/stray")))
+		s.Equal([]byte("msg"), quarantine.Get([]byte("2018-07-18/bad key")))
+		return nil
+	})
+}
+
+func (s *boltTestSuite) TestHomeTimezoneBucket() {
+	s.store.Close()
+	s.cleanup()
+	s.store = s.newStore(config{HomeTimezone: "America/Los_Angeles"})
+	s.db = s.boltStore().db
+	ts := timeFromString(s.T(), "2018-07-18T03:00:00Z")
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("msg1")})
+	s.db.View(func(tx *bolt.Tx) error {
+		s.NotNil(tx.Bucket([]byte(s.testBucketName)).Bucket([]byte("2018-07-17")))
+		return nil
+	})
+}
+
+func TestBoltStore(t *testing.T) {
+	suite.Run(t, &boltTestSuite{
+		storeTestSuite: storeTestSuite{
+			newStore: func(cfg config) entryStore {
+				return getTestBoltStore(t, cfg)
 			},
+			cleanup: cleanupTestBoltStore,
 		},
+	})
+}
+
+// populateBenchmarkStore logs a few entries on every working day of the last years
+func populateBenchmarkStore(b *testing.B, years int) (*boltStore, string) {
+	s := getTestBoltStore(b, config{})
+	bucketName := randString(10)
+	s.db.NoSync = true
+	end := time.Now()
+	for day := end.AddDate(-years, 0, 0); day.Before(end); day = day.AddDate(0, 0, 1) {
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+		for i := 0; i < 3; i++ {
+			putEntry(b, s, bucketName, entry{Timestamp: day.Add(time.Duration(i) * time.Hour), Content: []byte(randString(40))})
+		}
 	}
+	s.db.NoSync = false
+	return s, bucketName
+}
 
-	for _, tc := range testCases {
-		result, err := s.store.GetRangeWithAggregation(s.testBucketName, tc.start, tc.end, tc.agg)
-		if tc.shouldError {
-			s.Error(err)
-		} else {
-			s.NoError(err)
-			entries, ok := result.([]entry)
-			s.True(ok)
-			s.Equal(tc.expected, entries)
+// getRangePerDay is the former GetRange implementation, reading each day of the range in its own transaction
+func getRangePerDay(s *boltStore, parentBucketName string, start, end time.Time) ([]entry, error) {
+	result := make([]entry, 0)
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		bucketName, err := getBucketFromTime(day)
+		if err != nil {
+			return nil, err
+		}
+		err = s.db.View(func(tx *bolt.Tx) error {
+			parentBucket := tx.Bucket([]byte(parentBucketName))
+			if parentBucket == nil {
+				return nil
+			}
+			b := parentBucket.Bucket([]byte(bucketName))
+			if b == nil {
+				return nil
+			}
+			bucketEntries, err := s.getBucketEntries(b)
+			result = append(result, bucketEntries...)
+			return err
+		})
+		if err != nil {
+			return nil, err
 		}
 	}
+	return result, nil
 }
 
-func TestBoltStore(t *testing.T) {
-	suite.Run(t, new(boltTestSuite))
+func BenchmarkGetRange(b *testing.B) {
+	s, bucketName := populateBenchmarkStore(b, 5)
+	defer cleanupTestBoltStore()
+	defer s.Close()
+	end := time.Now()
+	start := end.AddDate(0, 0, -3650)
+
+	b.Run("cursor", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, err := s.GetRange(bucketName, start, end)
+			require.NoError(b, err)
+		}
+	})
+	b.Run("per day transactions", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, err := getRangePerDay(s, bucketName, start, end)
+			require.NoError(b, err)
+		}
+	})
 }
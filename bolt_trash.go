@@ -0,0 +1,189 @@
+package godid
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/boltdb/bolt"
+)
+
+// Trash moves the entry out of its day bucket and of the search index into the trash bucket
+func (s *boltStore) Trash(parentBucketName string, id string, deletedAt time.Time) error {
+	key, err := parseID(id)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := s.findEntryBucket(tx, parentBucketName, key)
+		if err != nil {
+			return err
+		}
+		v := b.Get(key)
+		old, err := decodeEntry(key, v, s.loc)
+		if err != nil {
+			return err
+		}
+		record, err := encodeTrashRecord(parentBucketName, key, v, deletedAt)
+		if err != nil {
+			return err
+		}
+		trash, err := tx.CreateBucketIfNotExists([]byte(trashBucketName))
+		if err != nil {
+			return err
+		}
+		trashParent, err := trash.CreateBucketIfNotExists([]byte(parentBucketName))
+		if err != nil {
+			return err
+		}
+		if err := trashParent.Put(key, record); err != nil {
+			return err
+		}
+		if err := b.Delete(key); err != nil {
+			return err
+		}
+		return unindexEntry(tx, parentBucketName, key, old)
+	})
+}
+
+func (s *boltStore) Untrash(parentBucketName string, id string) (entry, error) {
+	key, err := parseID(id)
+	if err != nil {
+		return entry{}, err
+	}
+	var result entry
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		var trashParent *bolt.Bucket
+		if trash := tx.Bucket([]byte(trashBucketName)); trash != nil {
+			trashParent = trash.Bucket([]byte(parentBucketName))
+		}
+		if trashParent == nil || trashParent.Get(key) == nil {
+			return trashedEntryNotFoundError(parentBucketName, id)
+		}
+		r, err := decodeTrashRecord(trashParent.Get(key))
+		if err != nil {
+			return err
+		}
+		if _, err := s.findEntryBucket(tx, parentBucketName, key); err == nil {
+			return didErrorf("an entry with id %s already exists in bucket %s", id, parentBucketName)
+		}
+		result, err = decodeEntry(key, r.Value, s.loc)
+		if err != nil {
+			return err
+		}
+		bucketName, err := getBucketFromEntry(result, s.loc)
+		if err != nil {
+			return err
+		}
+		parentBucket, err := createParentBucket(tx, parentBucketName)
+		if err != nil {
+			return err
+		}
+		b, err := parentBucket.CreateBucketIfNotExists([]byte(bucketName))
+		if err != nil {
+			return err
+		}
+		// the entries logged from now on mustn't get the sequence number of the restored one
+		if seq := keySequence(key); b.Sequence() < seq {
+			if err := b.SetSequence(seq); err != nil {
+				return err
+			}
+		}
+		if err := b.Put(key, r.Value); err != nil {
+			return err
+		}
+		if err := trashParent.Delete(key); err != nil {
+			return err
+		}
+		return indexEntry(tx, parentBucketName, key, result)
+	})
+	if err != nil {
+		return entry{}, err
+	}
+	return result, nil
+}
+
+func (s *boltStore) ListTrash() ([]trashedEntry, error) {
+	result := make([]trashedEntry, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		trash := tx.Bucket([]byte(trashBucketName))
+		if trash == nil {
+			return nil
+		}
+		return trash.ForEach(func(parentBucketName, _ []byte) error {
+			return trash.Bucket(parentBucketName).ForEach(func(_, v []byte) error {
+				r, err := decodeTrashRecord(v)
+				if err != nil {
+					return err
+				}
+				t, err := r.trashed(s.loc)
+				if err != nil {
+					return err
+				}
+				result = append(result, t)
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortTrash(result)
+	return result, nil
+}
+
+func (s *boltStore) EmptyTrash() (int, error) {
+	count := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		trash := tx.Bucket([]byte(trashBucketName))
+		if trash == nil {
+			return nil
+		}
+		err := trash.ForEach(func(parentBucketName, _ []byte) error {
+			count += trash.Bucket(parentBucketName).Stats().KeyN
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return tx.DeleteBucket([]byte(trashBucketName))
+	})
+	return count, err
+}
+
+// SetLastChange keeps the change in the meta bucket, along with the schema version
+func (s *boltStore) SetLastChange(c *change) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists([]byte(metaBucketName))
+		if err != nil {
+			return err
+		}
+		if c == nil {
+			return meta.Delete([]byte(lastChangeKey))
+		}
+		v, err := json.Marshal(c)
+		if err != nil {
+			return err
+		}
+		return meta.Put([]byte(lastChangeKey), v)
+	})
+}
+
+func (s *boltStore) LastChange() (*change, error) {
+	var result *change
+	err := s.db.View(func(tx *bolt.Tx) error {
+		meta := tx.Bucket([]byte(metaBucketName))
+		if meta == nil {
+			return nil
+		}
+		v := meta.Get([]byte(lastChangeKey))
+		if v == nil {
+			return nil
+		}
+		result = &change{}
+		return json.Unmarshal(v, result)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
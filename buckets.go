@@ -0,0 +1,90 @@
+package godid
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// bucketPathSeparator splits the path of the parent buckets nested in others, e.g. work/payments/refunds
+const bucketPathSeparator = "/"
+
+// checkBucketPath makes sure the parent bucket path is made of non empty names and isn't reserved
+func checkBucketPath(parentBucketName string) error {
+	if isReservedBucket([]byte(parentBucketName)) {
+		return didErrorf("bucket names starting with %s are reserved", reservedBucketPrefix)
+	}
+	for _, segment := range strings.Split(parentBucketName, bucketPathSeparator) {
+		if segment == "" {
+			return didErrorf("invalid bucket name %q", parentBucketName)
+		}
+	}
+	return nil
+}
+
+// cutBucketPath splits the path of a parent bucket into its parent and its own name, nested telling whether there's a
+// parent
+func cutBucketPath(parentBucketName string) (parent, name string, nested bool) {
+	i := strings.LastIndex(parentBucketName, bucketPathSeparator)
+	if i < 0 {
+		return "", parentBucketName, false
+	}
+	return parentBucketName[:i], parentBucketName[i+1:], true
+}
+
+// isInBucket tells whether the parent bucket is bucket or one of its descendants
+func isInBucket(parentBucketName, bucket string) bool {
+	return parentBucketName == bucket || strings.HasPrefix(parentBucketName, bucket+bucketPathSeparator)
+}
+
+// nearestBucket returns the most specific of the buckets holding the parent bucket, ok telling whether any does
+func nearestBucket(parentBucketName string, buckets []string) (nearest string, ok bool) {
+	for _, bucket := range buckets {
+		if isInBucket(parentBucketName, bucket) && (!ok || len(bucket) > len(nearest)) {
+			nearest, ok = bucket, true
+		}
+	}
+	return nearest, ok
+}
+
+// rebaseBucket returns the path the parent bucket gets when the bucket it's in moves from oldName to newName
+func rebaseBucket(parentBucketName, oldName, newName string) string {
+	return newName + strings.TrimPrefix(parentBucketName, oldName)
+}
+
+// addEntry counts an entry logged at t into the stats
+func (b *BucketStats) addEntry(t time.Time) {
+	if b.Entries == 0 || t.Before(b.First) {
+		b.First = t
+	}
+	if b.Entries == 0 || t.After(b.Last) {
+		b.Last = t
+	}
+	b.Entries++
+}
+
+// renameRecord moves a trash or revision record to another parent bucket, leaving the rest of it untouched
+func renameRecord(v []byte, parentBucketName string) ([]byte, error) {
+	return rekeyRecord(v, parentBucketName, "")
+}
+
+// rekeyRecord moves a trash or revision record to another parent bucket under a new id, the one of its entry being
+// taken there. An empty id leaves the id alone.
+func rekeyRecord(v []byte, parentBucketName, id string) ([]byte, error) {
+	var record map[string]json.RawMessage
+	if err := json.Unmarshal(v, &record); err != nil {
+		return nil, err
+	}
+	fields := map[string]string{"bucket": parentBucketName}
+	if id != "" {
+		fields["id"] = id
+	}
+	for field, value := range fields {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		record[field] = raw
+	}
+	return json.Marshal(record)
+}
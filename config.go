@@ -3,6 +3,9 @@ package godid
 import (
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/go-yaml/yaml"
 	homedir "github.com/mitchellh/go-homedir"
@@ -11,16 +14,198 @@ import (
 const (
 	workDir    = "~/.godid/"
 	configPath = workDir + "config.yml"
+
+	backendBolt   = "bolt"
+	backendSQLite = "sqlite"
+	backendMemory = "memory"
+	// backendMarkdown keeps a journal of Markdown files in the directory at the store path
+	backendMarkdown = "markdown"
+
+	defaultLockTimeout = 10 * time.Second
 )
 
+// defaultWorkDays are the working days when the config doesn't set them
+var defaultWorkDays = []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}
+
 type config struct {
 	StorePath string `yaml:"store_path"`
+	// Backend selects the entryStore implementation, bolt when empty
+	Backend string `yaml:"backend,omitempty"`
+	// HomeTimezone is the IANA name of the zone deciding day buckets and week boundaries, the local zone when empty
+	HomeTimezone string `yaml:"home_timezone,omitempty"`
+	// LockTimeout is how long to wait for another process to release the store, as a duration, 10s when empty
+	LockTimeout string `yaml:"lock_timeout,omitempty"`
+	// SocketPath is where the daemon listens, ~/.godid/did.sock when empty
+	SocketPath string `yaml:"socket_path,omitempty"`
+	// WeekStart is the name of the first day of the weeks, e.g. sunday, monday when empty
+	WeekStart string `yaml:"week_start,omitempty"`
+	// WorkDays are the names of the working days, e.g. [sunday, monday, tuesday, wednesday, thursday], monday through
+	// friday when empty
+	WorkDays []string `yaml:"work_days,omitempty"`
+	// Backup enables the automatic backups when set
+	Backup *backupConfig `yaml:"backup,omitempty"`
+	// Retention sets how long the entries of the parent buckets are kept, forever when empty
+	Retention *retentionConfig `yaml:"retention,omitempty"`
 }
 
 func (c *config) GetStorePath() (string, error) {
 	return homedir.Expand(c.StorePath)
 }
 
+func (c *config) GetLocation() (*time.Location, error) {
+	if c.HomeTimezone == "" {
+		return time.Local, nil
+	}
+	return loadLocation(c.HomeTimezone)
+}
+
+func (c *config) GetLockTimeout() (time.Duration, error) {
+	if c.LockTimeout == "" {
+		return defaultLockTimeout, nil
+	}
+	timeout, err := time.ParseDuration(c.LockTimeout)
+	if err != nil || timeout < 0 {
+		return 0, didErrorf("invalid lock timeout %s", c.LockTimeout)
+	}
+	return timeout, nil
+}
+
+// GetArchivePath returns where the archived entries are stored, next to the store unless the retention config says
+// otherwise, e.g. ~/.godid/store.archive.db for ~/.godid/store.db
+func (c *config) GetArchivePath() (string, error) {
+	if c.Retention != nil && c.Retention.Archive != "" {
+		return homedir.Expand(c.Retention.Archive)
+	}
+	storePath, err := c.GetStorePath()
+	if err != nil {
+		return "", err
+	}
+	ext := filepath.Ext(storePath)
+	return strings.TrimSuffix(storePath, ext) + ".archive" + ext, nil
+}
+
+func (c *config) GetWeekStart() (time.Weekday, error) {
+	if c.WeekStart == "" {
+		return time.Monday, nil
+	}
+	day, ok := parseWeekday(c.WeekStart)
+	if !ok {
+		return 0, didErrorf("invalid week start %s", c.WeekStart)
+	}
+	return day, nil
+}
+
+func (c *config) GetWorkDays() ([]time.Weekday, error) {
+	if len(c.WorkDays) == 0 {
+		return defaultWorkDays, nil
+	}
+	result := make([]time.Weekday, 0, len(c.WorkDays))
+	for _, name := range c.WorkDays {
+		day, ok := parseWeekday(name)
+		if !ok {
+			return nil, didErrorf("invalid work day %s", name)
+		}
+		result = append(result, day)
+	}
+	return result, nil
+}
+
+func (c *config) GetSocketPath() (string, error) {
+	if c.SocketPath == "" {
+		return homedir.Expand(workDir + "did.sock")
+	}
+	return homedir.Expand(c.SocketPath)
+}
+
+// backupConfig describes the automatic backups, taken when did runs and the newest backup is older than Every
+type backupConfig struct {
+	// Dir holds the backups, ~/.godid/backups when empty
+	Dir string `yaml:"dir,omitempty"`
+	// Every is the interval between two backups as a duration, e.g. 12h, a day when empty
+	Every string `yaml:"every,omitempty"`
+	// Keep is the number of backups kept, all of them when 0
+	Keep int `yaml:"keep,omitempty"`
+}
+
+func (c backupConfig) GetDir() (string, error) {
+	if c.Dir == "" {
+		return homedir.Expand(workDir + "backups")
+	}
+	return homedir.Expand(c.Dir)
+}
+
+func (c backupConfig) GetEvery() (time.Duration, error) {
+	if c.Every == "" {
+		return 24 * time.Hour, nil
+	}
+	every, err := time.ParseDuration(c.Every)
+	if err != nil || every <= 0 {
+		return 0, didErrorf("invalid backup interval %s", c.Every)
+	}
+	return every, nil
+}
+
+// retentionConfig describes how long the entries of each parent bucket are kept, see ApplyRetention
+type retentionConfig struct {
+	// Archive is the store holding the archived entries, of the same backend as the store. It's next to the store when
+	// empty, see GetArchivePath.
+	Archive string `yaml:"archive,omitempty"`
+	// Buckets maps the parent bucket names to their policy, which also covers the buckets nested in them unless they
+	// have their own. The entries of the other buckets are kept forever.
+	Buckets map[string]retentionPolicy `yaml:"buckets,omitempty"`
+}
+
+// retentionPolicy is how long the entries of a parent bucket are kept and what happens to them afterwards
+type retentionPolicy struct {
+	// Keep is how long the entries are kept, e.g. 90d
+	Keep string `yaml:"keep"`
+	// Action is either archive, the default, moving the older entries to the archive, or delete
+	Action string `yaml:"action,omitempty"`
+}
+
+const (
+	retentionArchive = "archive"
+	retentionDelete  = "delete"
+)
+
+func (p retentionPolicy) GetKeep() (calendarDuration, error) {
+	keep, err := parseDuration(p.Keep)
+	if err != nil {
+		return calendarDuration{}, didErrorf("invalid retention period %s", p.Keep)
+	}
+	return keep, nil
+}
+
+func (p retentionPolicy) GetAction() (string, error) {
+	switch p.Action {
+	case "", retentionArchive:
+		return retentionArchive, nil
+	case retentionDelete:
+		return retentionDelete, nil
+	default:
+		return "", didErrorf("invalid retention action %s, it must be archive or delete", p.Action)
+	}
+}
+
+// parseWeekday parses the name of a weekday, in full or its first three letters, e.g. sunday or Sun
+func parseWeekday(name string) (time.Weekday, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for fullName, day := range weekdays {
+		if name == fullName || (len(name) == 3 && strings.HasPrefix(fullName, name)) {
+			return day, true
+		}
+	}
+	return 0, false
+}
+
+func loadLocation(name string) (*time.Location, error) {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, didErrorf("unknown timezone %s", name)
+	}
+	return loc, nil
+}
+
 var (
 	defaultConfig = config{
 		StorePath: workDir + "store.db",
@@ -0,0 +1,463 @@
+package godid
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// The daemon speaks a request/response protocol over its unix socket: every request is a gob encoded daemonRequest
+// answered by a daemonResponse, one at a time per connection.
+const (
+	methodPut     = "put"
+	methodGet     = "get"
+	methodUpdate  = "update"
+	methodDelete  = "delete"
+	methodRange   = "range"
+	methodScan    = "scan"
+	methodSearch  = "search"
+	methodBackup  = "backup"
+	methodRestore = "restore"
+	methodMigrate = "migrate"
+	methodCheck   = "check"
+
+	methodTrash         = "trash"
+	methodUntrash       = "untrash"
+	methodListTrash     = "listTrash"
+	methodEmptyTrash    = "emptyTrash"
+	methodSetLastChange = "setLastChange"
+	methodLastChange    = "lastChange"
+	methodHistory       = "history"
+	methodBuckets       = "buckets"
+	methodRenameBucket  = "renameBucket"
+	methodChildBuckets  = "childBuckets"
+	methodMergeBucket   = "mergeBucket"
+)
+
+// daemonRequest holds the arguments of all the methods, only the ones of Method being set
+type daemonRequest struct {
+	Method string
+	Bucket string
+	ID     string
+	Entry  wireEntry
+	Start  time.Time
+	End    time.Time
+	Query  [][]wireWord
+	Filter SearchFilter
+	Path   string
+	DryRun bool
+	Repair bool
+	// DeletedAt is the time of a trash
+	DeletedAt time.Time
+	Change    *change
+	// NewBucket is the new name of the renamed Bucket, or the one it's merged into
+	NewBucket string
+}
+
+type daemonResponse struct {
+	// Err is the message of the error returned by the store, DidErr telling whether it's meant for the user
+	Err    string
+	DidErr bool
+	// ID is the id of the entry added by a put
+	ID      string
+	Entries []wireEntry
+	// Buckets holds the parent bucket of each of the Entries of a scan or trash listing, or the nested buckets
+	Buckets []string
+	// Times holds the time each of the Entries was deleted, for a trash listing, or replaced, for a history
+	Times      []time.Time
+	Hits       []wireHit
+	Migrations []MigrationResult
+	Problems   []CheckProblem
+	// Count is the number of entries removed by emptying the trash, or moved by merging buckets
+	Count       int
+	Change      *change
+	BucketStats []BucketStats
+}
+
+func (r daemonResponse) err() error {
+	switch {
+	case r.Err == "":
+		return nil
+	case r.DidErr:
+		return DidError{message: r.Err}
+	default:
+		return errors.New(r.Err)
+	}
+}
+
+func (r *daemonResponse) setErr(err error) {
+	if err == nil {
+		return
+	}
+	r.Err = err.Error()
+	_, r.DidErr = err.(DidError)
+}
+
+// wireEntry carries an entry as its stored value, which keeps the zone it was logged in
+type wireEntry struct {
+	ID        string
+	Timestamp time.Time
+	Value     []byte
+}
+
+func toWireEntry(e entry) (wireEntry, error) {
+	v, err := encodeValue(e)
+	if err != nil {
+		return wireEntry{}, err
+	}
+	return wireEntry{ID: e.ID, Timestamp: e.Timestamp, Value: v}, nil
+}
+
+// entry rebuilds the entry, home being the zone of the legacy values, like for the stores
+func (w wireEntry) entry(home *time.Location) (entry, error) {
+	p, zone, err := decodeValue(w.Value)
+	if err != nil {
+		return entry{}, err
+	}
+	return entry{
+		ID:        w.ID,
+		Timestamp: inZone(w.Timestamp, zone, home),
+		Content:   []byte(p.Content),
+		Metadata:  p.Metadata,
+	}, nil
+}
+
+type wireWord struct {
+	Text   string
+	Prefix bool
+}
+
+type wireHit struct {
+	Bucket string
+	Entry  wireEntry
+	Score  float64
+}
+
+func toWireQuery(q searchQuery) [][]wireWord {
+	result := make([][]wireWord, len(q))
+	for i, c := range q {
+		for _, w := range c {
+			result[i] = append(result[i], wireWord{Text: w.text, Prefix: w.prefix})
+		}
+	}
+	return result
+}
+
+func fromWireQuery(q [][]wireWord) searchQuery {
+	result := make(searchQuery, len(q))
+	for i, c := range q {
+		for _, w := range c {
+			result[i] = append(result[i], searchWord{text: w.Text, prefix: w.Prefix})
+		}
+	}
+	return result
+}
+
+// Serve shares the open store with the other did processes through the daemon socket, until ctx is done
+func Serve(ctx context.Context) error {
+	if _, ok := store.(*remoteStore); ok {
+		return didErrorf("a did daemon is already running")
+	}
+	cfg, err := getConfig()
+	if err != nil {
+		return err
+	}
+	socketPath, err := cfg.GetSocketPath()
+	if err != nil {
+		return err
+	}
+	home, err := cfg.GetLocation()
+	if err != nil {
+		return err
+	}
+	// the store couldn't be reached through the socket when opened, so it's left over by a daemon that crashed
+	if err := os.Remove(socketPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(socketPath)
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		l.Close()
+		return err
+	}
+	getLogger().WithFields(logrus.Fields{
+		"component": "daemon",
+		"socket":    socketPath,
+	}).Info("serving the store")
+	return newDaemon(store, home).serve(ctx, l)
+}
+
+// daemon serves a store to the connections of a listener
+type daemon struct {
+	store entryStore
+	// loc is the home location of the config, the zone of the legacy entries
+	loc *time.Location
+	// mu lets the requests run side by side, apart from the ones replacing or rewriting the whole store
+	mu sync.RWMutex
+
+	connsMu sync.Mutex
+	conns   map[net.Conn]struct{}
+	wg      sync.WaitGroup
+}
+
+func newDaemon(s entryStore, loc *time.Location) *daemon {
+	return &daemon{
+		store: s,
+		loc:   loc,
+		conns: make(map[net.Conn]struct{}),
+	}
+}
+
+// serve handles the connections of l until ctx is done, then waits for the requests being handled
+func (d *daemon) serve(ctx context.Context, l net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		l.Close()
+		d.connsMu.Lock()
+		for conn := range d.conns {
+			conn.Close()
+		}
+		d.connsMu.Unlock()
+	}()
+	defer d.wg.Wait()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		d.connsMu.Lock()
+		d.conns[conn] = struct{}{}
+		d.connsMu.Unlock()
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			d.serveConn(conn)
+			d.connsMu.Lock()
+			delete(d.conns, conn)
+			d.connsMu.Unlock()
+		}()
+	}
+}
+
+func (d *daemon) serveConn(conn net.Conn) {
+	defer conn.Close()
+	logger := getLogger().WithFields(logrus.Fields{
+		"component": "daemon",
+		"method":    "serveConn",
+	})
+	dec := gob.NewDecoder(conn)
+	enc := gob.NewEncoder(conn)
+	for {
+		var req daemonRequest
+		if err := dec.Decode(&req); err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				logger.WithError(err).Error("failed to read request")
+			}
+			return
+		}
+		if err := enc.Encode(d.handle(req)); err != nil {
+			logger.WithError(err).Error("failed to write response")
+			return
+		}
+	}
+}
+
+func (d *daemon) handle(req daemonRequest) daemonResponse {
+	exclusive := req.Method == methodRestore || (req.Method == methodMigrate && !req.DryRun) ||
+		(req.Method == methodCheck && req.Repair)
+	if exclusive {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+	} else {
+		d.mu.RLock()
+		defer d.mu.RUnlock()
+	}
+	var resp daemonResponse
+	resp.setErr(d.dispatch(req, &resp))
+	return resp
+}
+
+func (d *daemon) dispatch(req daemonRequest, resp *daemonResponse) error {
+	switch req.Method {
+	case methodPut, methodUpdate:
+		e, err := req.Entry.entry(d.loc)
+		if err != nil {
+			return err
+		}
+		if req.Method == methodPut {
+			resp.ID, err = d.store.Put(req.Bucket, e)
+			return err
+		}
+		return d.store.Update(req.Bucket, e)
+	case methodGet:
+		e, err := d.store.Get(req.Bucket, req.ID)
+		if err != nil {
+			return err
+		}
+		return resp.addEntries([]entry{e})
+	case methodDelete:
+		return d.store.Delete(req.Bucket, req.ID)
+	case methodRange:
+		entries, err := d.store.GetRange(req.Bucket, req.Start, req.End)
+		if err != nil {
+			return err
+		}
+		return resp.addEntries(entries)
+	case methodScan:
+		return d.store.ForEach(func(parentBucketName string, e entry) error {
+			resp.Buckets = append(resp.Buckets, parentBucketName)
+			return resp.addEntries([]entry{e})
+		})
+	case methodSearch:
+		s, ok := d.store.(searcher)
+		if !ok {
+			return didErrorf("the store doesn't support searching")
+		}
+		hits, err := s.Search(fromWireQuery(req.Query), req.Filter)
+		if err != nil {
+			return err
+		}
+		for _, hit := range hits {
+			w, err := toWireEntry(hit.entry)
+			if err != nil {
+				return err
+			}
+			resp.Hits = append(resp.Hits, wireHit{Bucket: hit.parentBucketName, Entry: w, Score: hit.score})
+		}
+		return nil
+	case methodBackup, methodRestore:
+		b, ok := d.store.(backuper)
+		if !ok {
+			return didErrorf("the store doesn't support backups")
+		}
+		if req.Method == methodBackup {
+			return b.Backup(req.Path)
+		}
+		return b.Restore(req.Path)
+	case methodMigrate:
+		m, ok := d.store.(migrator)
+		if !ok {
+			return nil
+		}
+		results, err := m.Migrate(req.DryRun)
+		resp.Migrations = results
+		return err
+	case methodCheck:
+		c, ok := d.store.(checker)
+		if !ok {
+			return didErrorf("the store doesn't support checking")
+		}
+		problems, err := c.Check(req.Repair)
+		resp.Problems = problems
+		return err
+	case methodTrash, methodUntrash, methodListTrash, methodEmptyTrash:
+		return d.dispatchTrash(req, resp)
+	case methodHistory:
+		h, ok := d.store.(historian)
+		if !ok {
+			return didErrorf("the store doesn't keep the history of the entries")
+		}
+		revisions, err := h.History(req.Bucket, req.ID)
+		if err != nil {
+			return err
+		}
+		for _, r := range revisions {
+			resp.Times = append(resp.Times, r.replacedAt)
+			if err := resp.addEntries([]entry{r.entry}); err != nil {
+				return err
+			}
+		}
+		return nil
+	case methodBuckets, methodRenameBucket, methodChildBuckets, methodMergeBucket:
+		m, ok := d.store.(bucketManager)
+		if !ok {
+			return didErrorf("the store doesn't support managing buckets")
+		}
+		switch req.Method {
+		case methodRenameBucket:
+			return m.RenameBucket(req.Bucket, req.NewBucket)
+		case methodMergeBucket:
+			count, err := m.MergeBucket(req.Bucket, req.NewBucket)
+			resp.Count = count
+			return err
+		case methodChildBuckets:
+			children, err := m.ChildBuckets(req.Bucket)
+			resp.Buckets = children
+			return err
+		}
+		stats, err := m.Buckets()
+		resp.BucketStats = stats
+		return err
+	case methodSetLastChange, methodLastChange:
+		u, ok := d.store.(undoer)
+		if !ok {
+			return didErrorf("the store doesn't support undo")
+		}
+		if req.Method == methodSetLastChange {
+			return u.SetLastChange(req.Change)
+		}
+		c, err := u.LastChange()
+		resp.Change = c
+		return err
+	default:
+		return didErrorf("unknown daemon method %s", req.Method)
+	}
+}
+
+func (d *daemon) dispatchTrash(req daemonRequest, resp *daemonResponse) error {
+	t, ok := d.store.(trasher)
+	if !ok {
+		return didErrorf("the store doesn't support the trash")
+	}
+	switch req.Method {
+	case methodTrash:
+		return t.Trash(req.Bucket, req.ID, req.DeletedAt)
+	case methodUntrash:
+		e, err := t.Untrash(req.Bucket, req.ID)
+		if err != nil {
+			return err
+		}
+		return resp.addEntries([]entry{e})
+	case methodListTrash:
+		trashed, err := t.ListTrash()
+		if err != nil {
+			return err
+		}
+		for _, te := range trashed {
+			resp.Buckets = append(resp.Buckets, te.parentBucketName)
+			resp.Times = append(resp.Times, te.deletedAt)
+			if err := resp.addEntries([]entry{te.entry}); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		count, err := t.EmptyTrash()
+		resp.Count = count
+		return err
+	}
+}
+
+func (r *daemonResponse) addEntries(entries []entry) error {
+	for _, e := range entries {
+		w, err := toWireEntry(e)
+		if err != nil {
+			return err
+		}
+		r.Entries = append(r.Entries, w)
+	}
+	return nil
+}
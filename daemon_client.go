@@ -0,0 +1,304 @@
+package godid
+
+import (
+	"encoding/gob"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// remoteStore is an entryStore forwarding everything to a did daemon
+type remoteStore struct {
+	// mu keeps the requests of the goroutines sharing the store from interleaving on the connection
+	mu   sync.Mutex
+	conn net.Conn
+	enc  *gob.Encoder
+	dec  *gob.Decoder
+	// loc is the home location of the config, the zone of the legacy entries
+	loc *time.Location
+}
+
+// dialDaemon connects to the daemon listening on the socket of the config, failing with fs.ErrNotExist when there's
+// no socket
+func dialDaemon(cfg config) (*remoteStore, error) {
+	path, err := cfg.GetSocketPath()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	timeout, err := cfg.GetLockTimeout()
+	if err != nil {
+		return nil, err
+	}
+	loc, err := cfg.GetLocation()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.DialTimeout("unix", path, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return newRemoteStore(conn, loc), nil
+}
+
+func newRemoteStore(conn net.Conn, loc *time.Location) *remoteStore {
+	return &remoteStore{
+		conn: conn,
+		enc:  gob.NewEncoder(conn),
+		dec:  gob.NewDecoder(conn),
+		loc:  loc,
+	}
+}
+
+func (s *remoteStore) call(req daemonRequest) (daemonResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var resp daemonResponse
+	if err := s.enc.Encode(req); err != nil {
+		return resp, err
+	}
+	if err := s.dec.Decode(&resp); err != nil {
+		return resp, err
+	}
+	return resp, resp.err()
+}
+
+func (s *remoteStore) Put(parentBucketName string, e entry) (string, error) {
+	w, err := toWireEntry(e)
+	if err != nil {
+		return "", err
+	}
+	resp, err := s.call(daemonRequest{Method: methodPut, Bucket: parentBucketName, Entry: w})
+	if err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+func (s *remoteStore) Get(parentBucketName string, id string) (entry, error) {
+	resp, err := s.call(daemonRequest{Method: methodGet, Bucket: parentBucketName, ID: id})
+	if err != nil {
+		return entry{}, err
+	}
+	if len(resp.Entries) != 1 {
+		return entry{}, errors.New("invalid daemon response")
+	}
+	return resp.Entries[0].entry(s.loc)
+}
+
+func (s *remoteStore) Update(parentBucketName string, e entry) error {
+	w, err := toWireEntry(e)
+	if err != nil {
+		return err
+	}
+	_, err = s.call(daemonRequest{Method: methodUpdate, Bucket: parentBucketName, Entry: w})
+	return err
+}
+
+func (s *remoteStore) Delete(parentBucketName string, id string) error {
+	_, err := s.call(daemonRequest{Method: methodDelete, Bucket: parentBucketName, ID: id})
+	return err
+}
+
+func (s *remoteStore) GetRange(parentBucketName string, start, end time.Time) ([]entry, error) {
+	resp, err := s.call(daemonRequest{Method: methodRange, Bucket: parentBucketName, Start: start, End: end})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]entry, 0, len(resp.Entries))
+	for _, w := range resp.Entries {
+		e, err := w.entry(s.loc)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+func (s *remoteStore) GetRangeWithAggregation(parentBucketName string, start, end time.Time, agg aggregationFunction) (any, error) {
+	if agg == nil {
+		return nil, errors.New("aggregation function is nil")
+	}
+	entries, err := s.GetRange(parentBucketName, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return agg(entries)
+}
+
+func (s *remoteStore) ForEach(fn func(parentBucketName string, e entry) error) error {
+	resp, err := s.call(daemonRequest{Method: methodScan})
+	if err != nil {
+		return err
+	}
+	if len(resp.Buckets) != len(resp.Entries) {
+		return errors.New("invalid daemon response")
+	}
+	for i, w := range resp.Entries {
+		e, err := w.entry(s.loc)
+		if err != nil {
+			return err
+		}
+		if err := fn(resp.Buckets[i], e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *remoteStore) Search(q searchQuery, f SearchFilter) ([]searchHit, error) {
+	resp, err := s.call(daemonRequest{Method: methodSearch, Query: toWireQuery(q), Filter: f})
+	if err != nil {
+		return nil, err
+	}
+	result := make([]searchHit, 0, len(resp.Hits))
+	for _, hit := range resp.Hits {
+		e, err := hit.Entry.entry(s.loc)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, searchHit{parentBucketName: hit.Bucket, entry: e, score: hit.Score})
+	}
+	return result, nil
+}
+
+func (s *remoteStore) Backup(path string) error {
+	// the daemon runs in another directory
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	_, err = s.call(daemonRequest{Method: methodBackup, Path: path})
+	return err
+}
+
+func (s *remoteStore) Restore(path string) error {
+	// the daemon runs in another directory
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	_, err = s.call(daemonRequest{Method: methodRestore, Path: path})
+	return err
+}
+
+func (s *remoteStore) Migrate(dryRun bool) ([]MigrationResult, error) {
+	resp, err := s.call(daemonRequest{Method: methodMigrate, DryRun: dryRun})
+	if resp.Migrations == nil {
+		resp.Migrations = []MigrationResult{}
+	}
+	return resp.Migrations, err
+}
+
+func (s *remoteStore) Check(repair bool) ([]CheckProblem, error) {
+	resp, err := s.call(daemonRequest{Method: methodCheck, Repair: repair})
+	if resp.Problems == nil {
+		resp.Problems = []CheckProblem{}
+	}
+	return resp.Problems, err
+}
+
+func (s *remoteStore) Trash(parentBucketName string, id string, deletedAt time.Time) error {
+	_, err := s.call(daemonRequest{Method: methodTrash, Bucket: parentBucketName, ID: id, DeletedAt: deletedAt})
+	return err
+}
+
+func (s *remoteStore) Untrash(parentBucketName string, id string) (entry, error) {
+	resp, err := s.call(daemonRequest{Method: methodUntrash, Bucket: parentBucketName, ID: id})
+	if err != nil {
+		return entry{}, err
+	}
+	if len(resp.Entries) != 1 {
+		return entry{}, errors.New("invalid daemon response")
+	}
+	return resp.Entries[0].entry(s.loc)
+}
+
+func (s *remoteStore) ListTrash() ([]trashedEntry, error) {
+	resp, err := s.call(daemonRequest{Method: methodListTrash})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Buckets) != len(resp.Entries) || len(resp.Times) != len(resp.Entries) {
+		return nil, errors.New("invalid daemon response")
+	}
+	result := make([]trashedEntry, 0, len(resp.Entries))
+	for i, w := range resp.Entries {
+		e, err := w.entry(s.loc)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, trashedEntry{parentBucketName: resp.Buckets[i], entry: e, deletedAt: resp.Times[i]})
+	}
+	return result, nil
+}
+
+func (s *remoteStore) EmptyTrash() (int, error) {
+	resp, err := s.call(daemonRequest{Method: methodEmptyTrash})
+	return resp.Count, err
+}
+
+func (s *remoteStore) History(parentBucketName string, id string) ([]revision, error) {
+	resp, err := s.call(daemonRequest{Method: methodHistory, Bucket: parentBucketName, ID: id})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Times) != len(resp.Entries) {
+		return nil, errors.New("invalid daemon response")
+	}
+	result := make([]revision, 0, len(resp.Entries))
+	for i, w := range resp.Entries {
+		e, err := w.entry(s.loc)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, revision{entry: e, replacedAt: resp.Times[i]})
+	}
+	return result, nil
+}
+
+func (s *remoteStore) SetLastChange(c *change) error {
+	_, err := s.call(daemonRequest{Method: methodSetLastChange, Change: c})
+	return err
+}
+
+func (s *remoteStore) LastChange() (*change, error) {
+	resp, err := s.call(daemonRequest{Method: methodLastChange})
+	return resp.Change, err
+}
+
+func (s *remoteStore) Buckets() ([]BucketStats, error) {
+	resp, err := s.call(daemonRequest{Method: methodBuckets})
+	if resp.BucketStats == nil {
+		resp.BucketStats = []BucketStats{}
+	}
+	return resp.BucketStats, err
+}
+
+func (s *remoteStore) ChildBuckets(parentBucketName string) ([]string, error) {
+	resp, err := s.call(daemonRequest{Method: methodChildBuckets, Bucket: parentBucketName})
+	if resp.Buckets == nil {
+		resp.Buckets = []string{}
+	}
+	return resp.Buckets, err
+}
+
+func (s *remoteStore) RenameBucket(oldName, newName string) error {
+	_, err := s.call(daemonRequest{Method: methodRenameBucket, Bucket: oldName, NewBucket: newName})
+	return err
+}
+
+func (s *remoteStore) MergeBucket(src, dst string) (int, error) {
+	resp, err := s.call(daemonRequest{Method: methodMergeBucket, Bucket: src, NewBucket: dst})
+	return resp.Count, err
+}
+
+func (s *remoteStore) Close() error {
+	return s.conn.Close()
+}
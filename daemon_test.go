@@ -0,0 +1,111 @@
+package godid
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type remoteTestSuite struct {
+	storeTestSuite
+}
+
+func (s *remoteTestSuite) TestErrors() {
+	_, err := s.store.Get(s.testBucketName, "abc-1")
+	s.IsType(DidError{}, err, "the errors meant for the user should stay so")
+	_, err = s.store.GetRange(s.testBucketName, time.Time{}, time.Time{})
+	s.Error(err)
+}
+
+func (s *remoteTestSuite) TestZone() {
+	ts := time.Date(2018, 7, 18, 12, 11, 0, 0, time.FixedZone("JST", 9*60*60))
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("msg1")})
+	entries, err := s.store.GetRange(s.testBucketName, ts, ts)
+	s.Require().NoError(err)
+	s.Require().Len(entries, 1)
+	name, offset := entries[0].Timestamp.Zone()
+	s.Equal("JST", name)
+	s.Equal(9*60*60, offset)
+}
+
+func TestRemoteStore(t *testing.T) {
+	suite.Run(t, &remoteTestSuite{
+		storeTestSuite: storeTestSuite{
+			newStore: func(cfg config) entryStore {
+				return getTestRemoteStore(t, cfg)
+			},
+			cleanup: cleanupTestRemoteStore,
+		},
+	})
+}
+
+func TestNewStoreThroughDaemon(t *testing.T) {
+	defer cleanupTestRemoteStore()
+	cfg := config{StorePath: "test.db", SocketPath: "test.sock"}
+
+	served := getTestRemoteStore(t, cfg)
+	s, err := newStore(cfg, Options{})
+	require.NoError(t, err)
+	assert.IsType(t, &remoteStore{}, s, "the daemon should be used while it runs")
+	require.NoError(t, s.Close())
+	s, err = newStore(cfg, Options{Backend: backendMemory})
+	require.NoError(t, err)
+	assert.IsType(t, &memoryStore{}, s, "a backend picked by the options should skip the daemon")
+	require.NoError(t, s.Close())
+	require.NoError(t, served.Close())
+
+	// a daemon that crashed leaves its socket behind
+	l, err := net.Listen("unix", "test.sock")
+	require.NoError(t, err)
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, l.Close())
+	s, err = newStore(cfg, Options{})
+	require.NoError(t, err)
+	assert.IsType(t, &boltStore{}, s, "the store should be opened directly without a daemon")
+	require.NoError(t, s.Close())
+}
+
+func TestDaemonShutdown(t *testing.T) {
+	defer cleanupTestRemoteStore()
+	served := getTestMemoryStore(t, config{})
+	l, err := net.Listen("unix", "test.sock")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- newDaemon(served, time.Local).serve(ctx, l)
+	}()
+	conn, err := net.Dial("unix", "test.sock")
+	require.NoError(t, err)
+	client := newRemoteStore(conn, time.Local)
+	putEntry(t, client, "bucket", entry{Timestamp: time.Now(), Content: []byte("msg1")})
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the daemon should stop even with clients connected")
+	}
+	_, err = client.Put("bucket", entry{Timestamp: time.Now(), Content: []byte("msg2")})
+	assert.Error(t, err)
+	_, err = os.Stat("test.sock")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestWireEntryLegacyZone(t *testing.T) {
+	home, err := time.LoadLocation("America/Santiago")
+	require.NoError(t, err)
+	ts := timeFromString(t, "2026-10-18T02:30:00Z")
+	e, err := wireEntry{ID: "abc-1", Timestamp: ts, Value: []byte("legacy")}.entry(home)
+	require.NoError(t, err)
+	assert.Equal(t, home, e.Timestamp.Location(), "the legacy entries are in the home location, like in the stores")
+	assert.Equal(t, "2026-10-17", e.Timestamp.Format(dayFormat))
+	assert.Equal(t, "legacy", string(e.Content))
+}
@@ -0,0 +1,227 @@
+package godid
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The date expressions accepted wherever a day is expected, case insensitive:
+//
+//   - today, yesterday, tomorrow
+//   - a date, e.g. 2026-10-01
+//   - a count of days, weeks, months, quarters or years ago, e.g. 3 days ago or a month ago
+//   - a weekday, the latest one up to today, or last and next ones, e.g. last monday
+//   - a period, e.g. this week, last month or next year, standing for all its days
+//   - the start or end of a period, e.g. start of month or end of last quarter
+//   - a quarter, of this year or of the given one, e.g. q3, q3 2025 or 2025-q3
+//
+// The weeks start on the week_start of the config, Monday by default, like the ones of the week queries. Going back
+// months clamps to the end of shorter months, e.g. a month ago on March 31st is the last day of February, like the
+// durations of the last query and of the retention policies do.
+
+var (
+	// calendarPeriods are the periods standing for a span of days
+	calendarPeriods = `(week|month|quarter|year)`
+
+	agoPattern         = regexp.MustCompile(`^(\d+|an?) (day|week|month|quarter|year)s? ago$`)
+	weekdayPattern     = regexp.MustCompile(`^(?:(last|next) )?(monday|tuesday|wednesday|thursday|friday|saturday|sunday)$`)
+	periodPattern      = regexp.MustCompile(`^(this|last|next) ` + calendarPeriods + `$`)
+	periodBoundPattern = regexp.MustCompile(`^(start|beginning|end) of (?:(this|last|next) )?` + calendarPeriods + `$`)
+	quarterPattern     = regexp.MustCompile(`^q([1-4])(?: (\d{4}))?$`)
+	yearQuarterPattern = regexp.MustCompile(`^(\d{4})[ -]q([1-4])$`)
+
+	weekdays = map[string]time.Weekday{
+		"monday":    time.Monday,
+		"tuesday":   time.Tuesday,
+		"wednesday": time.Wednesday,
+		"thursday":  time.Thursday,
+		"friday":    time.Friday,
+		"saturday":  time.Saturday,
+		"sunday":    time.Sunday,
+	}
+	periodOffsets = map[string]int{
+		"":     0,
+		"this": 0,
+		"last": -1,
+		"next": 1,
+	}
+)
+
+// ParseDate returns the start of the first day the date expression stands for, in the timezone of the queries, e.g.
+// the first day of the quarter for q3. See ParseDateSpan.
+func ParseDate(expr string) (time.Time, error) {
+	return ParseDateAt(expr, now())
+}
+
+// ParseDateSpan returns the starts of the first and last days the date expression stands for, in the timezone of the
+// queries. Most expressions stand for a single day, the periods like last month or q3 for all their days.
+func ParseDateSpan(expr string) (time.Time, time.Time, error) {
+	return ParseDateSpanAt(expr, now())
+}
+
+// ParseDateAt is ParseDate relative to the given time rather than to the current one, the day being in the location
+// of now
+func ParseDateAt(expr string, now time.Time) (time.Time, error) {
+	first, _, err := ParseDateSpanAt(expr, now)
+	return first, err
+}
+
+// ParseDateSpanAt is ParseDateSpan relative to the given time rather than to the current one, the days being in the
+// location of now
+func ParseDateSpanAt(expr string, now time.Time) (time.Time, time.Time, error) {
+	normalized := normalizeDateExpr(expr)
+	today := startOfDay(now)
+	day := func(t time.Time) (time.Time, time.Time, error) {
+		return t, t, nil
+	}
+	switch normalized {
+	case "today":
+		return day(today)
+	case "yesterday":
+		return day(addDays(today, -1))
+	case "tomorrow":
+		return day(addDays(today, 1))
+	}
+	if t, err := time.Parse(dayFormat, normalized); err == nil {
+		return day(dayStart(t.Year(), t.Month(), t.Day(), now.Location()))
+	}
+	if match := agoPattern.FindStringSubmatch(normalized); match != nil {
+		count := 1
+		if match[1] != "a" && match[1] != "an" {
+			var err error
+			if count, err = strconv.Atoi(match[1]); err != nil {
+				return time.Time{}, time.Time{}, didErrorf("invalid date %q", expr)
+			}
+		}
+		return day(addPeriods(today, match[2], -count))
+	}
+	if match := weekdayPattern.FindStringSubmatch(normalized); match != nil {
+		return day(weekdayFrom(today, weekdays[match[2]], match[1]))
+	}
+	if match := periodPattern.FindStringSubmatch(normalized); match != nil {
+		first, last := periodSpan(today, match[2], periodOffsets[match[1]])
+		return first, last, nil
+	}
+	if match := periodBoundPattern.FindStringSubmatch(normalized); match != nil {
+		first, last := periodSpan(today, match[3], periodOffsets[match[2]])
+		if match[1] == "end" {
+			return day(last)
+		}
+		return day(first)
+	}
+	if match := quarterPattern.FindStringSubmatch(normalized); match != nil {
+		year := today.Year()
+		if match[2] != "" {
+			year, _ = strconv.Atoi(match[2])
+		}
+		quarter, _ := strconv.Atoi(match[1])
+		first, last := quarterSpan(year, quarter, now.Location())
+		return first, last, nil
+	}
+	if match := yearQuarterPattern.FindStringSubmatch(normalized); match != nil {
+		year, _ := strconv.Atoi(match[1])
+		quarter, _ := strconv.Atoi(match[2])
+		first, last := quarterSpan(year, quarter, now.Location())
+		return first, last, nil
+	}
+	return time.Time{}, time.Time{}, didErrorf("invalid date %q", expr)
+}
+
+// normalizeDateExpr lowercases the expression and squeezes its spaces, leaving out the filler word the
+func normalizeDateExpr(expr string) string {
+	words := make([]string, 0)
+	for _, word := range strings.Fields(strings.ToLower(expr)) {
+		if word != "the" {
+			words = append(words, word)
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// addDays moves the day by count days. The days are built from their date rather than moved by AddDate, which would
+// keep the clock of a day starting late because its midnight was skipped by a DST change.
+func addDays(day time.Time, count int) time.Time {
+	return dayStart(day.Year(), day.Month(), day.Day()+count, day.Location())
+}
+
+// addPeriods moves the day by count periods, clamping to the end of the shorter months
+func addPeriods(day time.Time, period string, count int) time.Time {
+	switch period {
+	case "day":
+		return addDays(day, count)
+	case "week":
+		return addDays(day, 7*count)
+	case "month":
+		return addMonths(day, count)
+	case "quarter":
+		return addMonths(day, 3*count)
+	default:
+		return addMonths(day, 12*count)
+	}
+}
+
+// addMonths moves the day by count months, the days past the end of the target month becoming its last day
+func addMonths(day time.Time, count int) time.Time {
+	year, month, dayOfMonth := clampedDate(day, count)
+	return dayStart(year, month, dayOfMonth, day.Location())
+}
+
+// clampedDate returns the date count months away from the one of t, the days past the end of the target month
+// becoming its last day
+func clampedDate(t time.Time, count int) (int, time.Month, int) {
+	last := lastOfMonth(dayStart(t.Year(), t.Month()+time.Month(count), 1, t.Location()))
+	return last.Year(), last.Month(), min(t.Day(), last.Day())
+}
+
+// lastOfMonth returns the last day of the month of day
+func lastOfMonth(day time.Time) time.Time {
+	return dayStart(day.Year(), day.Month()+1, 0, day.Location())
+}
+
+// weekdayFrom returns the latest weekday up to today, the one before today for last and the one after for next
+func weekdayFrom(today time.Time, weekday time.Weekday, qualifier string) time.Time {
+	diff := int(weekday) - int(today.Weekday())
+	switch qualifier {
+	case "last":
+		if diff >= 0 {
+			diff -= 7
+		}
+	case "next":
+		if diff <= 0 {
+			diff += 7
+		}
+	default:
+		if diff > 0 {
+			diff -= 7
+		}
+	}
+	return addDays(today, diff)
+}
+
+// periodSpan returns the first and last days of the period holding today, moved by offset periods
+func periodSpan(today time.Time, period string, offset int) (time.Time, time.Time) {
+	loc := today.Location()
+	switch period {
+	case "week":
+		first, _ := getWeekInterval(today)
+		first = addDays(first, 7*offset)
+		return first, addDays(first, 6)
+	case "month":
+		first := dayStart(today.Year(), today.Month()+time.Month(offset), 1, loc)
+		return first, lastOfMonth(first)
+	case "quarter":
+		month := (today.Month()-1)/3*3 + 1 + time.Month(3*offset)
+		return dayStart(today.Year(), month, 1, loc), dayStart(today.Year(), month+3, 0, loc)
+	default:
+		first := dayStart(today.Year()+offset, time.January, 1, loc)
+		return first, dayStart(today.Year()+offset, time.December, 31, loc)
+	}
+}
+
+// quarterSpan returns the first and last days of a quarter, numbered from 1
+func quarterSpan(year, quarter int, loc *time.Location) (time.Time, time.Time) {
+	first := dayStart(year, time.Month(3*(quarter-1)+1), 1, loc)
+	return first, dayStart(year, time.Month(3*quarter)+1, 0, loc)
+}
@@ -0,0 +1,136 @@
+package godid
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDateSpan(t *testing.T) {
+	bucharest, err := time.LoadLocation("Europe/Bucharest")
+	require.NoError(t, err)
+	santiago, err := time.LoadLocation("America/Santiago")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name string
+		now  string
+		loc  *time.Location
+		expr string
+		// first and last are the expected starts of the days, their offset telling the DST apart
+		first       string
+		last        string
+		shouldError bool
+	}{
+		// the reference is Sunday, October 18th 2026
+		{name: "today", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "today", first: "2026-10-18T00:00:00+03:00"},
+		{name: "case and spaces", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "  Yesterday ", first: "2026-10-17T00:00:00+03:00"},
+		{name: "tomorrow", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "tomorrow", first: "2026-10-19T00:00:00+03:00"},
+		{name: "date", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "2026-10-01", first: "2026-10-01T00:00:00+03:00"},
+		{name: "date in winter", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "2026-12-01", first: "2026-12-01T00:00:00+02:00"},
+		{name: "days ago", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "3 days ago", first: "2026-10-15T00:00:00+03:00"},
+		{name: "one day ago", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "1 day ago", first: "2026-10-17T00:00:00+03:00"},
+		{name: "a week ago", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "a week ago", first: "2026-10-11T00:00:00+03:00"},
+		{name: "weeks ago", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "2 weeks ago", first: "2026-10-04T00:00:00+03:00"},
+		{name: "quarters ago", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "2 quarters ago", first: "2026-04-18T00:00:00+03:00"},
+		{name: "a year ago", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "1 year ago", first: "2025-10-18T00:00:00+03:00"},
+		{name: "weekday", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "monday", first: "2026-10-12T00:00:00+03:00"},
+		{name: "weekday of today", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "sunday", first: "2026-10-18T00:00:00+03:00"},
+		{name: "last weekday of today", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "last sunday", first: "2026-10-11T00:00:00+03:00"},
+		{name: "last weekday", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "last monday", first: "2026-10-12T00:00:00+03:00"},
+		{name: "next weekday", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "next monday", first: "2026-10-19T00:00:00+03:00"},
+		{name: "next weekday of today", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "next sunday", first: "2026-10-25T00:00:00+03:00"},
+		{name: "this week", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "this week", first: "2026-10-12T00:00:00+03:00", last: "2026-10-18T00:00:00+03:00"},
+		{name: "last week", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "last week", first: "2026-10-05T00:00:00+03:00", last: "2026-10-11T00:00:00+03:00"},
+		{name: "next week", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "next week", first: "2026-10-19T00:00:00+03:00", last: "2026-10-25T00:00:00+03:00"},
+		{name: "start of month", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "start of month", first: "2026-10-01T00:00:00+03:00"},
+		{name: "end of month", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "end of month", first: "2026-10-31T00:00:00+02:00"},
+		{name: "this month", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "this month", first: "2026-10-01T00:00:00+03:00", last: "2026-10-31T00:00:00+02:00"},
+		{name: "last month", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "last month", first: "2026-09-01T00:00:00+03:00", last: "2026-09-30T00:00:00+03:00"},
+		{name: "start of the year", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "start of the year", first: "2026-01-01T00:00:00+02:00"},
+		{name: "beginning of next week", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "beginning of next week", first: "2026-10-19T00:00:00+03:00"},
+		{name: "end of last year", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "end of last year", first: "2025-12-31T00:00:00+02:00"},
+		{name: "next year", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "next year", first: "2027-01-01T00:00:00+02:00", last: "2027-12-31T00:00:00+02:00"},
+		{name: "this quarter", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "this quarter", first: "2026-10-01T00:00:00+03:00", last: "2026-12-31T00:00:00+02:00"},
+		{name: "last quarter", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "last quarter", first: "2026-07-01T00:00:00+03:00", last: "2026-09-30T00:00:00+03:00"},
+		{name: "quarter", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "q3", first: "2026-07-01T00:00:00+03:00", last: "2026-09-30T00:00:00+03:00"},
+		{name: "quarter of a year", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "Q1 2024", first: "2024-01-01T00:00:00+02:00", last: "2024-03-31T00:00:00+02:00"},
+		{name: "year and quarter", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "2025-q4", first: "2025-10-01T00:00:00+03:00", last: "2025-12-31T00:00:00+02:00"},
+
+		// the clocks went back on Sunday, October 25th 2026
+		{name: "after DST ends", now: "2026-10-26T09:00:00+02:00", loc: bucharest, expr: "today", first: "2026-10-26T00:00:00+02:00"},
+		{name: "day DST ends", now: "2026-10-26T09:00:00+02:00", loc: bucharest, expr: "yesterday", first: "2026-10-25T00:00:00+03:00"},
+		{name: "week ago across DST end", now: "2026-10-26T09:00:00+02:00", loc: bucharest, expr: "a week ago", first: "2026-10-19T00:00:00+03:00"},
+		{name: "week ending with DST", now: "2026-10-26T09:00:00+02:00", loc: bucharest, expr: "last week", first: "2026-10-19T00:00:00+03:00", last: "2026-10-25T00:00:00+03:00"},
+		// the clocks went forward on Sunday, March 29th 2026
+		{name: "day DST starts", now: "2026-03-30T10:00:00+03:00", loc: bucharest, expr: "yesterday", first: "2026-03-29T00:00:00+02:00"},
+		{name: "days ago across DST start", now: "2026-03-30T10:00:00+03:00", loc: bucharest, expr: "2 days ago", first: "2026-03-28T00:00:00+02:00"},
+		{name: "month with DST start", now: "2026-03-30T10:00:00+03:00", loc: bucharest, expr: "this month", first: "2026-03-01T00:00:00+02:00", last: "2026-03-31T00:00:00+03:00"},
+		// Santiago skips midnight on Sunday, September 6th 2026, that day starting at 01:00
+		{name: "day without midnight", now: "2026-09-06T10:00:00-03:00", loc: santiago, expr: "today", first: "2026-09-06T01:00:00-03:00"},
+		{name: "before day without midnight", now: "2026-09-06T10:00:00-03:00", loc: santiago, expr: "yesterday", first: "2026-09-05T00:00:00-04:00"},
+		{name: "after day without midnight", now: "2026-09-06T10:00:00-03:00", loc: santiago, expr: "tomorrow", first: "2026-09-07T00:00:00-03:00"},
+		{name: "week ending without midnight", now: "2026-09-06T10:00:00-03:00", loc: santiago, expr: "this week", first: "2026-08-31T00:00:00-04:00", last: "2026-09-06T01:00:00-03:00"},
+		{name: "date without midnight", now: "2026-09-07T10:00:00-03:00", loc: santiago, expr: "2026-09-06", first: "2026-09-06T01:00:00-03:00"},
+		{name: "weekday without midnight", now: "2026-09-07T10:00:00-03:00", loc: santiago, expr: "last sunday", first: "2026-09-06T01:00:00-03:00"},
+
+		// month edges
+		{name: "month ago from a longer month", now: "2026-03-31T12:00:00+03:00", loc: bucharest, expr: "a month ago", first: "2026-02-28T00:00:00+02:00"},
+		{name: "month ago in a leap year", now: "2024-03-31T12:00:00+03:00", loc: bucharest, expr: "1 month ago", first: "2024-02-29T00:00:00+02:00"},
+		{name: "months ago across the year", now: "2026-03-31T12:00:00+03:00", loc: bucharest, expr: "3 months ago", first: "2025-12-31T00:00:00+02:00"},
+		{name: "quarter ago", now: "2026-05-31T12:00:00+03:00", loc: bucharest, expr: "a quarter ago", first: "2026-02-28T00:00:00+02:00"},
+		{name: "end of last month", now: "2026-03-31T12:00:00+03:00", loc: bucharest, expr: "end of last month", first: "2026-02-28T00:00:00+02:00"},
+		{name: "year ago from a leap day", now: "2024-02-29T12:00:00+02:00", loc: bucharest, expr: "1 year ago", first: "2023-02-28T00:00:00+02:00"},
+		{name: "end of a leap month", now: "2024-02-10T12:00:00+02:00", loc: bucharest, expr: "end of month", first: "2024-02-29T00:00:00+02:00"},
+		{name: "last month across the year", now: "2026-01-15T12:00:00+02:00", loc: bucharest, expr: "last month", first: "2025-12-01T00:00:00+02:00", last: "2025-12-31T00:00:00+02:00"},
+		{name: "last quarter across the year", now: "2026-01-15T12:00:00+02:00", loc: bucharest, expr: "last quarter", first: "2025-10-01T00:00:00+03:00", last: "2025-12-31T00:00:00+02:00"},
+		{name: "last week across the year", now: "2026-01-02T12:00:00+02:00", loc: bucharest, expr: "last week", first: "2025-12-22T00:00:00+02:00", last: "2025-12-28T00:00:00+02:00"},
+		{name: "tomorrow across the year", now: "2026-12-31T23:59:00+02:00", loc: bucharest, expr: "tomorrow", first: "2027-01-01T00:00:00+02:00"},
+		{name: "next month across the year", now: "2026-12-31T23:59:00+02:00", loc: bucharest, expr: "next month", first: "2027-01-01T00:00:00+02:00", last: "2027-01-31T00:00:00+02:00"},
+		{name: "next quarter across the year", now: "2026-12-31T23:59:00+02:00", loc: bucharest, expr: "next quarter", first: "2027-01-01T00:00:00+02:00", last: "2027-03-31T00:00:00+03:00"},
+
+		{name: "empty", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "", shouldError: true},
+		{name: "unknown", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "someday", shouldError: true},
+		{name: "missing ago", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "3 days", shouldError: true},
+		{name: "negative count", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "-1 days ago", shouldError: true},
+		{name: "bad month", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "2026-13-01", shouldError: true},
+		{name: "bad day", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "2026-02-30", shouldError: true},
+		{name: "bad quarter", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "q5", shouldError: true},
+		{name: "unknown period", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "last fortnight", shouldError: true},
+		{name: "start of day", now: "2026-10-18T14:30:00+03:00", loc: bucharest, expr: "start of day", shouldError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			now, err := time.Parse(time.RFC3339, tc.now)
+			require.NoError(t, err)
+			first, last, err := ParseDateSpanAt(tc.expr, now.In(tc.loc))
+			if tc.shouldError {
+				require.IsType(t, DidError{}, err)
+				return
+			}
+			require.NoError(t, err)
+			if tc.last == "" {
+				tc.last = tc.first
+			}
+			assert.Equal(t, tc.first, first.Format(time.RFC3339))
+			assert.Equal(t, tc.last, last.Format(time.RFC3339))
+			assert.Equal(t, tc.loc, first.Location())
+		})
+	}
+}
+
+func TestStartOfDay(t *testing.T) {
+	santiago, err := time.LoadLocation("America/Santiago")
+	require.NoError(t, err)
+	for _, reference := range []string{"2026-09-06T01:00:00-03:00", "2026-09-06T12:00:00-03:00", "2026-09-06T23:59:59-03:00"} {
+		now, err := time.Parse(time.RFC3339, reference)
+		require.NoError(t, err)
+		assert.Equal(t, "2026-09-06T01:00:00-03:00", startOfDay(now.In(santiago)).Format(time.RFC3339),
+			"the day starts when the clocks skip midnight")
+	}
+	now := time.Date(2026, time.September, 5, 23, 30, 0, 0, santiago)
+	assert.Equal(t, "2026-09-05T00:00:00-04:00", startOfDay(now).Format(time.RFC3339))
+}
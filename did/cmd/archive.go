@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/Link512/godid"
+	"github.com/spf13/cobra"
+)
+
+var archiveCmd = &cobra.Command{
+	Use:   "archive",
+	Short: "Moves old tasks to the archive store",
+	Long: `With --before every task logged before that day is moved to the archive, otherwise the retention policies of
+the config are applied. The queries keep showing the archived tasks, reading them from the archive`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := openStore(false); err != nil {
+			return err
+		}
+		defer godid.Close()
+		before, err := getDateFlag(cmd, "before")
+		if err != nil {
+			return err
+		}
+		var result godid.ArchiveResult
+		if before.IsZero() {
+			result, err = godid.ApplyRetention()
+		} else {
+			result, err = godid.Archive(before)
+		}
+		if err != nil {
+			return handleError(err)
+		}
+		fmt.Printf("Archived %d tasks, deleted %d\n", result.Archived, result.Deleted)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(archiveCmd)
+	archiveCmd.Flags().String("before", "", "Archive the tasks logged before this day, e.g. 2026-01-01 or start of year")
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Link512/godid"
+	"github.com/spf13/cobra"
+)
+
+var backupCmd = &cobra.Command{
+	Use:   "backup <file>",
+	Short: "Writes a snapshot of the store to a file",
+	Long:  `The snapshot is consistent even while other did commands are logging tasks. Use restore to bring it back`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("must specify the file")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := openStore(true); err != nil {
+			return err
+		}
+		defer godid.Close()
+		if err := godid.Backup(args[0]); err != nil {
+			return handleError(err)
+		}
+		fmt.Printf("Backed up the store to %s\n", args[0])
+		return nil
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore <file>",
+	Short: "Replaces the store with a snapshot written by backup",
+	Long:  `The snapshot is checked first, the store is left untouched when it is invalid`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("must specify the file")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := openStore(false); err != nil {
+			return err
+		}
+		defer godid.Close()
+		if err := godid.Restore(args[0]); err != nil {
+			return handleError(err)
+		}
+		fmt.Printf("Restored the store from %s\n", args[0])
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(backupCmd)
+	rootCmd.AddCommand(restoreCmd)
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"github.com/Link512/godid"
+	"github.com/spf13/cobra"
+)
+
+// defaultBucket is the bucket the tasks are logged in and read from when no other is given
+const defaultBucket = "root"
+
+func addBucketFlag(cmd *cobra.Command, usage string) {
+	cmd.Flags().StringP("bucket", "b", defaultBucket, usage)
+}
+
+func addGroupByBucketFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("by-bucket", false, "Group the tasks by the bucket they were logged in rather than per day")
+}
+
+// groupByBucket tells whether the --by-bucket flag is set, commands without the flag grouping the tasks per day
+func groupByBucket(cmd *cobra.Command) (bool, error) {
+	if cmd.Flags().Lookup("by-bucket") == nil {
+		return false, nil
+	}
+	return cmd.Flags().GetBool("by-bucket")
+}
+
+// applyGroupByBucket makes the queries group the tasks by bucket when the --by-bucket flag is set
+func applyGroupByBucket(cmd *cobra.Command) error {
+	group, err := groupByBucket(cmd)
+	if err != nil {
+		return err
+	}
+	godid.SetGroupByBucket(group)
+	return nil
+}
@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Link512/godid"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var bucketsCmd = &cobra.Command{
+	Use:   "buckets",
+	Short: "Manages the buckets the tasks are logged in",
+	Long:  `Tasks are logged in the root bucket unless --bucket says otherwise, nested buckets having a path, e.g. work/payments`,
+}
+
+var bucketsLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "Lists the buckets holding tasks",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := openStore(true); err != nil {
+			return err
+		}
+		defer godid.Close()
+		stats, err := godid.ListBuckets()
+		if err != nil {
+			return handleError(err)
+		}
+		if len(stats) == 0 {
+			printEmpty()
+		}
+		for _, s := range stats {
+			fmt.Println(s.Name)
+		}
+		return nil
+	},
+}
+
+var bucketsStatsCmd = &cobra.Command{
+	Use:   "stats [bucket]",
+	Short: "Displays how many tasks the buckets hold and when they were logged",
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 1 {
+			return errors.New("must specify at most one bucket")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := openStore(true); err != nil {
+			return err
+		}
+		defer godid.Close()
+		var (
+			stats []godid.BucketStats
+			err   error
+		)
+		if len(args) == 1 {
+			var s godid.BucketStats
+			s, err = godid.GetBucketStats(args[0])
+			stats = []godid.BucketStats{s}
+		} else {
+			stats, err = godid.ListBuckets()
+		}
+		if err != nil {
+			return handleError(err)
+		}
+		printBucketStats(stats)
+		return nil
+	},
+}
+
+var bucketsRenameCmd = &cobra.Command{
+	Use:     "rename <bucket> <new name>",
+	Short:   "Renames a bucket, its tasks keeping their ids",
+	PreRunE: requireBucketPair,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := openStore(false); err != nil {
+			return err
+		}
+		defer godid.Close()
+		if err := godid.RenameBucket(args[0], args[1]); err != nil {
+			return handleError(err)
+		}
+		fmt.Printf("Renamed %s to %s\n", args[0], args[1])
+		return nil
+	},
+}
+
+var bucketsMergeCmd = &cobra.Command{
+	Use:     "merge <bucket> <into bucket>",
+	Short:   "Moves all the tasks of a bucket into another one",
+	Long:    `The moved tasks keep their ids unless taken in the other bucket, their trash and edit history moving along`,
+	PreRunE: requireBucketPair,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := openStore(false); err != nil {
+			return err
+		}
+		defer godid.Close()
+		count, err := godid.MergeBuckets(args[0], args[1])
+		if err != nil {
+			return handleError(err)
+		}
+		fmt.Printf("Moved %d tasks from %s to %s\n", count, args[0], args[1])
+		return nil
+	},
+}
+
+var bucketsCpCmd = &cobra.Command{
+	Use:     "cp <bucket> <to bucket>",
+	Short:   "Copies all the tasks of a bucket into another one",
+	PreRunE: requireBucketPair,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := openStore(false); err != nil {
+			return err
+		}
+		defer godid.Close()
+		count, err := godid.CopyBucket(args[0], args[1])
+		if err != nil {
+			return handleError(err)
+		}
+		fmt.Printf("Copied %d tasks from %s to %s\n", count, args[0], args[1])
+		return nil
+	},
+}
+
+var bucketsRmCmd = &cobra.Command{
+	Use:   "rm <bucket>",
+	Short: "Moves all the tasks of a bucket to the trash",
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("must specify the bucket")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := openStore(false); err != nil {
+			return err
+		}
+		defer godid.Close()
+		count, err := godid.DeleteBucket(args[0])
+		if err != nil {
+			return handleError(err)
+		}
+		fmt.Printf("Moved %d tasks to the trash\n", count)
+		return nil
+	},
+}
+
+func requireBucketPair(cmd *cobra.Command, args []string) error {
+	if len(args) != 2 {
+		return errors.New("must specify two buckets")
+	}
+	return nil
+}
+
+func printBucketStats(stats []godid.BucketStats) {
+	if len(stats) == 0 {
+		printEmpty()
+		return
+	}
+	writer := tablewriter.NewWriter(os.Stdout)
+	writer.SetHeader([]string{"Bucket", "Tasks", "Days", "First", "Last"})
+	for _, s := range stats {
+		writer.Append([]string{
+			s.Name,
+			fmt.Sprint(s.Entries),
+			fmt.Sprint(s.Days),
+			s.First.In(godid.Location()).Format("2006-01-02 15:04"),
+			s.Last.In(godid.Location()).Format("2006-01-02 15:04"),
+		})
+	}
+	writer.Render()
+}
+
+func init() {
+	bucketsCmd.AddCommand(bucketsLsCmd, bucketsStatsCmd, bucketsRenameCmd, bucketsMergeCmd, bucketsCpCmd, bucketsRmCmd)
+	rootCmd.AddCommand(bucketsCmd)
+}
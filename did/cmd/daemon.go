@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Link512/godid"
+	"github.com/spf13/cobra"
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Keeps the store open and shares it with the other did commands",
+	Long: `While the daemon runs the other commands go through its socket instead of opening the store, so any number of
+them can log tasks at the same time. Stop it with Ctrl+C`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := openStore(false); err != nil {
+			return err
+		}
+		defer godid.Close()
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		return handleError(godid.Serve(ctx))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(daemonCmd)
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Link512/godid"
+	"github.com/spf13/cobra"
+)
+
+var dayCmd = &cobra.Command{
+	Use:   "day <date>",
+	Short: "Displays the tasks logged on a given day",
+	Long:  `The day can be a date or an expression like yesterday, 3 days ago, last monday or start of month`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return errors.New("must specify the day")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		bucket, err := cmd.Flags().GetString("bucket")
+		if err != nil {
+			return err
+		}
+		if err := openStore(true); err != nil {
+			return err
+		}
+		defer godid.Close()
+		if err := applyTimezone(cmd); err != nil {
+			return err
+		}
+		day, err := godid.ParseDate(strings.Join(args, " "))
+		if err != nil {
+			return handleError(err)
+		}
+		entries, err := godid.GetRangeFromBucket(bucket, day, day, false)
+		return handleResult(cmd, entries, err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dayCmd)
+	addTimezoneFlag(dayCmd)
+	addBucketFlag(dayCmd, "Bucket to read the tasks from, the nested ones included")
+}
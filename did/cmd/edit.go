@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Link512/godid"
+	"github.com/spf13/cobra"
+)
+
+var editCmd = &cobra.Command{
+	Use:   "edit <id> <entry>",
+	Short: "Replaces the content of a logged task",
+	Long:  `The id of a task is displayed by the query commands when running them with --ids`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 2 {
+			return errors.New("must specify the id and the new entry")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := openStore(false); err != nil {
+			return err
+		}
+		defer godid.Close()
+		bucket, err := cmd.Flags().GetString("bucket")
+		if err != nil {
+			return err
+		}
+		return handleError(godid.UpdateEntryInBucket(bucket, args[0], strings.Join(args[1:], " ")))
+	},
+}
+
+func init() {
+	addBucketFlag(editCmd, "Bucket the task was logged in")
+	rootCmd.AddCommand(editCmd)
+}
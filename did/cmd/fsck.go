@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Link512/godid"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var fsckCmd = &cobra.Command{
+	Use:   "fsck",
+	Short: "Checks the store for damaged or misfiled tasks",
+	Long: `Reports the tasks filed under the wrong day, the ones that can't be read and the empty ones. With --repair the
+misfiled tasks are moved to their day and the unreadable ones are set aside in quarantine, the store being upgraded to
+the latest format afterwards`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		repair, err := cmd.Flags().GetBool("repair")
+		if err != nil {
+			return err
+		}
+		// the store is checked as it is, the entries a migration can't read mustn't keep fsck from reporting them
+		if err := handleError(godid.InitWithOptions(godid.Options{ReadOnly: !repair, SkipMigrations: true})); err != nil {
+			return err
+		}
+		defer godid.Close()
+		problems, err := godid.Check(repair)
+		if err != nil {
+			return handleError(err)
+		}
+		printProblems(problems, repair)
+		if !repair {
+			return nil
+		}
+		results, err := godid.Migrate(false)
+		if err != nil {
+			return handleError(err)
+		}
+		printMigrations(results, false)
+		return nil
+	},
+}
+
+func printProblems(problems []godid.CheckProblem, repair bool) {
+	if len(problems) == 0 {
+		fmt.Println("No problems found")
+		return
+	}
+	writer := tablewriter.NewWriter(os.Stdout)
+	writer.SetAutoWrapText(true)
+	writer.SetRowLine(true)
+	header := []string{"Bucket", "Day", "ID", "Problem"}
+	if repair {
+		header = append(header, "Repaired")
+	}
+	writer.SetHeader(header)
+	repaired := 0
+	for _, p := range problems {
+		row := []string{p.Bucket, p.Day, p.Key, p.Description}
+		if repair {
+			row = append(row, fmt.Sprint(p.Repaired))
+		}
+		if p.Repaired {
+			repaired++
+		}
+		writer.Append(row)
+	}
+	writer.Render()
+	if repair {
+		fmt.Printf("Repaired %d of %d problems\n", repaired, len(problems))
+		return
+	}
+	fmt.Printf("Found %d problems, run did fsck --repair to fix the ones it can\n", len(problems))
+}
+
+func init() {
+	rootCmd.AddCommand(fsckCmd)
+	fsckCmd.Flags().Bool("repair", false, "Move the misfiled tasks to their day and quarantine the unreadable ones")
+}
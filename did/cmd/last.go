@@ -9,8 +9,9 @@ import (
 
 var lastCmd = &cobra.Command{
 	Use:   "last",
-	Short: "Displays the tasks logged in the last custom day duration",
-	Long:  `The duration string only accepts days: 1d, 2d, etc`,
+	Short: "Displays the tasks logged in the last custom duration",
+	Long: `The duration counts years, months, weeks, days and hours, e.g. 2w, 3m, 1y, 4h or 1w3d. The months and years are
+calendar ones. The durations with hours start at the exact time, the others cover whole days`,
 	PreRunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) != 1 {
 			return errors.New("must specify interval")
@@ -22,14 +23,33 @@ var lastCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		godid.Init()
+		bucket, err := cmd.Flags().GetString("bucket")
+		if err != nil {
+			return err
+		}
+		if err := openStore(true); err != nil {
+			return err
+		}
 		defer godid.Close()
-		last, err := godid.GetLastDuration(args[0], flat)
-		return handleResult(last, err)
+		if err := applyTimezone(cmd); err != nil {
+			return err
+		}
+		if err := applyGroupByBucket(cmd); err != nil {
+			return err
+		}
+		if err := applyWorkDays(cmd); err != nil {
+			return err
+		}
+		last, err := godid.GetLastDurationFromBucket(bucket, args[0], flat)
+		return handleResult(cmd, last, err)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(lastCmd)
+	addTimezoneFlag(lastCmd)
+	addBucketFlag(lastCmd, "Bucket to read the tasks from, the nested ones included")
+	addGroupByBucketFlag(lastCmd)
+	addWorkDaysFlag(lastCmd)
 	lastCmd.Flags().BoolP("flat", "f", false, "Do not aggregate the tasks per day")
 }
@@ -13,14 +13,33 @@ var lastWeekCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		godid.Init()
+		bucket, err := cmd.Flags().GetString("bucket")
+		if err != nil {
+			return err
+		}
+		if err := openStore(true); err != nil {
+			return err
+		}
 		defer godid.Close()
-		lastWeek, err := godid.GetLastWeek(flat)
-		return handleResult(lastWeek, err)
+		if err := applyTimezone(cmd); err != nil {
+			return err
+		}
+		if err := applyGroupByBucket(cmd); err != nil {
+			return err
+		}
+		if err := applyWorkDays(cmd); err != nil {
+			return err
+		}
+		lastWeek, err := godid.GetLastWeekFromBucket(bucket, flat)
+		return handleResult(cmd, lastWeek, err)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(lastWeekCmd)
+	addTimezoneFlag(lastWeekCmd)
+	addBucketFlag(lastWeekCmd, "Bucket to read the tasks from, the nested ones included")
+	addGroupByBucketFlag(lastWeekCmd)
+	addWorkDaysFlag(lastWeekCmd)
 	lastWeekCmd.Flags().BoolP("flat", "f", false, "Do not aggregate the tasks per day")
 }
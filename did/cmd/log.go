@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Link512/godid"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+const logTimeFormat = "2006-01-02 15:04:05"
+
+var logCmd = &cobra.Command{
+	Use:   "log <id>",
+	Short: "Displays how a logged task changed over time",
+	Long:  `Every edit keeps the version it replaced along with the time of the edit, the last version being the current one`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("must specify the id")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := openStore(true); err != nil {
+			return err
+		}
+		defer godid.Close()
+		bucket, err := cmd.Flags().GetString("bucket")
+		if err != nil {
+			return err
+		}
+		revisions, err := godid.GetEntryHistoryFromBucket(bucket, args[0])
+		if err != nil {
+			return handleError(err)
+		}
+		printRevisions(revisions)
+		return nil
+	},
+}
+
+func printRevisions(revisions []godid.Revision) {
+	writer := tablewriter.NewWriter(os.Stdout)
+	writer.SetAutoWrapText(true)
+	writer.SetRowLine(true)
+	writer.SetColWidth(4096)
+	writer.SetHeader([]string{"Version", "From", "Until", "Entry"})
+	// the first version dates from when the task was logged, the next ones from the edit replacing the previous
+	from := revisions[0].Timestamp
+	for i, r := range revisions {
+		until := "now"
+		if !r.ReplacedAt.IsZero() {
+			until = formatLogTime(r.ReplacedAt)
+		}
+		writer.Append([]string{strconv.Itoa(i + 1), formatLogTime(from), until, formatEntry(r.Entry)})
+		from = r.ReplacedAt
+	}
+	writer.Render()
+}
+
+func formatLogTime(t time.Time) string {
+	return t.In(godid.Location()).Format(logTimeFormat)
+}
+
+func init() {
+	addBucketFlag(logCmd, "Bucket the task was logged in")
+	rootCmd.AddCommand(logCmd)
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Link512/godid"
+	"github.com/spf13/cobra"
+)
+
+func addMetadataFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceP("tag", "t", nil, "Tag the logged entries, can be repeated")
+	cmd.Flags().StringP("project", "p", "", "Project the logged entries belong to")
+	cmd.Flags().DurationP("duration", "d", 0, "Time spent on the logged entries, e.g. 1h30m")
+	cmd.Flags().StringP("author", "a", "", "Author of the logged entries")
+	cmd.Flags().StringToString("extra", nil, "Extra key=value pairs attached to the logged entries")
+}
+
+// metadataFromFlags builds the metadata of the logged entries out of the flags added by addMetadataFlags
+func metadataFromFlags(cmd *cobra.Command) (godid.Metadata, error) {
+	var (
+		meta godid.Metadata
+		err  error
+	)
+	if meta.Tags, err = cmd.Flags().GetStringSlice("tag"); err != nil {
+		return meta, err
+	}
+	if meta.Project, err = cmd.Flags().GetString("project"); err != nil {
+		return meta, err
+	}
+	if meta.Duration, err = cmd.Flags().GetDuration("duration"); err != nil {
+		return meta, err
+	}
+	if meta.Author, err = cmd.Flags().GetString("author"); err != nil {
+		return meta, err
+	}
+	if meta.Extra, err = cmd.Flags().GetStringToString("extra"); err != nil {
+		return meta, err
+	}
+	if len(meta.Tags) == 0 {
+		meta.Tags = nil
+	}
+	if len(meta.Extra) == 0 {
+		meta.Extra = nil
+	}
+	return meta, nil
+}
+
+// formatEntry renders the content of an entry followed by its metadata, e.g. "fixed the build #ci @infra (30m0s)"
+func formatEntry(e godid.Entry) string {
+	parts := []string{e.Content}
+	for _, tag := range e.Tags {
+		parts = append(parts, "#"+tag)
+	}
+	if e.Project != "" {
+		parts = append(parts, "@"+e.Project)
+	}
+	if e.Duration != 0 {
+		parts = append(parts, fmt.Sprintf("(%s)", e.Duration))
+	}
+	if e.Author != "" {
+		parts = append(parts, "by "+e.Author)
+	}
+	keys := make([]string, 0, len(e.Extra))
+	for k := range e.Extra {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		parts = append(parts, k+"="+e.Extra[k])
+	}
+	return strings.Join(parts, " ")
+}
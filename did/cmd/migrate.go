@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/Link512/godid"
+	"github.com/spf13/cobra"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrades the store to the latest format",
+	Long:  `The other commands upgrade the store on their own, use --dry-run to report what would change without touching it`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			return err
+		}
+		if err := handleError(godid.InitWithOptions(godid.Options{SkipMigrations: true})); err != nil {
+			return err
+		}
+		defer godid.Close()
+		results, err := godid.Migrate(dryRun)
+		if err != nil {
+			return handleError(err)
+		}
+		if len(results) == 0 {
+			fmt.Println("The store is up to date")
+			return nil
+		}
+		printMigrations(results, dryRun)
+		return nil
+	},
+}
+
+func printMigrations(results []godid.MigrationResult, dryRun bool) {
+	action := "Applied"
+	if dryRun {
+		action = "Would apply"
+	}
+	for _, r := range results {
+		fmt.Printf("%s migration to version %d: %s (%d entries changed)\n", action, r.Version, r.Description, r.Changes)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+	migrateCmd.Flags().Bool("dry-run", false, "Only report the changes, leaving the store untouched")
+}
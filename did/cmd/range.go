@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"github.com/Link512/godid"
+	"github.com/spf13/cobra"
+)
+
+var rangeCmd = &cobra.Command{
+	Use:   "range",
+	Short: "Displays the tasks logged between two days",
+	Long: `Both days are included, e.g. did range --from 2026-09-01 --to 2026-09-30. The days can also be written like
+yesterday, 3 days ago, last monday, start of month or q3, a period like q3 or last month covering all its days`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		flat, err := cmd.Flags().GetBool("flat")
+		if err != nil {
+			return err
+		}
+		bucket, err := cmd.Flags().GetString("bucket")
+		if err != nil {
+			return err
+		}
+		if err := openStore(true); err != nil {
+			return err
+		}
+		defer godid.Close()
+		if err := applyTimezone(cmd); err != nil {
+			return err
+		}
+		if err := applyGroupByBucket(cmd); err != nil {
+			return err
+		}
+		if err := applyWorkDays(cmd); err != nil {
+			return err
+		}
+		from, err := getDateFlag(cmd, "from")
+		if err != nil {
+			return err
+		}
+		to, err := getLastDateFlag(cmd, "to")
+		if err != nil {
+			return err
+		}
+		result, err := godid.GetRangeFromBucket(bucket, from, to, flat)
+		return handleResult(cmd, result, err)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rangeCmd)
+	addTimezoneFlag(rangeCmd)
+	addBucketFlag(rangeCmd, "Bucket to read the tasks from, the nested ones included")
+	addGroupByBucketFlag(rangeCmd)
+	addWorkDaysFlag(rangeCmd)
+	rangeCmd.Flags().BoolP("flat", "f", false, "Do not aggregate the tasks per day")
+	rangeCmd.Flags().String("from", "", "First day of the range, e.g. 2026-09-01 or start of last month")
+	rangeCmd.Flags().String("to", "", "Last day of the range, e.g. 2026-09-30 or yesterday")
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/Link512/godid"
+	"github.com/spf13/cobra"
+)
+
+var rmCmd = &cobra.Command{
+	Use:   "rm <id>",
+	Short: "Moves a logged task to the trash",
+	Long:  `The id of a task is displayed by the query commands when running them with --ids`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("must specify the id")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := openStore(false); err != nil {
+			return err
+		}
+		defer godid.Close()
+		bucket, err := cmd.Flags().GetString("bucket")
+		if err != nil {
+			return err
+		}
+		return handleError(godid.DeleteEntryFromBucket(bucket, args[0]))
+	},
+}
+
+func init() {
+	addBucketFlag(rmCmd, "Bucket the task was logged in")
+	rootCmd.AddCommand(rmCmd)
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -20,10 +21,25 @@ var rootCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		godid.Init()
-		defer godid.Close()
+		meta, err := metadataFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		bucket, err := cmd.Flags().GetString("bucket")
+		if err != nil {
+			return err
+		}
+		allowFuture, err := cmd.Flags().GetBool("allow-future")
+		if err != nil {
+			return err
+		}
+		godid.SetAllowFutureEntries(allowFuture)
+		at, err := cmd.Flags().GetString("at")
+		if err != nil {
+			return err
+		}
 		if entry != "" {
-			return godid.AddEntry(entry)
+			return logEntry(bucket, entry, at, meta, false)
 		}
 		reader := bufio.NewReader(os.Stdin)
 		for {
@@ -31,11 +47,11 @@ var rootCmd = &cobra.Command{
 			line := strings.TrimSpace(string(lineBytes))
 			if err != nil {
 				if err == io.EOF && line != "" {
-					return godid.AddEntry(line)
+					return logEntry(bucket, line, at, meta, true)
 				}
 				break
 			}
-			if err := godid.AddEntry(line); err != nil {
+			if err := logEntry(bucket, line, at, meta, true); err != nil {
 				return err
 			}
 		}
@@ -43,6 +59,34 @@ var rootCmd = &cobra.Command{
 	},
 }
 
+// logEntry opens the store only for as long as it takes to log the entry, so the other commands don't have to wait
+// while reading from stdin. The entry is logged at the time given by --at, or now when it's empty. A line read from
+// stdin may start with the time it was done at instead, e.g. 09:30 or 2026-10-01 12:00, a line starting with something
+// that isn't a time, e.g. 25:00, being logged whole.
+func logEntry(bucket, what, atExpr string, meta godid.Metadata, fromStdin bool) error {
+	if err := openStore(false); err != nil {
+		return err
+	}
+	defer godid.Close()
+	// the times are read once the store is open, in the home timezone of the config
+	var at time.Time
+	if atExpr != "" {
+		var err error
+		if at, err = godid.ParseTime(atExpr); err != nil {
+			return handleError(err)
+		}
+	}
+	if fromStdin {
+		if lineAt, rest := godid.CutTimestamp(what); !lineAt.IsZero() {
+			at, what = lineAt, rest
+		}
+	}
+	if at.IsZero() {
+		return handleError(godid.AddEntryToBucketWithMetadata(bucket, what, meta))
+	}
+	return handleError(godid.AddEntryAtWithMetadata(bucket, what, at, meta))
+}
+
 // Execute is the entry point for the CLI
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
@@ -53,4 +97,9 @@ func Execute() {
 
 func init() {
 	rootCmd.Flags().StringP("entry", "e", "", "Entry to log")
+	rootCmd.Flags().String("at", "", "When the entry was done, e.g. 09:30, yesterday 17:00, 2h ago or 2026-10-01 12:00")
+	rootCmd.Flags().Bool("allow-future", false, "Allow logging entries at times that haven't come yet")
+	addMetadataFlags(rootCmd)
+	addBucketFlag(rootCmd, "Bucket to log the entries in, nested ones being separated by slashes, e.g. work/payments")
+	rootCmd.PersistentFlags().BoolP("ids", "i", false, "Display the ids of the tasks")
 }
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Link512/godid"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var searchCmd = &cobra.Command{
+	Use:   "search <query>",
+	Short: "Searches the logged tasks",
+	Long: `Words match anywhere in the tasks, their tags or project. Quote words to match them as a phrase and end a word
+with * to match it as a prefix, e.g. did search '"billing migration"' deploy*`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return errors.New("must specify the query")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		bucket, err := cmd.Flags().GetString("bucket")
+		if err != nil {
+			return err
+		}
+		limit, err := cmd.Flags().GetInt("limit")
+		if err != nil {
+			return err
+		}
+		showIDs, err := cmd.Flags().GetBool("ids")
+		if err != nil {
+			return err
+		}
+		if err := openStore(true); err != nil {
+			return err
+		}
+		defer godid.Close()
+		if err := applyTimezone(cmd); err != nil {
+			return err
+		}
+		from, err := getDateFlag(cmd, "from")
+		if err != nil {
+			return err
+		}
+		to, err := getLastDateFlag(cmd, "to")
+		if err != nil {
+			return err
+		}
+		results, err := godid.Search(strings.Join(args, " "), godid.SearchFilter{
+			Bucket: bucket,
+			From:   from,
+			To:     to,
+			Limit:  limit,
+		})
+		if err != nil {
+			return handleError(err)
+		}
+		printSearchResults(results, showIDs)
+		return nil
+	},
+}
+
+// getDateFlag parses a date flag in the timezone of the queries, returning the zero time when it's not set. The flag
+// takes a date expression, e.g. 2026-09-01 or last monday, the first day it stands for being returned.
+func getDateFlag(cmd *cobra.Command, name string) (time.Time, error) {
+	first, _, err := getDateSpanFlag(cmd, name)
+	return first, err
+}
+
+// getLastDateFlag parses a date flag like getDateFlag, returning the last day the expression stands for, e.g. the end
+// of the quarter for q3
+func getLastDateFlag(cmd *cobra.Command, name string) (time.Time, error) {
+	_, last, err := getDateSpanFlag(cmd, name)
+	return last, err
+}
+
+func getDateSpanFlag(cmd *cobra.Command, name string) (time.Time, time.Time, error) {
+	value, err := cmd.Flags().GetString(name)
+	if err != nil || value == "" {
+		return time.Time{}, time.Time{}, err
+	}
+	first, last, err := godid.ParseDateSpan(value)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("--%s: %w", name, handleError(err))
+	}
+	return first, last, nil
+}
+
+func printSearchResults(results []godid.SearchResult, showIDs bool) {
+	if len(results) == 0 {
+		printEmpty()
+		return
+	}
+	writer := tablewriter.NewWriter(os.Stdout)
+	writer.SetAutoWrapText(true)
+	writer.SetRowLine(true)
+	writer.SetColWidth(4096)
+	header := []string{"Date", "Bucket", "Entries"}
+	if showIDs {
+		header = []string{"Date", "Bucket", "ID", "Entries"}
+	}
+	writer.SetHeader(header)
+	for _, result := range results {
+		row := []string{result.Timestamp.In(godid.Location()).Format("2006-01-02 15:04"), result.Bucket}
+		if showIDs {
+			row = append(row, result.ID)
+		}
+		writer.Append(append(row, formatEntry(result.Entry)))
+	}
+	writer.Render()
+}
+
+func init() {
+	rootCmd.AddCommand(searchCmd)
+	addTimezoneFlag(searchCmd)
+	searchCmd.Flags().StringP("bucket", "b", "", "Only search the tasks of this bucket")
+	searchCmd.Flags().String("from", "", "Only search the tasks logged since this day, e.g. 2026-09-01 or last monday")
+	searchCmd.Flags().String("to", "", "Only search the tasks logged until this day, e.g. 2026-09-30 or yesterday")
+	searchCmd.Flags().IntP("limit", "n", 20, "Maximum number of results, 0 for all of them")
+}
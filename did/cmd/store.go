@@ -0,0 +1,11 @@
+package cmd
+
+import (
+	"github.com/Link512/godid"
+)
+
+// openStore initialises godid. The commands only querying the store open it read only, so they neither wait for nor
+// block the other did processes.
+func openStore(readOnly bool) error {
+	return handleError(godid.InitWithOptions(godid.Options{ReadOnly: readOnly}))
+}
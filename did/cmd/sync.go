@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Link512/godid"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync <store>",
+	Short: "Merges the tasks of another store into this one",
+	Long: `The tasks already present are skipped, so syncing with a store kept up to date by Syncthing or copied over on a
+USB stick can be repeated safely. Tasks edited in one of the stores, or logged at the same time in both but
+differing, are listed as conflicts, keeping this store's version. The tasks deleted from this store aren't brought back while they're in the
+trash`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("must specify the other store")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := openStore(false); err != nil {
+			return err
+		}
+		defer godid.Close()
+		result, err := godid.Sync(args[0])
+		if err != nil {
+			return handleError(err)
+		}
+		fmt.Printf("Added %d tasks, skipped %d already present and %d deleted from this store\n", result.Added,
+			result.Duplicates, result.Deleted)
+		printConflicts(result.Conflicts)
+		return nil
+	},
+}
+
+func printConflicts(conflicts []godid.SyncConflict) {
+	if len(conflicts) == 0 {
+		return
+	}
+	fmt.Printf("%d conflicts, this store's tasks were kept:\n", len(conflicts))
+	writer := tablewriter.NewWriter(os.Stdout)
+	writer.SetAutoWrapText(true)
+	writer.SetRowLine(true)
+	writer.SetColWidth(4096)
+	writer.SetHeader([]string{"Date", "Bucket", "This store", "Other store"})
+	for _, c := range conflicts {
+		writer.Append([]string{
+			c.Local.Timestamp.In(godid.Location()).Format("2006-01-02 15:04"),
+			c.Bucket,
+			formatEntry(c.Local),
+			formatEntry(c.Other),
+		})
+	}
+	writer.Render()
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+}
@@ -10,16 +10,36 @@ import (
 	"github.com/Link512/godid"
 
 	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
 )
 
-func handleResult(result map[string][]string, err error) error {
+func handleError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if didErr, ok := err.(godid.DidError); ok {
+		return didErr
+	}
+	return errors.New("internal error, check the logs")
+}
+
+func handleResult(cmd *cobra.Command, result map[string][]godid.Entry, err error) error {
 	if err != nil {
-		if didErr, ok := err.(godid.DidError); ok {
-			return didErr
-		}
-		return errors.New("internal error, check the logs")
+		return handleError(err)
 	}
-	printResults(result)
+	showIDs, err := cmd.Flags().GetBool("ids")
+	if err != nil {
+		return err
+	}
+	byBucket, err := groupByBucket(cmd)
+	if err != nil {
+		return err
+	}
+	groupColumn := "Date"
+	if byBucket {
+		groupColumn = "Bucket"
+	}
+	printResults(result, groupColumn, showIDs)
 	return nil
 }
 
@@ -27,7 +47,8 @@ func printEmpty() {
 	fmt.Println("Nothing here, you lazy slob!!")
 }
 
-func printResults(result map[string][]string) {
+// printResults prints the entries under the key they are grouped by, a date or a bucket as named by groupColumn
+func printResults(result map[string][]godid.Entry, groupColumn string, showIDs bool) {
 	if len(result) == 0 {
 		printEmpty()
 		return
@@ -37,18 +58,26 @@ func printResults(result map[string][]string) {
 	writer.SetAutoWrapText(true)
 	writer.SetRowLine(true)
 	writer.SetColWidth(4096)
-	writer.SetHeader([]string{"Date", "Entries"})
+	if showIDs {
+		writer.SetHeader([]string{groupColumn, "ID", "Entries"})
+	} else {
+		writer.SetHeader([]string{groupColumn, "Entries"})
+	}
 	bulkEntries := make([][]string, 0)
-	for date, entries := range result {
+	for group, entries := range result {
 		for _, entry := range entries {
-			bulkEntries = append(bulkEntries, []string{date, entry})
+			if showIDs {
+				bulkEntries = append(bulkEntries, []string{group, entry.ID, formatEntry(entry)})
+			} else {
+				bulkEntries = append(bulkEntries, []string{group, formatEntry(entry)})
+			}
 		}
 	}
 	if len(bulkEntries) == 0 {
 		printEmpty()
 		return
 	}
-	sort.Slice(bulkEntries, func(i, j int) bool {
+	sort.SliceStable(bulkEntries, func(i, j int) bool {
 		return strings.Compare(bulkEntries[i][0], bulkEntries[j][0]) < 0
 	})
 	writer.AppendBulk(bulkEntries)
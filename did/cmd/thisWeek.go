@@ -14,14 +14,33 @@ var thisWeekCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		godid.Init()
+		bucket, err := cmd.Flags().GetString("bucket")
+		if err != nil {
+			return err
+		}
+		if err := openStore(true); err != nil {
+			return err
+		}
 		defer godid.Close()
-		thisWeek, err := godid.GetThisWeek(flat)
-		return handleResult(thisWeek, err)
+		if err := applyTimezone(cmd); err != nil {
+			return err
+		}
+		if err := applyGroupByBucket(cmd); err != nil {
+			return err
+		}
+		if err := applyWorkDays(cmd); err != nil {
+			return err
+		}
+		thisWeek, err := godid.GetThisWeekFromBucket(bucket, flat)
+		return handleResult(cmd, thisWeek, err)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(thisWeekCmd)
+	addTimezoneFlag(thisWeekCmd)
+	addBucketFlag(thisWeekCmd, "Bucket to read the tasks from, the nested ones included")
+	addGroupByBucketFlag(thisWeekCmd)
+	addWorkDaysFlag(thisWeekCmd)
 	thisWeekCmd.Flags().BoolP("flat", "f", false, "Do not aggregate the tasks per day")
 }
@@ -12,13 +12,24 @@ var todayCmd = &cobra.Command{
 	Use:   "today",
 	Short: "Displays the tasks logged today",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		godid.Init()
+		bucket, err := cmd.Flags().GetString("bucket")
+		if err != nil {
+			return err
+		}
+		if err := openStore(true); err != nil {
+			return err
+		}
 		defer godid.Close()
-		today, err := godid.GetToday()
-		return handleResult(map[string][]string{time.Now().Format("2006-01-02"): today}, err)
+		if err := applyTimezone(cmd); err != nil {
+			return err
+		}
+		today, err := godid.GetTodayFromBucket(bucket)
+		return handleResult(cmd, map[string][]godid.Entry{time.Now().In(godid.Location()).Format("2006-01-02"): today}, err)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(todayCmd)
+	addTimezoneFlag(todayCmd)
+	addBucketFlag(todayCmd, "Bucket to read the tasks from, the nested ones included")
 }
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Link512/godid"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+var trashCmd = &cobra.Command{
+	Use:   "trash",
+	Short: "Manages the deleted tasks",
+	Long:  `Tasks deleted with rm are kept in the trash until it's emptied, the queries never show them`,
+}
+
+var trashListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Displays the deleted tasks, the most recently deleted first",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := openStore(true); err != nil {
+			return err
+		}
+		defer godid.Close()
+		trashed, err := godid.ListTrash()
+		if err != nil {
+			return handleError(err)
+		}
+		printTrash(trashed)
+		return nil
+	},
+}
+
+var trashRestoreCmd = &cobra.Command{
+	Use:   "restore <id>",
+	Short: "Puts a deleted task back, under the same id",
+	Long:  `The task goes back to the bucket it was deleted from`,
+	PreRunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return errors.New("must specify the id")
+		}
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := openStore(false); err != nil {
+			return err
+		}
+		defer godid.Close()
+		bucket, err := cmd.Flags().GetString("bucket")
+		if err != nil {
+			return err
+		}
+		var e godid.Entry
+		if bucket == "" {
+			e, err = godid.RestoreEntry(args[0])
+		} else {
+			e, err = godid.RestoreEntryToBucket(bucket, args[0])
+		}
+		if err != nil {
+			return handleError(err)
+		}
+		fmt.Printf("Restored %s\n", formatEntry(e))
+		return nil
+	},
+}
+
+var trashEmptyCmd = &cobra.Command{
+	Use:   "empty",
+	Short: "Deletes the tasks in the trash for good",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := openStore(false); err != nil {
+			return err
+		}
+		defer godid.Close()
+		count, err := godid.EmptyTrash()
+		if err != nil {
+			return handleError(err)
+		}
+		fmt.Printf("Deleted %d tasks for good\n", count)
+		return nil
+	},
+}
+
+func printTrash(trashed []godid.TrashedEntry) {
+	if len(trashed) == 0 {
+		fmt.Println("The trash is empty")
+		return
+	}
+	writer := tablewriter.NewWriter(os.Stdout)
+	writer.SetAutoWrapText(true)
+	writer.SetRowLine(true)
+	writer.SetColWidth(4096)
+	writer.SetHeader([]string{"Deleted", "Bucket", "ID", "Date", "Entry"})
+	for _, t := range trashed {
+		writer.Append([]string{
+			t.DeletedAt.In(godid.Location()).Format("2006-01-02 15:04"),
+			t.Bucket,
+			t.ID,
+			t.Timestamp.In(godid.Location()).Format("2006-01-02 15:04"),
+			formatEntry(t.Entry),
+		})
+	}
+	writer.Render()
+}
+
+func init() {
+	trashRestoreCmd.Flags().StringP("bucket", "b", "", "Bucket the task was deleted from, needed when tasks with the same id were deleted from several")
+	trashCmd.AddCommand(trashListCmd, trashRestoreCmd, trashEmptyCmd)
+	rootCmd.AddCommand(trashCmd)
+}
@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"github.com/Link512/godid"
+	"github.com/spf13/cobra"
+)
+
+func addTimezoneFlag(cmd *cobra.Command) {
+	cmd.Flags().String("tz", "", "Timezone used to split the days, e.g. Europe/Bucharest. Defaults to the home timezone")
+}
+
+// applyTimezone makes the queries use the timezone passed through the --tz flag, if any
+func applyTimezone(cmd *cobra.Command) error {
+	tz, err := cmd.Flags().GetString("tz")
+	if err != nil {
+		return err
+	}
+	if tz == "" {
+		return nil
+	}
+	return handleError(godid.SetTimezone(tz))
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/Link512/godid"
+	"github.com/spf13/cobra"
+)
+
+var undoCmd = &cobra.Command{
+	Use:   "undo",
+	Short: "Reverses the last task added, edited or deleted",
+	Long:  `Added tasks are moved to the trash, edited ones get their previous content back and deleted ones, those of a deleted bucket included, are restored`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := openStore(false); err != nil {
+			return err
+		}
+		defer godid.Close()
+		c, err := godid.Undo()
+		if err != nil {
+			return handleError(err)
+		}
+		switch c.Kind {
+		case godid.ChangeAdd:
+			fmt.Printf("Moved to the trash %s\n", formatEntry(c.Entry))
+		case godid.ChangeEdit:
+			fmt.Printf("Reverted to %s\n", formatEntry(c.Entry))
+		case godid.ChangeDeleteBucket:
+			fmt.Printf("Restored %d tasks of %s\n", c.Entries, c.Bucket)
+		default:
+			fmt.Printf("Restored %s\n", formatEntry(c.Entry))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(undoCmd)
+}
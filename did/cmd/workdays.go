@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"github.com/Link512/godid"
+	"github.com/spf13/cobra"
+)
+
+func addWorkDaysFlag(cmd *cobra.Command) {
+	cmd.Flags().Bool("work-days", false, "Hide the tasks logged on the days that aren't work_days in the config")
+}
+
+// applyWorkDays makes the queries leave out the non-working days when the --work-days flag is set
+func applyWorkDays(cmd *cobra.Command) error {
+	hide, err := cmd.Flags().GetBool("work-days")
+	if err != nil {
+		return err
+	}
+	godid.SetHideNonWorkingDays(hide)
+	return nil
+}
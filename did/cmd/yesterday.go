@@ -12,13 +12,24 @@ var yesterdayCmd = &cobra.Command{
 	Short: "Displays the tasks logged yesterday",
 	Long:  ``,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		godid.Init()
+		bucket, err := cmd.Flags().GetString("bucket")
+		if err != nil {
+			return err
+		}
+		if err := openStore(true); err != nil {
+			return err
+		}
 		defer godid.Close()
-		yesterday, err := godid.GetYesterday()
-		return handleResult(map[string][]string{time.Now().AddDate(0, 0, -1).Format("2006-01-02"): yesterday}, err)
+		if err := applyTimezone(cmd); err != nil {
+			return err
+		}
+		yesterday, err := godid.GetYesterdayFromBucket(bucket)
+		return handleResult(cmd, map[string][]godid.Entry{time.Now().In(godid.Location()).AddDate(0, 0, -1).Format("2006-01-02"): yesterday}, err)
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(yesterdayCmd)
+	addTimezoneFlag(yesterdayCmd)
+	addBucketFlag(yesterdayCmd, "Bucket to read the tasks from, the nested ones included")
 }
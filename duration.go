@@ -0,0 +1,57 @@
+package godid
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+)
+
+var (
+	// durationPattern matches a duration made of counts of years, months, weeks, days and hours, e.g. 1w3d
+	durationPattern     = regexp.MustCompile(`^(?:\d+[ymwdh])+$`)
+	durationPartPattern = regexp.MustCompile(`(\d+)([ymwdh])`)
+)
+
+// calendarDuration is a duration counted on the calendar: the months and years have the length of the actual ones
+// while the hours are exact
+type calendarDuration struct {
+	years  int
+	months int
+	days   int
+	hours  int
+}
+
+// before returns the time the duration ends at when it starts at t. Going back months clamps to the end of shorter
+// months like the date expressions, e.g. a month before March 31st is February 28th, the days being counted after.
+func (d calendarDuration) before(t time.Time) time.Time {
+	year, month, day := clampedDate(t, -12*d.years-d.months)
+	moved := time.Date(year, month, day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	return moved.AddDate(0, 0, -d.days).Add(-time.Duration(d.hours) * time.Hour)
+}
+
+// parseDuration parses a duration like 2w, 3m, 1y, 4h or 1w3d, a week being 7 days
+func parseDuration(durationString string) (calendarDuration, error) {
+	if !durationPattern.MatchString(durationString) {
+		return calendarDuration{}, didErrorf("invalid duration string %s", durationString)
+	}
+	var d calendarDuration
+	for _, part := range durationPartPattern.FindAllStringSubmatch(durationString, -1) {
+		count, err := strconv.Atoi(part[1])
+		if err != nil {
+			return calendarDuration{}, didErrorf("invalid duration string %s", durationString)
+		}
+		switch part[2] {
+		case "y":
+			d.years += count
+		case "m":
+			d.months += count
+		case "w":
+			d.days += 7 * count
+		case "d":
+			d.days += count
+		case "h":
+			d.hours += count
+		}
+	}
+	return d, nil
+}
@@ -16,3 +16,19 @@ func didErrorf(format string, args ...any) DidError {
 		message: fmt.Sprintf(format, args...),
 	}
 }
+
+func entryNotFoundError(parentBucketName, id string) DidError {
+	return didErrorf("no entry with id %s in bucket %s", id, parentBucketName)
+}
+
+func trashedEntryNotFoundError(parentBucketName, id string) DidError {
+	return didErrorf("no entry with id %s from bucket %s in the trash", id, parentBucketName)
+}
+
+func bucketNotFoundError(parentBucketName string) DidError {
+	return didErrorf("no bucket %s", parentBucketName)
+}
+
+func bucketExistsError(parentBucketName string) DidError {
+	return didErrorf("bucket %s already holds entries", parentBucketName)
+}
@@ -0,0 +1,60 @@
+package godid
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	// historyBucketName holds the earlier versions of the edited entries under their parent bucket
+	historyBucketName = reservedBucketPrefix + "history"
+)
+
+// revision is an earlier version of an entry
+type revision struct {
+	entry
+	replacedAt time.Time
+}
+
+func (r revision) public() Revision {
+	return Revision{
+		Entry:      r.entry.public(),
+		ReplacedAt: r.replacedAt,
+	}
+}
+
+// revisionRecord is the stored form of a revision, for the stores without a table for them
+type revisionRecord struct {
+	Bucket     string    `json:"bucket"`
+	ID         string    `json:"id"`
+	ReplacedAt time.Time `json:"replaced_at"`
+	// Value is the entry as it was stored before the edit, see encodeValue
+	Value []byte `json:"value"`
+}
+
+func encodeRevisionRecord(parentBucketName string, key, v []byte, replacedAt time.Time) ([]byte, error) {
+	return json.Marshal(revisionRecord{
+		Bucket:     parentBucketName,
+		ID:         formatID(key),
+		ReplacedAt: replacedAt,
+		Value:      v,
+	})
+}
+
+func decodeRevisionRecord(v []byte) (revisionRecord, error) {
+	var r revisionRecord
+	err := json.Unmarshal(v, &r)
+	return r, err
+}
+
+func (r revisionRecord) revision(home *time.Location) (revision, error) {
+	key, err := parseID(r.ID)
+	if err != nil {
+		return revision{}, err
+	}
+	e, err := decodeEntry(key, r.Value, home)
+	if err != nil {
+		return revision{}, err
+	}
+	return revision{entry: e, replacedAt: r.ReplacedAt}, nil
+}
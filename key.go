@@ -0,0 +1,84 @@
+package godid
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// timeFormat is the format of the legacy, second precision entry keys
+	timeFormat = time.RFC3339
+	keyLength  = 16
+)
+
+// encodeKey builds a key that sorts in time order and can't collide with the keys of entries logged at the same time.
+// It's made out of the nanosecond timestamp followed by a sequence number assigned by the store.
+func encodeKey(t time.Time, seq uint64) []byte {
+	key := make([]byte, keyLength)
+	// flipping the sign bit keeps timestamps before the epoch sorted
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano())^(1<<63))
+	binary.BigEndian.PutUint64(key[8:], seq)
+	return key
+}
+
+// decodeKey extracts the timestamp from both collision-proof and legacy keys
+func decodeKey(k []byte) (time.Time, error) {
+	if isKey(k) {
+		return time.Unix(0, int64(binary.BigEndian.Uint64(k)^(1<<63))), nil
+	}
+	return time.Parse(timeFormat, string(k))
+}
+
+// formatID returns the printable id of the entry stored under key
+func formatID(key []byte) string {
+	return strconv.FormatUint(binary.BigEndian.Uint64(key), 36) + "-" + strconv.FormatUint(keySequence(key), 36)
+}
+
+// parseID returns the key of the entry identified by id
+func parseID(id string) ([]byte, error) {
+	invalid := didErrorf("invalid entry id %s", id)
+	timePart, seqPart, ok := strings.Cut(id, "-")
+	if !ok {
+		return nil, invalid
+	}
+	t, err := strconv.ParseUint(timePart, 36, 64)
+	if err != nil {
+		return nil, invalid
+	}
+	seq, err := strconv.ParseUint(seqPart, 36, 64)
+	if err != nil {
+		return nil, invalid
+	}
+	key := make([]byte, keyLength)
+	binary.BigEndian.PutUint64(key, t)
+	binary.BigEndian.PutUint64(key[8:], seq)
+	return key, nil
+}
+
+// keptKey returns the key of the id an entry comes with, e.g. when copied from another store, nil when it has none.
+// The ids of another time than the timestamp of the entry are left out, the key telling the time of the entry.
+func keptKey(e entry) []byte {
+	if e.ID == "" {
+		return nil
+	}
+	key, err := parseID(e.ID)
+	if err != nil {
+		return nil
+	}
+	if t, err := decodeKey(key); err != nil || !t.Equal(e.Timestamp) {
+		return nil
+	}
+	return key
+}
+
+// isKey checks whether k is a collision-proof key. Legacy keys are always longer.
+func isKey(k []byte) bool {
+	return len(k) == keyLength
+}
+
+// keySequence returns the sequence number of a collision-proof key
+func keySequence(key []byte) uint64 {
+	return binary.BigEndian.Uint64(key[8:])
+}
@@ -0,0 +1,44 @@
+package godid
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeKey(t *testing.T) {
+	ts := timeFromString(t, "2018-07-18T12:11:00Z")
+	key := encodeKey(ts, 1)
+	require.Len(t, key, keyLength)
+	actual, err := decodeKey(key)
+	require.NoError(t, err)
+	assert.True(t, ts.Equal(actual))
+
+	assert.NotEqual(t, key, encodeKey(ts, 2), "same timestamp must not collide")
+	assert.Equal(t, -1, bytes.Compare(key, encodeKey(ts.Add(time.Nanosecond), 0)))
+	assert.Equal(t, -1, bytes.Compare(encodeKey(time.Unix(-10, 0), 5), encodeKey(time.Unix(10, 0), 0)))
+}
+
+func TestDecodeKey(t *testing.T) {
+	actual, err := decodeKey([]byte("2018-07-18T12:11:00+03:00"))
+	require.NoError(t, err)
+	assert.True(t, timeFromString(t, "2018-07-18T09:11:00Z").Equal(actual))
+
+	_, err = decodeKey([]byte("garbage"))
+	require.Error(t, err)
+}
+
+func TestParseID(t *testing.T) {
+	key := encodeKey(timeFromString(t, "2018-07-18T12:11:00Z"), 42)
+	actual, err := parseID(formatID(key))
+	require.NoError(t, err)
+	assert.Equal(t, key, actual)
+
+	for _, id := range []string{"", "abc", "abc-", "-1", "abc-!", "zzzzzzzzzzzzzzzzzzzz-1"} {
+		_, err := parseID(id)
+		assert.IsType(t, DidError{}, err, id)
+	}
+}
@@ -0,0 +1,57 @@
+package godid
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+)
+
+// lockInfo describes the process holding the store open for writing. It's kept in a file next to the store so the
+// processes waiting for the store can tell who they are waiting for.
+type lockInfo struct {
+	PID     int    `json:"pid"`
+	Command string `json:"command"`
+}
+
+func lockInfoPath(storePath string) string {
+	return storePath + ".lock"
+}
+
+// writeLockInfo records the current process as the holder of the store
+func writeLockInfo(storePath string) error {
+	info, err := json.Marshal(lockInfo{
+		PID:     os.Getpid(),
+		Command: strings.Join(os.Args, " "),
+	})
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(lockInfoPath(storePath), info, 0600)
+}
+
+// removeLockInfo forgets the holder of the store, if it's still the current process
+func removeLockInfo(storePath string) {
+	info, err := readLockInfo(storePath)
+	if err == nil && info.PID == os.Getpid() {
+		os.Remove(lockInfoPath(storePath))
+	}
+}
+
+func readLockInfo(storePath string) (lockInfo, error) {
+	var info lockInfo
+	content, err := os.ReadFile(lockInfoPath(storePath))
+	if err != nil {
+		return info, err
+	}
+	err = json.Unmarshal(content, &info)
+	return info, err
+}
+
+// lockedError describes the process holding the store, when known
+func lockedError(storePath string) DidError {
+	info, err := readLockInfo(storePath)
+	if err != nil {
+		return didErrorf("the store %s is locked by another process, try again once it's done", storePath)
+	}
+	return didErrorf("the store %s is locked by process %d (%s), try again once it's done", storePath, info.PID, info.Command)
+}
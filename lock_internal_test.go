@@ -0,0 +1,91 @@
+package godid
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigLockTimeout(t *testing.T) {
+	timeout, err := (&config{}).GetLockTimeout()
+	require.NoError(t, err)
+	assert.Equal(t, defaultLockTimeout, timeout)
+	timeout, err = (&config{LockTimeout: "2s"}).GetLockTimeout()
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Second, timeout)
+	_, err = (&config{LockTimeout: "soon"}).GetLockTimeout()
+	assert.IsType(t, DidError{}, err)
+	_, err = (&config{LockTimeout: "-1s"}).GetLockTimeout()
+	assert.IsType(t, DidError{}, err)
+}
+
+func TestBoltStoreLocked(t *testing.T) {
+	defer cleanupTestBoltStore()
+	cfg := config{StorePath: "test.db", LockTimeout: "100ms"}
+	writer := getTestBoltStore(t, cfg)
+	defer writer.Close()
+
+	_, err := newBoltStore(cfg, false)
+	require.IsType(t, DidError{}, err)
+	assert.Contains(t, err.Error(), fmt.Sprintf("locked by process %d", os.Getpid()))
+	_, err = newBoltStore(cfg, true)
+	assert.IsType(t, DidError{}, err, "readers should wait for the writer too")
+
+	require.NoError(t, os.Remove(lockInfoPath("test.db")))
+	_, err = newBoltStore(cfg, false)
+	require.IsType(t, DidError{}, err)
+	assert.Contains(t, err.Error(), "locked by another process")
+}
+
+func TestBoltStoreReadOnly(t *testing.T) {
+	defer cleanupTestBoltStore()
+	cfg := config{StorePath: "test.db", LockTimeout: "100ms"}
+	ts := timeFromString(t, "2018-07-18T12:11:00Z")
+	writer := getTestBoltStore(t, cfg)
+	putEntry(t, writer, "bucket", entry{Timestamp: ts, Content: []byte("msg1")})
+	require.NoError(t, writer.Close())
+	assert.NoFileExists(t, lockInfoPath("test.db"))
+
+	first, err := newBoltStore(cfg, true)
+	require.NoError(t, err)
+	defer first.Close()
+	second, err := newBoltStore(cfg, true)
+	require.NoError(t, err, "readers should share the store")
+	defer second.Close()
+	assert.NoFileExists(t, lockInfoPath("test.db"))
+
+	entries, err := second.GetRange("bucket", ts, ts)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+	_, err = first.Put("bucket", entry{Timestamp: ts, Content: []byte("msg2")})
+	assert.Error(t, err)
+
+	_, err = newBoltStore(cfg, false)
+	assert.IsType(t, DidError{}, err, "writers should wait for the readers")
+}
+
+func TestNewStoreReadOnly(t *testing.T) {
+	defer cleanupTestBoltStore()
+	defer cleanupTestSQLiteStore()
+	for _, cfg := range []config{
+		{StorePath: "test.db"},
+		{StorePath: "test.sqlite", Backend: backendSQLite},
+	} {
+		cleanupTestBoltStore()
+		cleanupTestSQLiteStore()
+		s, err := newStore(cfg, Options{ReadOnly: true})
+		require.NoError(t, err, "a missing store should be created")
+		if m, ok := s.(migrator); ok {
+			results, err := m.Migrate(true)
+			require.NoError(t, err)
+			assert.Empty(t, results, "the store should be migrated before being opened read only")
+		}
+		_, err = s.Put("bucket", entry{Timestamp: time.Now(), Content: []byte("msg1")})
+		assert.Error(t, err)
+		require.NoError(t, s.Close())
+	}
+}
@@ -2,8 +2,9 @@ package godid
 
 import (
 	"errors"
-	"regexp"
-	"strconv"
+	"io/fs"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/samber/lo"
@@ -13,28 +14,43 @@ import (
 const (
 	flatEntriesPlaceholder = "all entries"
 	rootBucketName         = "root"
+	// entryTimeFormat is how the times of the entries are written in the errors
+	entryTimeFormat = "2006-01-02 15:04:05"
 )
 
 var (
-	lastDurationPattern = regexp.MustCompile(`^(\d+)d$`)
-)
-
-var (
-	store           entryStore
-	flatAggregation = func(entries []entry) (any, error) {
-		return lo.Map(entries, func(e entry, _ int) string {
-			return string(e.Content)
+	store entryStore
+	// archive holds the entries moved out of the store, opened by openArchive on first use
+	archive         entryStore
+	archiveReadOnly bool
+	// archiveConfig describes the archive store, there's none when nil
+	archiveConfig *config
+	// retention holds the retention policies applied by ApplyRetention
+	retention *retentionConfig
+	// location is used to interpret the query intervals and to group the entries per day
+	location = time.Local
+	// groupByBucket makes the queries that aren't flat group the entries by parent bucket rather than per day
+	groupByBucket bool
+	// weekStart is the first day of the weeks of the week queries and of the date expressions
+	weekStart = time.Monday
+	// workDays are the working days, the other ones being left out of the queries with hideNonWorkingDays
+	workDays           = defaultWorkDays
+	hideNonWorkingDays bool
+	// allowFutureEntries lets the entries be logged at times that haven't come yet
+	allowFutureEntries bool
+	flatAggregation    = func(entries []entry) (any, error) {
+		return lo.Map(entries, func(e entry, _ int) Entry {
+			return e.public()
 		}), nil
 	}
 	perDayAggregation = func(entries []entry) (any, error) {
-		result := make(map[string][]string)
+		result := make(map[string][]Entry)
 		for _, entry := range entries {
-			content := string(entry.Content)
-			bucket, err := getBucketFromEntry(entry)
+			bucket, err := getBucketFromEntry(entry, location)
 			if err != nil {
 				return nil, err
 			}
-			result[bucket] = append(result[bucket], content)
+			result[bucket] = append(result[bucket], entry.public())
 		}
 		return result, nil
 	}
@@ -42,22 +58,265 @@ var (
 
 // Init initialises godid
 func Init() {
+	if err := InitWithOptions(Options{}); err != nil {
+		panic(err)
+	}
+}
+
+// InitWithOptions initialises godid, opening the store as described by opts
+func InitWithOptions(opts Options) error {
 	cfg, err := getConfig()
 	if err != nil {
-		panic(err)
+		return err
 	}
 	if cfg == nil {
-		panic(errors.New("null config"))
+		return errors.New("null config")
 	}
-	store, err = newBoltStore(*cfg)
+	location, err = cfg.GetLocation()
 	if err != nil {
-		panic(err)
+		return err
+	}
+	weekStart, err = cfg.GetWeekStart()
+	if err != nil {
+		return err
+	}
+	workDays, err = cfg.GetWorkDays()
+	if err != nil {
+		return err
+	}
+	store, err = newStore(*cfg, opts)
+	if err != nil {
+		getLogger().WithFields(logrus.Fields{
+			"component": "manager",
+			"method":    "Init",
+			"readOnly":  opts.ReadOnly,
+		}).WithError(err).Error("failed to open store")
+		return err
+	}
+	archiveCfg, err := archiveConfigFor(*cfg)
+	if err != nil {
+		return err
+	}
+	archiveConfig, retention = &archiveCfg, cfg.Retention
+	if cfg.Backup != nil {
+		autoBackup(*cfg)
+	}
+	return nil
+}
+
+// SetTimezone overrides the home timezone used to interpret the query intervals and to group the entries per day
+func SetTimezone(name string) error {
+	loc, err := loadLocation(name)
+	if err != nil {
+		return err
+	}
+	location = loc
+	return nil
+}
+
+// SetGroupByBucket makes the queries that aren't flat group the entries by the path of their parent bucket, e.g.
+// work/payments, rather than per day. The entries of the buckets nested in the queried one are always included.
+func SetGroupByBucket(group bool) {
+	groupByBucket = group
+}
+
+// SetHideNonWorkingDays makes the queries leave out the entries logged on the days that aren't among the work_days of
+// the config, Monday through Friday by default
+func SetHideNonWorkingDays(hide bool) {
+	hideNonWorkingDays = hide
+}
+
+// SetAllowFutureEntries lets AddEntryAt log entries at times that haven't come yet, which it rejects by default
+func SetAllowFutureEntries(allow bool) {
+	allowFutureEntries = allow
+}
+
+// Location returns the location used by the queries
+func Location() *time.Location {
+	return location
+}
+
+// newStore creates the entryStore for the backend selected in the config, bringing it to the latest schema version
+// unless opts say otherwise. The store is reached through the daemon when one is running, unless opts pick the
+// backend.
+func newStore(cfg config, opts Options) (entryStore, error) {
+	if opts.Backend != "" {
+		cfg.Backend = opts.Backend
+	} else {
+		remote, err := dialDaemon(cfg)
+		if err == nil {
+			return remote, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			getLogger().WithFields(logrus.Fields{
+				"component": "manager",
+				"method":    "newStore",
+			}).WithError(err).Warn("failed to reach the daemon, opening the store directly")
+		}
+	}
+	s, err := openStore(cfg, opts.ReadOnly)
+	if opts.ReadOnly && (errors.Is(err, fs.ErrNotExist) || errors.Is(err, errReadOnly)) {
+		return prepareReadOnlyStore(cfg, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if m, ok := s.(migrator); ok && !opts.SkipMigrations {
+		if _, err := m.Migrate(false); err != nil {
+			s.Close()
+			if opts.ReadOnly && errors.Is(err, errReadOnly) {
+				return prepareReadOnlyStore(cfg, opts)
+			}
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// prepareReadOnlyStore creates or upgrades the store through a writable open, which a read only store can't do,
+// then opens it read only
+func prepareReadOnlyStore(cfg config, opts Options) (entryStore, error) {
+	writable := opts
+	writable.ReadOnly = false
+	s, err := newStore(cfg, writable)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Close(); err != nil {
+		return nil, err
+	}
+	return openStore(cfg, true)
+}
+
+func openStore(cfg config, readOnly bool) (entryStore, error) {
+	var (
+		s   entryStore
+		err error
+	)
+	switch cfg.Backend {
+	case "", backendBolt:
+		s, err = newBoltStore(cfg, readOnly)
+	case backendSQLite:
+		s, err = newSQLiteStore(cfg, readOnly)
+	case backendMemory:
+		s, err = newMemoryStore(cfg)
+	case backendMarkdown:
+		s, err = newMarkdownStore(cfg)
+	default:
+		return nil, didErrorf("unknown backend %s", cfg.Backend)
+	}
+	if err != nil {
+		return nil, err
 	}
+	return s, nil
 }
 
 // Close closes godid
 func Close() {
 	store.Close()
+	if archive != nil {
+		archive.Close()
+		archive = nil
+	}
+}
+
+// Migrate brings the store to the latest schema version and reports the applied migrations.
+// With dryRun the migrations are only reported, the store is left untouched.
+func Migrate(dryRun bool) ([]MigrationResult, error) {
+	m, ok := store.(migrator)
+	if !ok {
+		return []MigrationResult{}, nil
+	}
+	results, err := m.Migrate(dryRun)
+	if err != nil {
+		getLogger().WithFields(logrus.Fields{
+			"component": "manager",
+			"method":    "Migrate",
+			"dryRun":    dryRun,
+		}).WithError(err).Error("failed to migrate store")
+	}
+	return results, err
+}
+
+// Backup writes a consistent snapshot of the store to path, the store can keep being used meanwhile
+func Backup(path string) error {
+	b, ok := store.(backuper)
+	if !ok {
+		return didErrorf("the store doesn't support backups")
+	}
+	err := b.Backup(path)
+	if err != nil {
+		getLogger().WithFields(logrus.Fields{
+			"component": "manager",
+			"method":    "Backup",
+			"path":      path,
+		}).WithError(err).Error("failed to back up store")
+	}
+	return err
+}
+
+// Restore replaces the store with the snapshot at path, written by Backup. The snapshot is checked beforehand, the
+// store is left untouched when it's invalid.
+func Restore(path string) error {
+	b, ok := store.(backuper)
+	if !ok {
+		return didErrorf("the store doesn't support backups")
+	}
+	err := b.Restore(path)
+	if err == nil {
+		// snapshots taken by older versions are brought to the current schema
+		if m, ok := store.(migrator); ok {
+			_, err = m.Migrate(false)
+		}
+	}
+	if err != nil {
+		getLogger().WithFields(logrus.Fields{
+			"component": "manager",
+			"method":    "Restore",
+			"path":      path,
+		}).WithError(err).Error("failed to restore store")
+	}
+	return err
+}
+
+// Check looks for inconsistencies in the store, like entries filed under the wrong day or that can't be read. With
+// repair the misfiled entries are moved to their day and the unreadable ones are set aside in quarantine.
+func Check(repair bool) ([]CheckProblem, error) {
+	c, ok := store.(checker)
+	if !ok {
+		return nil, didErrorf("the store doesn't support checking")
+	}
+	problems, err := c.Check(repair)
+	if err != nil {
+		getLogger().WithFields(logrus.Fields{
+			"component": "manager",
+			"method":    "Check",
+			"repair":    repair,
+		}).WithError(err).Error("failed to check store")
+	}
+	return problems, err
+}
+
+// autoBackup takes the automatic backups described by the config. Failures are only logged, they must not get in
+// the way of logging entries.
+func autoBackup(cfg config) {
+	logger := getLogger().WithFields(logrus.Fields{
+		"component": "manager",
+		"method":    "autoBackup",
+	})
+	b, ok := store.(backuper)
+	if !ok {
+		logger.Warn("the store doesn't support backups")
+		return
+	}
+	storePath, err := cfg.GetStorePath()
+	if err != nil {
+		logger.WithError(err).Error("failed to get store path")
+		return
+	}
+	if err := rotateBackups(*cfg.Backup, b, filepath.Ext(storePath), time.Now()); err != nil {
+		logger.WithError(err).Error("failed to take automatic backup")
+	}
 }
 
 // AddEntry adds an entry to the underlying store in the root bucket
@@ -67,29 +326,361 @@ func AddEntry(what string) error {
 
 // AddEntryToBucket adds an entry to the underlying store in the specified parent bucket
 func AddEntryToBucket(bucket string, what string) error {
+	return AddEntryToBucketWithMetadata(bucket, what, Metadata{})
+}
+
+// AddEntryWithMetadata adds an entry carrying the given metadata to the underlying store in the root bucket
+func AddEntryWithMetadata(what string, meta Metadata) error {
+	return AddEntryToBucketWithMetadata(rootBucketName, what, meta)
+}
+
+// AddEntryToBucketWithMetadata adds an entry carrying the given metadata to the underlying store in the specified
+// parent bucket
+func AddEntryToBucketWithMetadata(bucket string, what string, meta Metadata) error {
+	return addEntry(bucket, what, time.Now(), meta)
+}
+
+// AddEntryAt adds an entry logged at the given time to the underlying store in the specified parent bucket, e.g. to
+// record a task that was forgotten. Times in the future are rejected, see SetAllowFutureEntries.
+func AddEntryAt(bucket string, what string, at time.Time) error {
+	return AddEntryAtWithMetadata(bucket, what, at, Metadata{})
+}
+
+// AddEntryAtWithMetadata adds an entry carrying the given metadata and logged at the given time to the underlying
+// store in the specified parent bucket
+func AddEntryAtWithMetadata(bucket string, what string, at time.Time, meta Metadata) error {
+	if at.IsZero() {
+		return didErrorf("the time of the entry is missing")
+	}
+	if !allowFutureEntries && at.After(time.Now()) {
+		return didErrorf("the time of the entry, %s, is in the future", at.Format(entryTimeFormat))
+	}
+	return addEntry(bucket, what, at, meta)
+}
+
+func addEntry(bucket string, what string, at time.Time, meta Metadata) error {
 	e := entry{
 		Content:   []byte(what),
-		Timestamp: time.Now(),
+		Timestamp: at,
+		Metadata:  meta,
 	}
-	err := store.Put(bucket, e)
+	id, err := store.Put(bucket, e)
 	if err != nil {
 		getLogger().WithFields(logrus.Fields{
 			"component": "manager",
 			"method":    "AddEntry",
 			"entry":     what,
 		}).WithError(err).Error("failed to put entry")
+		return err
+	}
+	recordChange(change{Kind: ChangeAdd, Bucket: bucket, ID: id, Timestamp: e.Timestamp, Content: what})
+	return nil
+}
+
+// GetEntry retrieves the entry with the given id from the root bucket
+func GetEntry(id string) (Entry, error) {
+	return GetEntryFromBucket(rootBucketName, id)
+}
+
+// GetEntryFromBucket retrieves the entry with the given id from the specified parent bucket
+func GetEntryFromBucket(bucket string, id string) (Entry, error) {
+	e, err := store.Get(bucket, id)
+	if err != nil {
+		getLogger().WithFields(logrus.Fields{
+			"component": "manager",
+			"method":    "GetEntry",
+			"id":        id,
+		}).WithError(err).Error("failed to get entry")
+		return Entry{}, err
+	}
+	return e.public(), nil
+}
+
+// UpdateEntry replaces the content of the entry with the given id from the root bucket
+func UpdateEntry(id string, what string) error {
+	return UpdateEntryInBucket(rootBucketName, id, what)
+}
+
+// UpdateEntryInBucket replaces the content of the entry with the given id from the specified parent bucket, keeping
+// its metadata
+func UpdateEntryInBucket(bucket string, id string, what string) error {
+	e, err := store.Get(bucket, id)
+	var previous []byte
+	if err == nil {
+		previous, err = encodeValue(e)
+	}
+	if err == nil {
+		e.Content = []byte(what)
+		err = store.Update(bucket, e)
+	}
+	if err != nil {
+		getLogger().WithFields(logrus.Fields{
+			"component": "manager",
+			"method":    "UpdateEntry",
+			"id":        id,
+			"entry":     what,
+		}).WithError(err).Error("failed to update entry")
+		return err
+	}
+	recordChange(change{Kind: ChangeEdit, Bucket: bucket, ID: id, Previous: previous})
+	return nil
+}
+
+// GetEntryHistory returns the versions of the entry with the given id from the root bucket
+func GetEntryHistory(id string) ([]Revision, error) {
+	return GetEntryHistoryFromBucket(rootBucketName, id)
+}
+
+// GetEntryHistoryFromBucket returns the versions of the entry with the given id from the specified parent bucket, the
+// oldest first. Every edit keeps the version it replaced, the last one returned being the current version unless the
+// entry was deleted.
+func GetEntryHistoryFromBucket(bucket string, id string) ([]Revision, error) {
+	logger := getLogger().WithFields(logrus.Fields{
+		"component": "manager",
+		"method":    "GetEntryHistory",
+		"id":        id,
+	})
+	h, ok := store.(historian)
+	if !ok {
+		return nil, didErrorf("the store doesn't keep the history of the entries")
+	}
+	revisions, err := h.History(bucket, id)
+	if err != nil {
+		logger.WithError(err).Error("failed to get history")
+		return nil, err
+	}
+	result := lo.Map(revisions, func(r revision, _ int) Revision {
+		return r.public()
+	})
+	current, err := store.Get(bucket, id)
+	if _, ok := err.(DidError); ok && len(result) > 0 {
+		return result, nil
+	}
+	if err != nil {
+		logger.WithError(err).Error("failed to get entry")
+		return nil, err
+	}
+	return append(result, Revision{Entry: current.public()}), nil
+}
+
+// DeleteEntry moves the entry with the given id from the root bucket to the trash
+func DeleteEntry(id string) error {
+	return DeleteEntryFromBucket(rootBucketName, id)
+}
+
+// DeleteEntryFromBucket moves the entry with the given id from the specified parent bucket to the trash, see
+// RestoreEntryToBucket
+func DeleteEntryFromBucket(bucket string, id string) error {
+	t, ok := store.(trasher)
+	if !ok {
+		return didErrorf("the store doesn't support the trash")
+	}
+	err := t.Trash(bucket, id, time.Now())
+	if err != nil {
+		getLogger().WithFields(logrus.Fields{
+			"component": "manager",
+			"method":    "DeleteEntry",
+			"id":        id,
+		}).WithError(err).Error("failed to delete entry")
+		return err
+	}
+	recordChange(change{Kind: ChangeDelete, Bucket: bucket, ID: id})
+	return nil
+}
+
+// ListTrash returns the entries deleted into the trash from all the parent buckets, the most recently deleted first
+func ListTrash() ([]TrashedEntry, error) {
+	t, ok := store.(trasher)
+	if !ok {
+		return nil, didErrorf("the store doesn't support the trash")
+	}
+	trashed, err := t.ListTrash()
+	if err != nil {
+		getLogger().WithFields(logrus.Fields{
+			"component": "manager",
+			"method":    "ListTrash",
+		}).WithError(err).Error("failed to list trash")
+		return nil, err
+	}
+	return lo.Map(trashed, func(te trashedEntry, _ int) TrashedEntry {
+		return te.public()
+	}), nil
+}
+
+// RestoreEntry puts the entry with the given id back from the trash into the parent bucket it was deleted from. The
+// ids being unique only within a parent bucket, an id found in the trash of several ones must be restored with
+// RestoreEntryToBucket.
+func RestoreEntry(id string) (Entry, error) {
+	trashed, err := ListTrash()
+	if err != nil {
+		return Entry{}, err
+	}
+	buckets := lo.Uniq(lo.FilterMap(trashed, func(t TrashedEntry, _ int) (string, bool) {
+		return t.Bucket, t.ID == id
+	}))
+	switch len(buckets) {
+	case 0:
+		return Entry{}, didErrorf("no entry with id %s in the trash", id)
+	case 1:
+		return RestoreEntryToBucket(buckets[0], id)
+	default:
+		return Entry{}, didErrorf("entries with id %s were deleted from buckets %s, pick the one to restore",
+			id, strings.Join(buckets, ", "))
+	}
+}
+
+// RestoreEntryToBucket puts the entry with the given id, deleted from the specified parent bucket, back from the
+// trash. It keeps its id.
+func RestoreEntryToBucket(bucket string, id string) (Entry, error) {
+	t, ok := store.(trasher)
+	if !ok {
+		return Entry{}, didErrorf("the store doesn't support the trash")
+	}
+	e, err := t.Untrash(bucket, id)
+	if err != nil {
+		getLogger().WithFields(logrus.Fields{
+			"component": "manager",
+			"method":    "RestoreEntry",
+			"id":        id,
+		}).WithError(err).Error("failed to restore entry")
+		return Entry{}, err
+	}
+	return e.public(), nil
+}
+
+// EmptyTrash deletes the entries in the trash for good, returning how many there were
+func EmptyTrash() (int, error) {
+	t, ok := store.(trasher)
+	if !ok {
+		return 0, didErrorf("the store doesn't support the trash")
+	}
+	count, err := t.EmptyTrash()
+	if err != nil {
+		getLogger().WithFields(logrus.Fields{
+			"component": "manager",
+			"method":    "EmptyTrash",
+		}).WithError(err).Error("failed to empty trash")
+	}
+	return count, err
+}
+
+// Undo reverses the last entry added, edited or deleted, or the last bucket deleted, returning what it did. Added
+// entries are moved to the trash, edited ones get their previous content back and deleted ones, those of a bucket
+// included, are restored from the trash. Only the last change can be undone.
+func Undo() (Change, error) {
+	logger := getLogger().WithFields(logrus.Fields{
+		"component": "manager",
+		"method":    "Undo",
+	})
+	u, ok := store.(undoer)
+	if !ok {
+		return Change{}, didErrorf("the store doesn't support undo")
+	}
+	c, err := u.LastChange()
+	if err != nil {
+		logger.WithError(err).Error("failed to get the last change")
+		return Change{}, err
+	}
+	if c == nil {
+		return Change{}, didErrorf("nothing to undo")
+	}
+	result := Change{Kind: c.Kind, Bucket: c.Bucket}
+	if c.Kind == ChangeDeleteBucket {
+		result.Entries, err = undoDeleteBucket(*c)
+	} else {
+		var e entry
+		e, err = undoChange(*c)
+		result.Entry = e.public()
+	}
+	if err == nil {
+		err = u.SetLastChange(nil)
+	}
+	if err != nil {
+		logger.WithField("kind", c.Kind).WithError(err).Error("failed to undo the last change")
+		return Change{}, err
+	}
+	return result, nil
+}
+
+func undoChange(c change) (entry, error) {
+	t, ok := store.(trasher)
+	if !ok {
+		return entry{}, didErrorf("the store doesn't support the trash")
+	}
+	switch c.Kind {
+	case ChangeAdd:
+		e, err := store.Get(c.Bucket, c.ID)
+		if err != nil {
+			return entry{}, err
+		}
+		return e, t.Trash(c.Bucket, e.ID, time.Now())
+	case ChangeEdit:
+		key, err := parseID(c.ID)
+		if err != nil {
+			return entry{}, err
+		}
+		previous, err := decodeEntry(key, c.Previous, location)
+		if err != nil {
+			return entry{}, err
+		}
+		return previous, store.Update(c.Bucket, previous)
+	case ChangeDelete:
+		return t.Untrash(c.Bucket, c.ID)
+	default:
+		return entry{}, didErrorf("unknown change %s", c.Kind)
+	}
+}
+
+// undoDeleteBucket restores the entries of a deleted bucket from the trash, returning how many there were. The ones
+// restored or emptied from the trash since are skipped.
+func undoDeleteBucket(c change) (int, error) {
+	t, ok := store.(trasher)
+	if !ok {
+		return 0, didErrorf("the store doesn't support the trash")
+	}
+	trashed, err := t.ListTrash()
+	if err != nil {
+		return 0, err
+	}
+	restored := 0
+	for _, te := range trashed {
+		if !lo.Contains(c.Deleted[te.parentBucketName], te.ID) {
+			continue
+		}
+		if _, err := t.Untrash(te.parentBucketName, te.ID); err != nil {
+			return restored, err
+		}
+		restored++
+	}
+	if restored == 0 {
+		return 0, didErrorf("the entries of bucket %s aren't in the trash anymore", c.Bucket)
+	}
+	return restored, nil
+}
+
+// recordChange keeps the change so it can be undone. Failures are only logged, the change was made already.
+func recordChange(c change) {
+	u, ok := store.(undoer)
+	if !ok {
+		return
+	}
+	if err := u.SetLastChange(&c); err != nil {
+		getLogger().WithFields(logrus.Fields{
+			"component": "manager",
+			"method":    "recordChange",
+			"kind":      c.Kind,
+		}).WithError(err).Warn("failed to record the change, it can't be undone")
 	}
-	return err
 }
 
 // GetToday retrieves all entries logged today from the root bucket
-func GetToday() ([]string, error) {
+func GetToday() ([]Entry, error) {
 	return GetTodayFromBucket(rootBucketName)
 }
 
 // GetTodayFromBucket retrieves all entries logged today from the specified bucket
-func GetTodayFromBucket(bucketName string) ([]string, error) {
-	start := time.Now()
+func GetTodayFromBucket(bucketName string) ([]Entry, error) {
+	start := now()
 	result, err := getRange(bucketName, start, start, true)
 	if err != nil {
 		getLogger().WithFields(logrus.Fields{
@@ -103,13 +694,13 @@ func GetTodayFromBucket(bucketName string) ([]string, error) {
 }
 
 // GetYesterday retrieves all entries logged yesterday from the root bucket
-func GetYesterday() ([]string, error) {
+func GetYesterday() ([]Entry, error) {
 	return GetYesterdayFromBucket(rootBucketName)
 }
 
 // GetYesterdayFromBucket retrieves all entries logged yesterday from the specified bucket
-func GetYesterdayFromBucket(bucketName string) ([]string, error) {
-	start := time.Now().AddDate(0, 0, -1)
+func GetYesterdayFromBucket(bucketName string) ([]Entry, error) {
+	start := now().AddDate(0, 0, -1)
 	result, err := getRange(bucketName, start, start, true)
 	if err != nil {
 		getLogger().WithFields(logrus.Fields{
@@ -123,13 +714,13 @@ func GetYesterdayFromBucket(bucketName string) ([]string, error) {
 }
 
 // GetThisWeek returns all entries from the current week from the root bucket
-func GetThisWeek(flat bool) (map[string][]string, error) {
+func GetThisWeek(flat bool) (map[string][]Entry, error) {
 	return GetThisWeekFromBucket(rootBucketName, flat)
 }
 
 // GetThisWeekFromBucket returns all entries from the current week from the specified bucket
-func GetThisWeekFromBucket(bucketName string, flat bool) (map[string][]string, error) {
-	start, end := getWeekInterval(time.Now())
+func GetThisWeekFromBucket(bucketName string, flat bool) (map[string][]Entry, error) {
+	start, end := getWeekInterval(now())
 	result, err := getRange(bucketName, start, end, flat)
 	if err != nil {
 		getLogger().WithFields(logrus.Fields{
@@ -144,13 +735,13 @@ func GetThisWeekFromBucket(bucketName string, flat bool) (map[string][]string, e
 }
 
 // GetLastWeek returns all entries from the previous week from the root bucket
-func GetLastWeek(flat bool) (map[string][]string, error) {
+func GetLastWeek(flat bool) (map[string][]Entry, error) {
 	return GetLastWeekFromBucket(rootBucketName, flat)
 }
 
 // GetLastWeekFromBucket returns all entries from the previous week from the specified bucket
-func GetLastWeekFromBucket(bucketName string, flat bool) (map[string][]string, error) {
-	aWeekBefore := time.Now().AddDate(0, 0, -7)
+func GetLastWeekFromBucket(bucketName string, flat bool) (map[string][]Entry, error) {
+	aWeekBefore := now().AddDate(0, 0, -7)
 	start, end := getWeekInterval(aWeekBefore)
 	result, err := getRange(bucketName, start, end, flat)
 	if err != nil {
@@ -165,81 +756,560 @@ func GetLastWeekFromBucket(bucketName string, flat bool) (map[string][]string, e
 	return result, err
 }
 
-// GetLastDuration retrives all the entries from the custom previous duration from the root bucket
-func GetLastDuration(durationString string, flat bool) (map[string][]string, error) {
+// GetLastDuration retrives all the entries from the custom previous duration from the root bucket. The duration
+// counts years, months, weeks, days and hours, e.g. 2w, 3m, 1y, 4h or 1w3d.
+func GetLastDuration(durationString string, flat bool) (map[string][]Entry, error) {
 	return GetLastDurationFromBucket(rootBucketName, durationString, flat)
 }
 
-// GetLastDurationFromBucket retrives all the entries from the custom previous duration from the specified bucket
-func GetLastDurationFromBucket(bucketName string, durationString string, flat bool) (map[string][]string, error) {
+// GetLastDurationFromBucket retrives all the entries from the custom previous duration from the specified bucket. The
+// months and years are calendar ones. The durations without hours cover whole days, the ones with hours start at the
+// exact time, e.g. 4h for the last four hours.
+func GetLastDurationFromBucket(bucketName string, durationString string, flat bool) (map[string][]Entry, error) {
 
 	d, err := parseDuration(durationString)
 	if err != nil {
 		return nil, err
 	}
-	now := time.Now()
-	result, err := getRange(bucketName, now.Add(-1*d), now, flat)
+	end := now()
+	start := d.before(end)
+	result, err := getRange(bucketName, start, end, flat)
 	if err != nil {
 		getLogger().WithFields(logrus.Fields{
 			"component": "manager",
 			"method":    "GetThisWeek",
-			"start":     now.Add(-1 * d),
-			"end":       now,
+			"start":     start,
+			"end":       end,
+			"flat":      flat,
+		}).WithError(err).Error("failed to get entries")
+		return nil, err
+	}
+	if d.hours > 0 {
+		dropEntries(result, func(e Entry) bool {
+			return e.Timestamp.Before(start)
+		})
+	}
+	return result, nil
+}
+
+// GetRange retrieves all the entries logged from the day of from to the day of to, inclusive, from the root bucket
+func GetRange(from, to time.Time, flat bool) (map[string][]Entry, error) {
+	return GetRangeFromBucket(rootBucketName, from, to, flat)
+}
+
+// GetRangeFromBucket retrieves all the entries logged from the day of from to the day of to, inclusive, from the
+// specified bucket. Only the dates of from and to are used, the days being those of the timezone of the queries.
+func GetRangeFromBucket(bucketName string, from, to time.Time, flat bool) (map[string][]Entry, error) {
+	if err := checkBucketName(bucketName); err != nil {
+		return nil, err
+	}
+	if from.IsZero() {
+		return nil, didErrorf("the start of the range is missing")
+	}
+	if to.IsZero() {
+		return nil, didErrorf("the end of the range is missing")
+	}
+	start, end := dayIn(from, location), dayIn(to, location)
+	if start.After(end) {
+		return nil, didErrorf("the start of the range, %s, is after its end, %s",
+			start.Format(dayFormat), end.Format(dayFormat))
+	}
+	result, err := getRange(bucketName, start, end, flat)
+	if err != nil {
+		getLogger().WithFields(logrus.Fields{
+			"component": "manager",
+			"method":    "GetRange",
+			"start":     start,
+			"end":       end,
 			"flat":      flat,
 		}).WithError(err).Error("failed to get entries")
 	}
 	return result, err
 }
 
-func parseDuration(durationString string) (time.Duration, error) {
-	match := lastDurationPattern.FindStringSubmatch(durationString)
-	if match == nil {
-		return 0, didErrorf("invalid duration string %s", durationString)
+// Search looks up the entries matching query in all the parent buckets, or only in the one set by the filter.
+// Words match anywhere in the content, tags or project of an entry, quoted phrases match consecutive words and
+// words ending in * match as prefixes. Results are ranked by relevance, the most recent first among equal ones.
+func Search(query string, filter SearchFilter) ([]SearchResult, error) {
+	q, err := parseSearchQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.From.After(filter.To) {
+		return nil, didErrorf("the start of the search interval is after its end")
+	}
+	if filter.Limit < 0 {
+		return nil, didErrorf("invalid search limit %d", filter.Limit)
+	}
+	s, ok := store.(searcher)
+	if !ok {
+		return nil, didErrorf("the store doesn't support searching")
+	}
+	logger := getLogger().WithFields(logrus.Fields{
+		"component": "manager",
+		"method":    "Search",
+		"query":     query,
+		"bucket":    filter.Bucket,
+	})
+	hits, err := s.Search(q, filter)
+	if err != nil {
+		logger.WithError(err).Error("failed to search entries")
+		return nil, err
+	}
+	arch, err := openArchiveFrom(filter.From)
+	if err != nil {
+		logger.WithError(err).Error("failed to open archive")
+		return nil, err
+	}
+	if a, ok := arch.(searcher); ok {
+		archived, err := a.Search(q, filter)
+		if err != nil {
+			logger.WithError(err).Error("failed to search archived entries")
+			return nil, err
+		}
+		hits = append(hits, archived...)
+	}
+	return lo.Map(rankHits(hits, filter.Limit), func(hit searchHit, _ int) SearchResult {
+		return SearchResult{
+			Entry:  hit.entry.public(),
+			Bucket: hit.parentBucketName,
+			Score:  hit.score,
+		}
+	}), nil
+}
+
+// Sync merges the entries of the store at path into this one, across all the parent buckets. Entries already present
+// are skipped, so syncing again changes nothing, while entries logged at the same time in the same bucket but
+// differing are reported as conflicts and left alone. The archived entries count as present, and the entries deleted
+// from this store aren't brought back as long as they're in its trash.
+func Sync(path string) (SyncResult, error) {
+	logger := getLogger().WithFields(logrus.Fields{
+		"component": "manager",
+		"method":    "Sync",
+		"path":      path,
+	})
+	cfg, err := getConfig()
+	if err != nil {
+		return SyncResult{}, err
+	}
+	other, err := openSyncSource(*cfg, path)
+	if err != nil {
+		logger.WithError(err).Error("failed to open the other store")
+		return SyncResult{}, err
+	}
+	defer other.Close()
+	arch, err := openArchive(false)
+	if err != nil {
+		logger.WithError(err).Error("failed to open archive")
+		return SyncResult{}, err
+	}
+	result, err := syncStores(store, other, arch)
+	if err != nil {
+		logger.WithError(err).Error("failed to sync stores")
+	}
+	return result, err
+}
+
+// ListBuckets returns the stats of the parent buckets holding entries, in name order
+func ListBuckets() ([]BucketStats, error) {
+	m, ok := store.(bucketManager)
+	if !ok {
+		return nil, didErrorf("the store doesn't support managing buckets")
+	}
+	stats, err := m.Buckets()
+	if err != nil {
+		getLogger().WithFields(logrus.Fields{
+			"component": "manager",
+			"method":    "ListBuckets",
+		}).WithError(err).Error("failed to list buckets")
+	}
+	return stats, err
+}
+
+// GetBucketStats returns the stats of a parent bucket, leaving out the buckets nested in it
+func GetBucketStats(bucket string) (BucketStats, error) {
+	stats, err := ListBuckets()
+	if err != nil {
+		return BucketStats{}, err
+	}
+	for _, s := range stats {
+		if s.Name == bucket {
+			return s, nil
+		}
+	}
+	return BucketStats{}, bucketNotFoundError(bucket)
+}
+
+// RenameBucket gives a parent bucket a new name, its entries keeping their ids. The buckets nested in it move along,
+// e.g. work/payments becoming job/payments when work is renamed to job. The new bucket must not hold entries, see
+// MergeBuckets.
+func RenameBucket(oldName, newName string) error {
+	if err := checkBucketNames(oldName, newName); err != nil {
+		return err
+	}
+	m, ok := store.(bucketManager)
+	if !ok {
+		return didErrorf("the store doesn't support managing buckets")
 	}
-	d, err := strconv.Atoi(match[1])
+	err := m.RenameBucket(oldName, newName)
 	if err != nil {
+		getLogger().WithFields(logrus.Fields{
+			"component": "manager",
+			"method":    "RenameBucket",
+			"oldName":   oldName,
+			"newName":   newName,
+		}).WithError(err).Error("failed to rename bucket")
+	}
+	return err
+}
+
+// MergeBuckets moves all the entries of the parent bucket src into dst, returning how many there were. The entries of
+// the buckets nested in src move to the same path under dst. The moved entries keep their ids unless taken in dst,
+// and their trash and history move along.
+func MergeBuckets(src, dst string) (int, error) {
+	if err := checkBucketNames(src, dst); err != nil {
 		return 0, err
 	}
-	return 24 * time.Duration(d) * time.Hour, nil
+	m, ok := store.(bucketManager)
+	if !ok {
+		return 0, didErrorf("the store doesn't support managing buckets")
+	}
+	count, err := m.MergeBucket(src, dst)
+	if err != nil {
+		getLogger().WithFields(logrus.Fields{
+			"component": "manager",
+			"method":    "MergeBuckets",
+			"src":       src,
+			"dst":       dst,
+		}).WithError(err).Error("failed to merge buckets")
+	}
+	return count, err
 }
 
-func getWeekInterval(reference time.Time) (time.Time, time.Time) {
-	weekDay := int(reference.Weekday())
-	if reference.Weekday() == time.Sunday {
-		weekDay = int(time.Saturday) + 1
+// CopyBucket copies all the entries of the parent bucket src, the nested buckets included, into dst, returning how
+// many there were. The copies keep their ids unless taken in dst.
+func CopyBucket(src, dst string) (int, error) {
+	if err := checkBucketNames(src, dst); err != nil {
+		return 0, err
+	}
+	logger := getLogger().WithFields(logrus.Fields{
+		"component": "manager",
+		"method":    "CopyBucket",
+		"src":       src,
+		"dst":       dst,
+	})
+	entries, err := collectEntries(store, func(parentBucketName string, _ entry) bool {
+		return isInBucket(parentBucketName, src)
+	})
+	if err != nil {
+		logger.WithError(err).Error("failed to read entries")
+		return 0, err
+	}
+	if len(entries) == 0 {
+		return 0, bucketNotFoundError(src)
+	}
+	for i, e := range entries {
+		if _, err := store.Put(rebaseBucket(e.parentBucketName, src, dst), e.entry); err != nil {
+			logger.WithError(err).Error("failed to copy entry")
+			return i, err
+		}
+	}
+	return len(entries), nil
+}
+
+// DeleteBucket moves all the entries of a parent bucket and of the ones nested in it to the trash, returning how many
+// there were
+func DeleteBucket(bucket string) (int, error) {
+	if err := checkBucketName(bucket); err != nil {
+		return 0, err
+	}
+	t, ok := store.(trasher)
+	if !ok {
+		return 0, didErrorf("the store doesn't support the trash")
+	}
+	logger := getLogger().WithFields(logrus.Fields{
+		"component": "manager",
+		"method":    "DeleteBucket",
+		"bucket":    bucket,
+	})
+	entries, err := collectEntries(store, func(parentBucketName string, _ entry) bool {
+		return isInBucket(parentBucketName, bucket)
+	})
+	if err != nil {
+		logger.WithError(err).Error("failed to read entries")
+		return 0, err
+	}
+	if len(entries) == 0 {
+		return 0, bucketNotFoundError(bucket)
+	}
+	deletedAt := time.Now()
+	deleted := make(map[string][]string)
+	for i, e := range entries {
+		if err := t.Trash(e.parentBucketName, e.ID, deletedAt); err != nil {
+			logger.WithError(err).Error("failed to delete entry")
+			return i, err
+		}
+		deleted[e.parentBucketName] = append(deleted[e.parentBucketName], e.ID)
+	}
+	recordChange(change{Kind: ChangeDeleteBucket, Bucket: bucket, Deleted: deleted})
+	return len(entries), nil
+}
+
+func checkBucketName(bucket string) error {
+	if bucket == "" {
+		return didErrorf("the bucket name is empty")
+	}
+	return checkBucketPath(bucket)
+}
+
+func checkBucketNames(src, dst string) error {
+	if err := checkBucketName(src); err != nil {
+		return err
+	}
+	if err := checkBucketName(dst); err != nil {
+		return err
+	}
+	if src == dst {
+		return didErrorf("the source and destination buckets are both %s", src)
+	}
+	if isInBucket(dst, src) {
+		return didErrorf("bucket %s is nested in %s", dst, src)
+	}
+	return nil
+}
+
+// Archive moves the entries logged before the given time to the archive store, across all the parent buckets. The
+// queries keep returning them, reading the archive as well when they reach back to the newest archived entry.
+func Archive(before time.Time) (ArchiveResult, error) {
+	logger := getLogger().WithFields(logrus.Fields{
+		"component": "manager",
+		"method":    "Archive",
+		"before":    before,
+	})
+	arch, err := openArchive(true)
+	if err != nil {
+		logger.WithError(err).Error("failed to open archive")
+		return ArchiveResult{}, err
 	}
-	start := reference.AddDate(0, 0, -1*(weekDay-int(time.Monday)))
-	end := reference.AddDate(0, 0, int(time.Saturday)+1-weekDay)
+	entries, err := collectEntries(store, func(_ string, e entry) bool {
+		return e.Timestamp.Before(before)
+	})
+	if err != nil {
+		logger.WithError(err).Error("failed to read entries")
+		return ArchiveResult{}, err
+	}
+	if err := raiseArchiveCutoff(entries); err != nil {
+		logger.WithError(err).Error("failed to record the archive cutoff")
+		return ArchiveResult{}, err
+	}
+	archived, err := archiveEntries(arch, store, entries)
+	if err != nil {
+		logger.WithError(err).Error("failed to archive entries")
+	}
+	return ArchiveResult{Archived: archived}, err
+}
+
+// ApplyRetention applies the retention policies of the config, archiving or deleting the entries of each parent
+// bucket older than its policy keeps. The nested buckets follow the policy of their nearest ancestor having one, the
+// buckets without any are left alone.
+func ApplyRetention() (ArchiveResult, error) {
+	logger := getLogger().WithFields(logrus.Fields{
+		"component": "manager",
+		"method":    "ApplyRetention",
+	})
+	result := ArchiveResult{}
+	if retention == nil || len(retention.Buckets) == 0 {
+		return result, didErrorf("no retention policy is configured")
+	}
+	cutoffs := make(map[string]time.Time)
+	actions := make(map[string]string)
+	for bucketName, policy := range retention.Buckets {
+		keep, err := policy.GetKeep()
+		if err != nil {
+			return result, err
+		}
+		actions[bucketName], err = policy.GetAction()
+		if err != nil {
+			return result, err
+		}
+		cutoffs[bucketName] = retentionCutoff(now(), keep)
+	}
+	// the nested buckets follow the policy of their nearest ancestor having one
+	policyBuckets := lo.Keys(cutoffs)
+	expired, err := collectEntries(store, func(parentBucketName string, e entry) bool {
+		policyBucket, ok := nearestBucket(parentBucketName, policyBuckets)
+		return ok && e.Timestamp.Before(cutoffs[policyBucket])
+	})
+	if err != nil {
+		logger.WithError(err).Error("failed to read entries")
+		return result, err
+	}
+	actionOf := func(e bucketEntry) string {
+		policyBucket, _ := nearestBucket(e.parentBucketName, policyBuckets)
+		return actions[policyBucket]
+	}
+	toArchive := lo.Filter(expired, func(e bucketEntry, _ int) bool {
+		return actionOf(e) == retentionArchive
+	})
+	toDelete := lo.Filter(expired, func(e bucketEntry, _ int) bool {
+		return actionOf(e) == retentionDelete
+	})
+	if len(toArchive) > 0 {
+		arch, err := openArchive(true)
+		if err != nil {
+			logger.WithError(err).Error("failed to open archive")
+			return result, err
+		}
+		if err := raiseArchiveCutoff(toArchive); err != nil {
+			logger.WithError(err).Error("failed to record the archive cutoff")
+			return result, err
+		}
+		result.Archived, err = archiveEntries(arch, store, toArchive)
+		if err != nil {
+			logger.WithError(err).Error("failed to archive entries")
+			return result, err
+		}
+	}
+	result.Deleted, err = deleteEntries(store, toDelete)
+	if err != nil {
+		logger.WithError(err).Error("failed to delete expired entries")
+	}
+	return result, err
+}
+
+func now() time.Time {
+	return time.Now().In(location)
+}
+
+// dropEntries removes the entries matching drop from the query result, along with the groups left empty
+func dropEntries(result map[string][]Entry, drop func(Entry) bool) {
+	for key, entries := range result {
+		kept := lo.Reject(entries, func(e Entry, _ int) bool {
+			return drop(e)
+		})
+		if len(kept) == 0 && key != flatEntriesPlaceholder {
+			delete(result, key)
+		} else {
+			result[key] = kept
+		}
+	}
+}
+
+// dayIn returns the start of the day having the date of t in loc
+func dayIn(t time.Time, loc *time.Location) time.Time {
+	return dayStart(t.Year(), t.Month(), t.Day(), loc)
+}
+
+// getWeekInterval returns the first and last days of the week holding reference, at its clock. The weeks start on
+// weekStart.
+func getWeekInterval(reference time.Time) (time.Time, time.Time) {
+	daysIn := (int(reference.Weekday()) - int(weekStart) + 7) % 7
+	start := reference.AddDate(0, 0, -daysIn)
+	end := reference.AddDate(0, 0, 6-daysIn)
 	return start, end
 }
 
-func getRange(bucketName string, start, end time.Time, flat bool) (map[string][]string, error) {
+// isWorkDay tells whether t falls on one of the working days, in the timezone of the queries
+func isWorkDay(t time.Time) bool {
+	return lo.Contains(workDays, t.In(location).Weekday())
+}
+
+// rangeBuckets returns the parent bucket along with the ones nested in it, in the store or in the archive
+func rangeBuckets(arch entryStore, bucketName string) ([]string, error) {
+	result := []string{bucketName}
+	for _, s := range []entryStore{store, arch} {
+		m, ok := s.(bucketManager)
+		if !ok {
+			continue
+		}
+		children, err := m.ChildBuckets(bucketName)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, children...)
+	}
+	return lo.Uniq(result), nil
+}
+
+// getTreeRange reads the range from the parent buckets, and from the archive when there's one. With byBucket the
+// entries are grouped by parent bucket, agg being left aside.
+func getTreeRange(arch entryStore, buckets []string, start, end time.Time, agg aggregationFunction, byBucket bool) (any, error) {
+	all := make([]entry, 0)
+	grouped := make(map[string][]Entry)
+	for _, bucketName := range buckets {
+		entries, err := store.GetRange(bucketName, start, end)
+		if err != nil {
+			return nil, err
+		}
+		if arch != nil {
+			archived, err := arch.GetRange(bucketName, start, end)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(archived, entries...)
+			sortEntries(entries)
+		}
+		if !byBucket {
+			all = append(all, entries...)
+		} else if len(entries) > 0 {
+			grouped[bucketName] = lo.Map(entries, func(e entry, _ int) Entry {
+				return e.public()
+			})
+		}
+	}
+	if byBucket {
+		return grouped, nil
+	}
+	sortEntries(all)
+	return agg(all)
+}
+
+func getRange(bucketName string, start, end time.Time, flat bool) (map[string][]Entry, error) {
 	var agg aggregationFunction
 	if flat {
 		agg = flatAggregation
 	} else {
 		agg = perDayAggregation
 	}
+	byBucket := groupByBucket && !flat
 
-	entries, err := store.GetRangeWithAggregation(bucketName, start, end, agg)
+	arch, err := openArchiveFrom(start)
+	if err != nil {
+		return nil, err
+	}
+	buckets, err := rangeBuckets(arch, bucketName)
+	if err != nil {
+		return nil, err
+	}
+	var entries any
+	if arch == nil && len(buckets) == 1 && !byBucket {
+		entries, err = store.GetRangeWithAggregation(bucketName, start, end, agg)
+	} else {
+		entries, err = getTreeRange(arch, buckets, start, end, agg, byBucket)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	result := make(map[string][]string)
+	result := make(map[string][]Entry)
 
 	if flat {
-		flatEntries, ok := entries.([]string)
+		flatEntries, ok := entries.([]Entry)
 		if !ok {
 			return nil, errors.New("internal error, cannot convert result")
 		}
 		result[flatEntriesPlaceholder] = flatEntries
 	} else {
 		var ok bool
-		result, ok = entries.(map[string][]string)
+		result, ok = entries.(map[string][]Entry)
 		if !ok {
 			return nil, errors.New("internal error, cannot convert result")
 		}
 	}
+	if hideNonWorkingDays {
+		dropEntries(result, func(e Entry) bool {
+			return !isWorkDay(e.Timestamp)
+		})
+	}
 	return result, nil
 }
@@ -15,11 +15,11 @@ func TestParseDuration(t *testing.T) {
 		name           string
 		durationString string
 		shouldError    bool
-		expected       time.Duration
+		expected       calendarDuration
 	}{
 		{
-			name:           "different interval",
-			durationString: "12h",
+			name:           "unknown unit",
+			durationString: "12s",
 			shouldError:    true,
 		},
 		{
@@ -28,9 +28,59 @@ func TestParseDuration(t *testing.T) {
 			shouldError:    true,
 		},
 		{
-			name:           "good interval",
+			name:           "missing count",
+			durationString: "1wd",
+			shouldError:    true,
+		},
+		{
+			name:           "missing unit",
+			durationString: "12",
+			shouldError:    true,
+		},
+		{
+			name:           "empty",
+			durationString: "",
+			shouldError:    true,
+		},
+		{
+			name:           "spaces",
+			durationString: "1w 3d",
+			shouldError:    true,
+		},
+		{
+			name:           "days",
 			durationString: "12d",
-			expected:       12 * 24 * time.Hour,
+			expected:       calendarDuration{days: 12},
+		},
+		{
+			name:           "weeks",
+			durationString: "2w",
+			expected:       calendarDuration{days: 14},
+		},
+		{
+			name:           "months",
+			durationString: "3m",
+			expected:       calendarDuration{months: 3},
+		},
+		{
+			name:           "years",
+			durationString: "1y",
+			expected:       calendarDuration{years: 1},
+		},
+		{
+			name:           "hours",
+			durationString: "4h",
+			expected:       calendarDuration{hours: 4},
+		},
+		{
+			name:           "combined",
+			durationString: "1w3d",
+			expected:       calendarDuration{days: 10},
+		},
+		{
+			name:           "all units",
+			durationString: "1y2m3w4d5h",
+			expected:       calendarDuration{years: 1, months: 2, days: 25, hours: 5},
 		},
 	}
 
@@ -38,7 +88,7 @@ func TestParseDuration(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			actual, err := parseDuration(tc.durationString)
 			if tc.shouldError {
-				require.Error(t, err)
+				require.IsType(t, DidError{}, err)
 			} else {
 				require.NoError(t, err)
 				require.Equal(t, tc.expected, actual)
@@ -47,6 +97,94 @@ func TestParseDuration(t *testing.T) {
 	}
 }
 
+func TestCalendarDurationBefore(t *testing.T) {
+	testCases := []struct {
+		name           string
+		durationString string
+		reference      string
+		expected       string
+	}{
+		{
+			name:           "a month is a calendar month",
+			durationString: "1m",
+			reference:      "2026-10-18T12:00:00Z",
+			expected:       "2026-09-18T12:00:00Z",
+		},
+		{
+			// like a month ago, February 31st being clamped to February 28th
+			name:           "a month from the end of a longer month",
+			durationString: "1m",
+			reference:      "2026-03-31T12:00:00Z",
+			expected:       "2026-02-28T12:00:00Z",
+		},
+		{
+			name:           "the days after the months",
+			durationString: "1m1d",
+			reference:      "2026-03-31T12:00:00Z",
+			expected:       "2026-02-27T12:00:00Z",
+		},
+		{
+			name:           "a year from a leap day",
+			durationString: "1y",
+			reference:      "2024-02-29T12:00:00Z",
+			expected:       "2023-02-28T12:00:00Z",
+		},
+		{
+			name:           "weeks and days",
+			durationString: "1w3d",
+			reference:      "2026-10-18T12:00:00Z",
+			expected:       "2026-10-08T12:00:00Z",
+		},
+		{
+			name:           "hours",
+			durationString: "4h",
+			reference:      "2026-10-18T02:00:00Z",
+			expected:       "2026-10-17T22:00:00Z",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := parseDuration(tc.durationString)
+			require.NoError(t, err)
+			reference := timeFromString(t, tc.reference)
+			assert.Equal(t, timeFromString(t, tc.expected), d.before(reference))
+		})
+	}
+}
+
+func TestNewStore(t *testing.T) {
+	defer cleanupTestBoltStore()
+	defer cleanupTestSQLiteStore()
+	defer cleanupTestMarkdownStore()
+
+	s, err := newStore(config{StorePath: "test.db", SocketPath: "test.sock"}, Options{})
+	require.NoError(t, err)
+	assert.IsType(t, &boltStore{}, s)
+	results, err := s.(migrator).Migrate(true)
+	require.NoError(t, err)
+	assert.Empty(t, results, "the store should be migrated when opened")
+	require.NoError(t, s.Close())
+
+	s, err = newStore(config{StorePath: "test.sqlite", Backend: backendSQLite, SocketPath: "test.sock"}, Options{})
+	require.NoError(t, err)
+	assert.IsType(t, &sqliteStore{}, s)
+	require.NoError(t, s.Close())
+
+	s, err = newStore(config{Backend: backendMemory}, Options{})
+	require.NoError(t, err)
+	assert.IsType(t, &memoryStore{}, s)
+	require.NoError(t, s.Close())
+
+	s, err = newStore(config{StorePath: "test-journal", Backend: backendMarkdown, SocketPath: "test.sock"}, Options{})
+	require.NoError(t, err)
+	assert.IsType(t, &markdownStore{}, s)
+	require.NoError(t, s.Close())
+
+	_, err = newStore(config{StorePath: "test.db", Backend: "foo"}, Options{})
+	assert.IsType(t, DidError{}, err)
+}
+
 func TestGetWeekInterval(t *testing.T) {
 	expectedStart := timeFromString(t, "2018-07-09T12:21:00Z")
 	expectedEnd := timeFromString(t, "2018-07-15T12:21:00Z")
@@ -59,6 +197,40 @@ func TestGetWeekInterval(t *testing.T) {
 	}
 }
 
+func TestGetWeekIntervalWeekStart(t *testing.T) {
+	t.Cleanup(func() { weekStart = time.Monday })
+	weekStart = time.Sunday
+	expectedStart := timeFromString(t, "2018-07-08T12:21:00Z")
+	expectedEnd := timeFromString(t, "2018-07-14T12:21:00Z")
+	reference := expectedStart
+	for i := 0; i < 7; i++ {
+		start, end := getWeekInterval(reference)
+		assert.Equal(t, expectedStart, start)
+		assert.Equal(t, expectedEnd, end)
+		reference = reference.AddDate(0, 0, 1)
+	}
+}
+
+func TestConfigWeek(t *testing.T) {
+	start, err := (&config{}).GetWeekStart()
+	require.NoError(t, err)
+	assert.Equal(t, time.Monday, start)
+	start, err = (&config{WeekStart: "Sun"}).GetWeekStart()
+	require.NoError(t, err)
+	assert.Equal(t, time.Sunday, start)
+	_, err = (&config{WeekStart: "someday"}).GetWeekStart()
+	assert.IsType(t, DidError{}, err)
+
+	days, err := (&config{}).GetWorkDays()
+	require.NoError(t, err)
+	assert.Equal(t, defaultWorkDays, days)
+	days, err = (&config{WorkDays: []string{"sunday", "Mon", "TUESDAY", "wed", "thursday"}}).GetWorkDays()
+	require.NoError(t, err)
+	assert.Equal(t, []time.Weekday{time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday}, days)
+	_, err = (&config{WorkDays: []string{"monday", "fri day"}}).GetWorkDays()
+	assert.IsType(t, DidError{}, err)
+}
+
 func TestGetRange(t *testing.T) {
 	testBucketName := "test-bucket"
 	testCases := []struct {
@@ -69,7 +241,7 @@ func TestGetRange(t *testing.T) {
 		storeReturn      any
 		storeShouldError bool
 		shouldError      bool
-		expected         map[string][]string
+		expected         map[string][]Entry
 	}{
 		{
 			name:             "store error",
@@ -98,20 +270,20 @@ func TestGetRange(t *testing.T) {
 			start:       time.Now(),
 			end:         time.Now().AddDate(0, 0, 1),
 			flat:        true,
-			storeReturn: []string{"a", "b", "c"},
-			expected:    map[string][]string{flatEntriesPlaceholder: {"a", "b", "c"}},
+			storeReturn: []Entry{{Content: "a"}, {Content: "b"}, {Content: "c"}},
+			expected:    map[string][]Entry{flatEntriesPlaceholder: {{Content: "a"}, {Content: "b"}, {Content: "c"}}},
 		},
 		{
 			name:  "per day",
 			start: time.Now(),
 			end:   time.Now().AddDate(0, 0, 1),
-			storeReturn: map[string][]string{
-				"key1": {"a", "b", "c"},
-				"key2": {"foo"},
+			storeReturn: map[string][]Entry{
+				"key1": {{Content: "a"}, {Content: "b"}, {Content: "c"}},
+				"key2": {{Content: "foo"}},
 			},
-			expected: map[string][]string{
-				"key1": {"a", "b", "c"},
-				"key2": {"foo"},
+			expected: map[string][]Entry{
+				"key1": {{Content: "a"}, {Content: "b"}, {Content: "c"}},
+				"key2": {{Content: "foo"}},
 			},
 		},
 	}
@@ -150,43 +322,55 @@ func TestFlatAggregation(t *testing.T) {
 	testCases := []struct {
 		name     string
 		input    []entry
-		expected []string
+		expected []Entry
 	}{
 		{
 			name:     "empty",
-			expected: []string{},
+			expected: []Entry{},
 		},
 		{
 			name: "entries in one day",
 			input: []entry{
 				{
+					ID:        "1",
 					Timestamp: timeFromString(t, "2018-07-18T12:00:00Z"),
 					Content:   []byte("msg1"),
 				},
 				{
+					ID:        "2",
 					Timestamp: timeFromString(t, "2018-07-18T14:00:00Z"),
 					Content:   []byte("msg2"),
 				},
 			},
-			expected: []string{"msg1", "msg2"},
+			expected: []Entry{
+				{ID: "1", Timestamp: timeFromString(t, "2018-07-18T12:00:00Z"), Content: "msg1"},
+				{ID: "2", Timestamp: timeFromString(t, "2018-07-18T14:00:00Z"), Content: "msg2"},
+			},
 		},
 		{
 			name: "entries in separate days",
 			input: []entry{
 				{
+					ID:        "1",
 					Timestamp: timeFromString(t, "2018-07-18T12:00:00Z"),
 					Content:   []byte("msg1"),
 				},
 				{
+					ID:        "2",
 					Timestamp: timeFromString(t, "2018-07-18T14:00:00Z"),
 					Content:   []byte("msg2"),
 				},
 				{
+					ID:        "3",
 					Timestamp: timeFromString(t, "2018-07-20T14:00:00Z"),
 					Content:   []byte("msg3"),
 				},
 			},
-			expected: []string{"msg1", "msg2", "msg3"},
+			expected: []Entry{
+				{ID: "1", Timestamp: timeFromString(t, "2018-07-18T12:00:00Z"), Content: "msg1"},
+				{ID: "2", Timestamp: timeFromString(t, "2018-07-18T14:00:00Z"), Content: "msg2"},
+				{ID: "3", Timestamp: timeFromString(t, "2018-07-20T14:00:00Z"), Content: "msg3"},
+			},
 		},
 	}
 
@@ -194,7 +378,7 @@ func TestFlatAggregation(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			result, err := flatAggregation(tc.input)
 			require.NoError(t, err)
-			actual, ok := result.([]string)
+			actual, ok := result.([]Entry)
 			require.True(t, ok)
 			require.Equal(t, tc.expected, actual)
 
@@ -202,16 +386,29 @@ func TestFlatAggregation(t *testing.T) {
 	}
 }
 
+func TestPerDayAggregationInLocation(t *testing.T) {
+	defer func() {
+		location = time.Local
+	}()
+	location = time.FixedZone("PDT", -7*60*60)
+	ts := timeFromString(t, "2018-07-18T03:00:00Z")
+	result, err := perDayAggregation([]entry{{ID: "1", Timestamp: ts, Content: []byte("msg1")}})
+	require.NoError(t, err)
+	require.Equal(t, map[string][]Entry{
+		"2018-07-17": {{ID: "1", Timestamp: ts, Content: "msg1"}},
+	}, result)
+}
+
 func TestPerDayAggregation(t *testing.T) {
 	testCases := []struct {
 		name        string
 		input       []entry
 		shouldError bool
-		expected    map[string][]string
+		expected    map[string][]Entry
 	}{
 		{
 			name:     "empty",
-			expected: map[string][]string{},
+			expected: map[string][]Entry{},
 		},
 		{
 			name: "bad entry",
@@ -226,37 +423,50 @@ func TestPerDayAggregation(t *testing.T) {
 			name: "entries in one day",
 			input: []entry{
 				{
+					ID:        "1",
 					Timestamp: timeFromString(t, "2018-07-18T12:00:00Z"),
 					Content:   []byte("msg1"),
 				},
 				{
+					ID:        "2",
 					Timestamp: timeFromString(t, "2018-07-18T14:00:00Z"),
 					Content:   []byte("msg2"),
 				},
 			},
-			expected: map[string][]string{
-				"2018-07-18": {"msg1", "msg2"},
+			expected: map[string][]Entry{
+				"2018-07-18": {
+					{ID: "1", Timestamp: timeFromString(t, "2018-07-18T12:00:00Z"), Content: "msg1"},
+					{ID: "2", Timestamp: timeFromString(t, "2018-07-18T14:00:00Z"), Content: "msg2"},
+				},
 			},
 		},
 		{
 			name: "entries in separate days",
 			input: []entry{
 				{
+					ID:        "1",
 					Timestamp: timeFromString(t, "2018-07-18T12:00:00Z"),
 					Content:   []byte("msg1"),
 				},
 				{
+					ID:        "2",
 					Timestamp: timeFromString(t, "2018-07-18T14:00:00Z"),
 					Content:   []byte("msg2"),
 				},
 				{
+					ID:        "3",
 					Timestamp: timeFromString(t, "2018-07-20T14:00:00Z"),
 					Content:   []byte("msg3"),
 				},
 			},
-			expected: map[string][]string{
-				"2018-07-18": {"msg1", "msg2"},
-				"2018-07-20": {"msg3"},
+			expected: map[string][]Entry{
+				"2018-07-18": {
+					{ID: "1", Timestamp: timeFromString(t, "2018-07-18T12:00:00Z"), Content: "msg1"},
+					{ID: "2", Timestamp: timeFromString(t, "2018-07-18T14:00:00Z"), Content: "msg2"},
+				},
+				"2018-07-20": {
+					{ID: "3", Timestamp: timeFromString(t, "2018-07-20T14:00:00Z"), Content: "msg3"},
+				},
 			},
 		},
 	}
@@ -268,7 +478,7 @@ func TestPerDayAggregation(t *testing.T) {
 				require.Error(t, err)
 			} else {
 				require.NoError(t, err)
-				actual, ok := result.(map[string][]string)
+				actual, ok := result.(map[string][]Entry)
 				require.True(t, ok)
 				require.Equal(t, tc.expected, actual)
 			}
@@ -2,10 +2,13 @@ package godid
 
 import (
 	"errors"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
 
+	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/stretchr/testify/require"
@@ -34,13 +37,13 @@ func TestAddEntry(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			var insertedEntry entry
 			store = &entryStoreMock{
-				PutFunc: func(bucketName string, e entry) error {
+				PutFunc: func(bucketName string, e entry) (string, error) {
 					require.Equal(t, rootBucketName, bucketName)
 					if tc.shouldError {
-						return errors.New("BOOM")
+						return "", errors.New("BOOM")
 					}
 					insertedEntry = e
-					return nil
+					return "", nil
 				},
 			}
 			err := AddEntry(tc.input)
@@ -80,13 +83,13 @@ func TestAddEntryToBucket(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			var insertedEntry entry
 			store = &entryStoreMock{
-				PutFunc: func(bucketName string, e entry) error {
+				PutFunc: func(bucketName string, e entry) (string, error) {
 					require.Equal(t, tc.bucketName, bucketName)
 					if tc.shouldError {
-						return errors.New("BOOM")
+						return "", errors.New("BOOM")
 					}
 					insertedEntry = e
-					return nil
+					return "", nil
 				},
 			}
 			err := AddEntryToBucket(tc.bucketName, tc.input)
@@ -101,6 +104,337 @@ func TestAddEntryToBucket(t *testing.T) {
 	}
 }
 
+func TestAddEntryToBucketWithMetadata(t *testing.T) {
+	meta := Metadata{Tags: []string{"billing"}, Project: "payments", Duration: time.Hour}
+	var insertedEntry entry
+	store = &entryStoreMock{
+		PutFunc: func(bucketName string, e entry) (string, error) {
+			require.Equal(t, "work", bucketName)
+			insertedEntry = e
+			return "", nil
+		},
+	}
+	require.NoError(t, AddEntryToBucketWithMetadata("work", "msg1", meta))
+	require.False(t, insertedEntry.Timestamp.IsZero())
+	require.Equal(t, []byte("msg1"), insertedEntry.Content)
+	require.Equal(t, meta, insertedEntry.Metadata)
+}
+
+func TestUpdateEntry(t *testing.T) {
+	testCases := []struct {
+		name        string
+		bucketName  string
+		shouldError bool
+	}{
+		{
+			name:        "will error",
+			shouldError: true,
+		},
+		{
+			name: "root bucket",
+		},
+		{
+			name:       "custom bucket",
+			bucketName: randString(10),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var updatedEntry entry
+			expectedBucket := tc.bucketName
+			if expectedBucket == "" {
+				expectedBucket = rootBucketName
+			}
+			store = &entryStoreMock{
+				GetFunc: func(bucketName string, id string) (entry, error) {
+					require.Equal(t, expectedBucket, bucketName)
+					return entry{ID: id, Content: []byte("typo"), Metadata: Metadata{Project: "p"}}, nil
+				},
+				UpdateFunc: func(bucketName string, e entry) error {
+					require.Equal(t, expectedBucket, bucketName)
+					if tc.shouldError {
+						return errors.New("BOOM")
+					}
+					updatedEntry = e
+					return nil
+				},
+			}
+			var err error
+			if tc.bucketName == "" {
+				err = UpdateEntry("id", "msg1")
+			} else {
+				err = UpdateEntryInBucket(tc.bucketName, "id", "msg1")
+			}
+			if tc.shouldError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, entry{ID: "id", Content: []byte("msg1"), Metadata: Metadata{Project: "p"}}, updatedEntry)
+			}
+		})
+	}
+}
+
+func TestDeleteEntry(t *testing.T) {
+	store = &entryStoreMock{}
+	require.IsType(t, DidError{}, DeleteEntry("id"), "the store doesn't support the trash")
+
+	testCases := []struct {
+		name       string
+		bucketName string
+	}{
+		{
+			name: "root bucket",
+		},
+		{
+			name:       "custom bucket",
+			bucketName: randString(10),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			expectedBucket := tc.bucketName
+			if expectedBucket == "" {
+				expectedBucket = rootBucketName
+			}
+			store = getTestMemoryStore(t, config{})
+			require.NoError(t, AddEntryToBucket(expectedBucket, "msg1"))
+			entries, err := GetTodayFromBucket(expectedBucket)
+			require.NoError(t, err)
+			require.Len(t, entries, 1)
+			id := entries[0].ID
+			if tc.bucketName == "" {
+				require.IsType(t, DidError{}, DeleteEntry("0-1"))
+				err = DeleteEntry(id)
+			} else {
+				err = DeleteEntryFromBucket(tc.bucketName, id)
+			}
+			require.NoError(t, err)
+			entries, err = GetTodayFromBucket(expectedBucket)
+			require.NoError(t, err)
+			require.Empty(t, entries, "the queries must not show trashed entries")
+			trashed, err := ListTrash()
+			require.NoError(t, err)
+			require.Len(t, trashed, 1)
+			assert.Equal(t, id, trashed[0].ID)
+			assert.Equal(t, expectedBucket, trashed[0].Bucket)
+			assert.Equal(t, "msg1", trashed[0].Content)
+			assert.False(t, trashed[0].DeletedAt.IsZero())
+		})
+	}
+}
+
+func TestGetEntryHistory(t *testing.T) {
+	store = &entryStoreMock{}
+	_, err := GetEntryHistory("id")
+	require.IsType(t, DidError{}, err)
+
+	store = getTestMemoryStore(t, config{})
+	_, err = GetEntryHistory("0-1")
+	require.IsType(t, DidError{}, err)
+	require.NoError(t, AddEntry("msg1"))
+	entries, err := GetToday()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	id := entries[0].ID
+	revisions, err := GetEntryHistory(id)
+	require.NoError(t, err)
+	assert.Equal(t, []Revision{{Entry: entries[0]}}, revisions)
+
+	require.NoError(t, UpdateEntry(id, "msg1 edited"))
+	_, err = Undo()
+	require.NoError(t, err)
+	revisions, err = GetEntryHistory(id)
+	require.NoError(t, err)
+	require.Len(t, revisions, 3, "undoing an edit is an edit too")
+	assert.Equal(t, []string{"msg1", "msg1 edited", "msg1"}, lo.Map(revisions, func(r Revision, _ int) string {
+		return r.Content
+	}))
+	assert.False(t, revisions[1].ReplacedAt.IsZero())
+	assert.True(t, revisions[2].ReplacedAt.IsZero())
+
+	require.NoError(t, DeleteEntry(id))
+	revisions, err = GetEntryHistory(id)
+	require.NoError(t, err)
+	assert.Len(t, revisions, 2, "the history outlives the entry")
+}
+
+func TestTrash(t *testing.T) {
+	store = &entryStoreMock{}
+	_, err := ListTrash()
+	require.IsType(t, DidError{}, err)
+
+	store = getTestMemoryStore(t, config{})
+	require.NoError(t, AddEntry("msg1"))
+	require.NoError(t, AddEntry("msg2"))
+	entries, err := GetToday()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	require.NoError(t, DeleteEntry(entries[0].ID))
+	require.NoError(t, DeleteEntry(entries[1].ID))
+
+	_, err = RestoreEntry(entries[1].ID + "0")
+	require.IsType(t, DidError{}, err)
+	restored, err := RestoreEntry(entries[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, entries[0].ID, restored.ID)
+	_, err = RestoreEntry(entries[0].ID)
+	require.IsType(t, DidError{}, err, "the entry isn't in the trash anymore")
+	actual, err := GetToday()
+	require.NoError(t, err)
+	assert.Equal(t, entries[:1], actual)
+
+	count, err := EmptyTrash()
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	trashed, err := ListTrash()
+	require.NoError(t, err)
+	assert.Empty(t, trashed)
+
+	ts := timeFromString(t, "2018-07-18T12:11:00Z")
+	id := putEntry(t, store, "work", entry{Timestamp: ts, Content: []byte("msg3")})
+	require.Equal(t, id, putEntry(t, store, rootBucketName, entry{Timestamp: ts, Content: []byte("msg4")}))
+	require.NoError(t, DeleteEntryFromBucket("work", id))
+	restored, err = RestoreEntry(id)
+	require.NoError(t, err)
+	assert.Equal(t, "msg3", restored.Content, "the entry goes back to the bucket it was deleted from")
+	require.NoError(t, DeleteEntryFromBucket("work", id))
+	require.NoError(t, DeleteEntryFromBucket(rootBucketName, id))
+	_, err = RestoreEntry(id)
+	require.IsType(t, DidError{}, err, "the bucket must be picked")
+	restored, err = RestoreEntryToBucket("work", id)
+	require.NoError(t, err)
+	assert.Equal(t, "msg3", restored.Content)
+	restored, err = RestoreEntry(id)
+	require.NoError(t, err)
+	assert.Equal(t, "msg4", restored.Content)
+}
+
+func TestUndo(t *testing.T) {
+	store = &entryStoreMock{}
+	_, err := Undo()
+	require.IsType(t, DidError{}, err)
+
+	store = getTestMemoryStore(t, config{})
+	_, err = Undo()
+	require.IsType(t, DidError{}, err, "nothing to undo")
+
+	require.NoError(t, AddEntryWithMetadata("msg1", Metadata{Project: "p"}))
+	require.NoError(t, AddEntry("msg2"))
+	c, err := Undo()
+	require.NoError(t, err)
+	assert.Equal(t, ChangeAdd, c.Kind)
+	assert.Equal(t, rootBucketName, c.Bucket)
+	assert.Equal(t, "msg2", c.Entry.Content)
+	_, err = Undo()
+	require.IsType(t, DidError{}, err, "only the last change can be undone")
+	entries, err := GetToday()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	trashed, err := ListTrash()
+	require.NoError(t, err)
+	require.Len(t, trashed, 1, "the undone entry must be recoverable")
+
+	id := entries[0].ID
+	require.NoError(t, UpdateEntry(id, "msg1 edited"))
+	c, err = Undo()
+	require.NoError(t, err)
+	assert.Equal(t, ChangeEdit, c.Kind)
+	e, err := GetEntry(id)
+	require.NoError(t, err)
+	assert.Equal(t, "msg1", e.Content)
+	assert.Equal(t, Metadata{Project: "p"}, e.Metadata)
+	assert.Equal(t, entries[0], e)
+
+	require.NoError(t, DeleteEntry(id))
+	c, err = Undo()
+	require.NoError(t, err)
+	assert.Equal(t, ChangeDelete, c.Kind)
+	assert.Equal(t, entries[0], c.Entry)
+	actual, err := GetToday()
+	require.NoError(t, err)
+	assert.Equal(t, entries, actual)
+
+	require.NoError(t, AddEntry("msg3"))
+	entries, err = GetToday()
+	require.NoError(t, err)
+	require.NoError(t, DeleteEntryFromBucket(rootBucketName, entries[1].ID))
+	_, err = EmptyTrash()
+	require.NoError(t, err)
+	_, err = Undo()
+	require.IsType(t, DidError{}, err, "the deleted entry is gone for good")
+
+	require.NoError(t, AddEntryToBucket("work", "msg4"))
+	require.NoError(t, AddEntryToBucket("work/payments", "msg5"))
+	count, err := DeleteBucket("work")
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+	c, err = Undo()
+	require.NoError(t, err)
+	assert.Equal(t, Change{Kind: ChangeDeleteBucket, Bucket: "work", Entries: 2}, c)
+	work, err := GetTodayFromBucket("work")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"msg4", "msg5"}, lo.Map(work, func(e Entry, _ int) string { return e.Content }))
+	trashed, err = ListTrash()
+	require.NoError(t, err)
+	assert.Empty(t, trashed)
+}
+
+func TestSetTimezone(t *testing.T) {
+	defer func() {
+		location = time.Local
+	}()
+	require.IsType(t, DidError{}, SetTimezone("Nowhere/Special"))
+	require.Equal(t, time.Local, Location())
+
+	require.NoError(t, SetTimezone("Asia/Tokyo"))
+	require.Equal(t, "Asia/Tokyo", Location().String())
+
+	store = &entryStoreMock{
+		GetRangeWithAggregationFunc: func(bucketName string, start, end time.Time, f aggregationFunction) (any, error) {
+			assert.Equal(t, "Asia/Tokyo", start.Location().String())
+			assert.Equal(t, "Asia/Tokyo", end.Location().String())
+			return []Entry{}, nil
+		},
+	}
+	_, err := GetToday()
+	require.NoError(t, err)
+}
+
+func TestMigrate(t *testing.T) {
+	store = &entryStoreMock{}
+	results, err := Migrate(false)
+	require.NoError(t, err)
+	require.Empty(t, results)
+}
+
+func TestSearch(t *testing.T) {
+	store = &entryStoreMock{}
+	_, err := Search("billing", SearchFilter{})
+	require.IsType(t, DidError{}, err)
+
+	store = getTestMemoryStore(t, config{})
+	_, err = Search(`"billing`, SearchFilter{})
+	require.IsType(t, DidError{}, err)
+	_, err = Search("billing", SearchFilter{From: time.Now(), To: time.Now().AddDate(0, 0, -1)})
+	require.IsType(t, DidError{}, err)
+	_, err = Search("billing", SearchFilter{Limit: -1})
+	require.IsType(t, DidError{}, err)
+
+	for _, what := range []string{"billing", "billing migration", "billing billing"} {
+		require.NoError(t, AddEntryToBucket("work", what))
+	}
+	results, err := Search("billing", SearchFilter{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "billing billing", results[0].Content)
+	assert.Equal(t, "work", results[0].Bucket)
+	assert.Greater(t, results[0].Score, results[1].Score)
+	assert.Equal(t, "billing migration", results[1].Content)
+}
+
 func TestGetToday(t *testing.T) {
 	store = &entryStoreMock{
 		GetRangeWithAggregationFunc: func(bucketName string, start, end time.Time, f aggregationFunction) (any, error) {
@@ -118,7 +452,7 @@ func TestGetToday(t *testing.T) {
 			assert.Equal(t, curD, eD)
 
 			assert.Equal(t, reflect.ValueOf(flatAggregation).Pointer(), reflect.ValueOf(f).Pointer())
-			return []string{}, nil
+			return []Entry{}, nil
 		},
 	}
 	_, err := GetToday()
@@ -143,7 +477,7 @@ func TestGetTodayFromBucket(t *testing.T) {
 			assert.Equal(t, curD, eD)
 
 			assert.Equal(t, reflect.ValueOf(flatAggregation).Pointer(), reflect.ValueOf(f).Pointer())
-			return []string{}, nil
+			return []Entry{}, nil
 		},
 	}
 	_, err := GetTodayFromBucket(testBucketName)
@@ -167,7 +501,7 @@ func TestGetYesterday(t *testing.T) {
 			assert.Equal(t, curD, eD)
 
 			assert.Equal(t, reflect.ValueOf(flatAggregation).Pointer(), reflect.ValueOf(f).Pointer())
-			return []string{}, nil
+			return []Entry{}, nil
 		},
 	}
 	_, err := GetYesterday()
@@ -192,7 +526,7 @@ func TestGetYesterdayFromBucket(t *testing.T) {
 			assert.Equal(t, curD, eD)
 
 			assert.Equal(t, reflect.ValueOf(flatAggregation).Pointer(), reflect.ValueOf(f).Pointer())
-			return []string{}, nil
+			return []Entry{}, nil
 		},
 	}
 	_, err := GetYesterdayFromBucket(testBucketName)
@@ -382,7 +716,7 @@ func TestGetLastDuration(t *testing.T) {
 	}{
 		{
 			name:        "bad interval",
-			interval:    "1080h",
+			interval:    "1080s",
 			shouldError: true,
 		},
 		{
@@ -404,7 +738,7 @@ func TestGetLastDuration(t *testing.T) {
 					if !tc.shouldError {
 						d, err := parseDuration(tc.interval)
 						require.NoError(t, err)
-						expectedStart := time.Now().Add(-1 * d)
+						expectedStart := d.before(time.Now())
 						expectedEnd := time.Now()
 
 						exY, exM, exD := expectedStart.Date()
@@ -426,9 +760,9 @@ func TestGetLastDuration(t *testing.T) {
 						}
 					}
 					if tc.flat {
-						return []string{}, nil
+						return []Entry{}, nil
 					}
-					return map[string][]string{}, nil
+					return map[string][]Entry{}, nil
 				},
 			}
 			_, err := GetLastDuration(tc.interval, tc.flat)
@@ -441,6 +775,76 @@ func TestGetLastDuration(t *testing.T) {
 	}
 }
 
+func TestGetLastDurationHours(t *testing.T) {
+	s := getTestMemoryStore(t, config{})
+	store = s
+	putEntry(t, s, rootBucketName, entry{Timestamp: time.Now().Add(-6 * time.Hour), Content: []byte("old")})
+	putEntry(t, s, rootBucketName, entry{Timestamp: time.Now().Add(-time.Hour), Content: []byte("recent")})
+
+	entries, err := GetLastDuration("4h", true)
+	require.NoError(t, err)
+	require.Len(t, entries[flatEntriesPlaceholder], 1, "the hours start at the exact time")
+	assert.Equal(t, "recent", entries[flatEntriesPlaceholder][0].Content)
+	perDay, err := GetLastDuration("4h", false)
+	require.NoError(t, err)
+	assert.Len(t, lo.Flatten(lo.Values(perDay)), 1)
+	entries, err = GetLastDuration("1d", true)
+	require.NoError(t, err)
+	assert.Len(t, entries[flatEntriesPlaceholder], 2)
+}
+
+func TestAddEntryAt(t *testing.T) {
+	t.Cleanup(func() { allowFutureEntries = false })
+	store = getTestMemoryStore(t, config{})
+	yesterday := time.Now().AddDate(0, 0, -1)
+	require.NoError(t, AddEntryAt("work", "forgotten", yesterday))
+	require.NoError(t, AddEntryToBucket("work", "today"))
+	require.IsType(t, DidError{}, AddEntryAt("work", "missing time", time.Time{}))
+	tomorrow := time.Now().AddDate(0, 0, 1)
+	require.IsType(t, DidError{}, AddEntryAt("work", "planned", tomorrow))
+
+	entries, err := GetLastDurationFromBucket("work", "2d", true)
+	require.NoError(t, err)
+	require.Len(t, entries[flatEntriesPlaceholder], 2)
+	assert.Equal(t, "forgotten", entries[flatEntriesPlaceholder][0].Content)
+	assert.True(t, yesterday.Equal(entries[flatEntriesPlaceholder][0].Timestamp))
+
+	SetAllowFutureEntries(true)
+	require.NoError(t, AddEntryAtWithMetadata("work", "planned", tomorrow, Metadata{Tags: []string{"plan"}}))
+	undone, err := Undo()
+	require.NoError(t, err)
+	assert.Equal(t, "planned", undone.Entry.Content, "the entries logged at a given time can be undone")
+}
+
+func TestHideNonWorkingDays(t *testing.T) {
+	t.Cleanup(func() {
+		location, workDays, hideNonWorkingDays = time.Local, defaultWorkDays, false
+	})
+	location = time.UTC
+	workDays = []time.Weekday{time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday}
+	s := getTestMemoryStore(t, config{})
+	store = s
+	for _, day := range []string{"2026-10-09", "2026-10-10", "2026-10-11", "2026-10-12"} {
+		timestamp, err := time.Parse(dayFormat, day)
+		require.NoError(t, err)
+		putEntry(t, s, rootBucketName, entry{Timestamp: timestamp.Add(10 * time.Hour), Content: []byte(day)})
+	}
+	from, to := time.Date(2026, 10, 9, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC)
+
+	perDay, err := GetRange(from, to, false)
+	require.NoError(t, err)
+	assert.Len(t, perDay, 4)
+	SetHideNonWorkingDays(true)
+	perDay, err = GetRange(from, to, false)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"2026-10-11", "2026-10-12"}, lo.Keys(perDay), "friday and saturday are off")
+	flat, err := GetRange(from, to, true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"2026-10-11", "2026-10-12"}, lo.Map(flat[flatEntriesPlaceholder], func(e Entry, _ int) string {
+		return e.Content
+	}))
+}
+
 func TestGetLastDurationFromBucket(t *testing.T) {
 	testCases := []struct {
 		name        string
@@ -451,7 +855,7 @@ func TestGetLastDurationFromBucket(t *testing.T) {
 	}{
 		{
 			name:        "bad interval",
-			interval:    "1080h",
+			interval:    "1080s",
 			shouldError: true,
 		},
 		{
@@ -475,7 +879,7 @@ func TestGetLastDurationFromBucket(t *testing.T) {
 					if !tc.shouldError {
 						d, err := parseDuration(tc.interval)
 						require.NoError(t, err)
-						expectedStart := time.Now().Add(-1 * d)
+						expectedStart := d.before(time.Now())
 						expectedEnd := time.Now()
 
 						exY, exM, exD := expectedStart.Date()
@@ -497,9 +901,9 @@ func TestGetLastDurationFromBucket(t *testing.T) {
 						}
 					}
 					if tc.flat {
-						return []string{}, nil
+						return []Entry{}, nil
 					}
-					return map[string][]string{}, nil
+					return map[string][]Entry{}, nil
 				},
 			}
 			_, err := GetLastDurationFromBucket(tc.bucketName, tc.interval, tc.flat)
@@ -511,3 +915,349 @@ func TestGetLastDurationFromBucket(t *testing.T) {
 		})
 	}
 }
+
+func TestGetRangeFromBucket(t *testing.T) {
+	from := time.Date(2026, time.September, 1, 15, 0, 0, 0, time.UTC)
+	to := time.Date(2026, time.September, 30, 2, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name        string
+		bucketName  string
+		from        time.Time
+		to          time.Time
+		flat        bool
+		shouldError bool
+	}{
+		{
+			name:        "missing start",
+			bucketName:  randString(10),
+			to:          to,
+			shouldError: true,
+		},
+		{
+			name:        "missing end",
+			bucketName:  randString(10),
+			from:        from,
+			shouldError: true,
+		},
+		{
+			name:        "start after end",
+			bucketName:  randString(10),
+			from:        to,
+			to:          from,
+			shouldError: true,
+		},
+		{
+			name:        "reserved bucket",
+			bucketName:  "_meta",
+			from:        from,
+			to:          to,
+			shouldError: true,
+		},
+		{
+			name:       "single day",
+			bucketName: randString(10),
+			from:       from,
+			to:         from.Add(time.Hour),
+			flat:       true,
+		},
+		{
+			name:       "flat",
+			bucketName: randString(10),
+			from:       from,
+			to:         to,
+			flat:       true,
+		},
+		{
+			name:       "aggregated",
+			bucketName: randString(10),
+			from:       from,
+			to:         to,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store = &entryStoreMock{
+				GetRangeWithAggregationFunc: func(bucketName string, start, end time.Time, f aggregationFunction) (any, error) {
+					require.False(t, tc.shouldError)
+					assert.Equal(t, tc.bucketName, bucketName)
+					assert.Equal(t, time.Date(2026, time.September, 1, 0, 0, 0, 0, location), start)
+					assert.Equal(t, dayIn(tc.to, location), end)
+					if tc.flat {
+						assert.Equal(t, reflect.ValueOf(flatAggregation).Pointer(), reflect.ValueOf(f).Pointer())
+						return []Entry{}, nil
+					}
+					assert.Equal(t, reflect.ValueOf(perDayAggregation).Pointer(), reflect.ValueOf(f).Pointer())
+					return map[string][]Entry{}, nil
+				},
+			}
+			_, err := GetRangeFromBucket(tc.bucketName, tc.from, tc.to, tc.flat)
+			if tc.shouldError {
+				require.IsType(t, DidError{}, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestArchive(t *testing.T) {
+	t.Cleanup(func() { archive = nil })
+	s := getTestMemoryStore(t, config{})
+	store = s
+	old := time.Now().AddDate(0, 0, -30)
+	oldID := putEntry(t, s, rootBucketName, entry{Timestamp: old, Content: []byte("typo")})
+	require.NoError(t, s.Update(rootBucketName, entry{ID: oldID, Content: []byte("old")}))
+	putEntry(t, s, "work", entry{Timestamp: old, Content: []byte("old work")})
+	require.NoError(t, AddEntry("new"))
+
+	archive = nil
+	_, err := Archive(time.Now())
+	require.IsType(t, DidError{}, err, "there's no archive to write to")
+
+	arch := getTestMemoryStore(t, config{})
+	archive = arch
+	result, err := Archive(startOfDay(time.Now()))
+	require.NoError(t, err)
+	assert.Equal(t, ArchiveResult{Archived: 2}, result)
+	archived, err := collectEntries(arch, func(string, entry) bool { return true })
+	require.NoError(t, err)
+	require.Len(t, archived, 2)
+	left, err := collectEntries(s, func(string, entry) bool { return true })
+	require.NoError(t, err)
+	require.Len(t, left, 1)
+	assert.Equal(t, "new", string(left[0].Content))
+	_, err = arch.Get(rootBucketName, oldID)
+	require.NoError(t, err, "the archived entries keep their ids")
+	revisions, err := arch.History(rootBucketName, oldID)
+	require.NoError(t, err)
+	require.Len(t, revisions, 1, "the archived entries keep their history")
+	assert.Equal(t, "typo", string(revisions[0].Content))
+
+	entries, err := GetLastDuration("31d", true)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"old", "new"}, lo.Map(entries[flatEntriesPlaceholder], func(e Entry, _ int) string {
+		return e.Content
+	}), "the archived entries are still returned, in order")
+	results, err := Search("old", SearchFilter{})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	putEntry(t, s, rootBucketName, entry{Timestamp: old, Content: []byte("old")})
+	result, err = Archive(startOfDay(time.Now()))
+	require.NoError(t, err)
+	assert.Equal(t, ArchiveResult{Archived: 1}, result)
+	archived, err = collectEntries(arch, func(string, entry) bool { return true })
+	require.NoError(t, err)
+	assert.Len(t, archived, 2, "the entries already archived aren't copied twice")
+}
+
+func TestArchiveCutoff(t *testing.T) {
+	t.Cleanup(func() {
+		if archive != nil {
+			archive.Close()
+		}
+		archive, archiveConfig, archiveReadOnly = nil, nil, false
+	})
+	archivePath := filepath.Join(t.TempDir(), "store.archive.db")
+	archiveConfig = &config{StorePath: archivePath, Backend: backendBolt}
+	s := getTestMemoryStore(t, config{})
+	store = s
+	old := time.Now().AddDate(0, 0, -30)
+	putEntry(t, s, rootBucketName, entry{Timestamp: old, Content: []byte("old")})
+	require.NoError(t, AddEntry("new"))
+
+	result, err := Archive(startOfDay(time.Now().AddDate(0, 0, -7)))
+	require.NoError(t, err)
+	assert.Equal(t, ArchiveResult{Archived: 1}, result)
+	newest, ok, err := readArchiveCutoff(archivePath)
+	require.NoError(t, err)
+	require.True(t, ok)
+	assert.True(t, old.Equal(newest))
+
+	reopen := func() {
+		require.NoError(t, archive.Close())
+		archive = nil
+	}
+	reopen()
+	today, err := GetToday()
+	require.NoError(t, err)
+	assert.Len(t, today, 1)
+	assert.Nil(t, archive, "the queries after the newest archived entry leave the archive alone")
+	entries, err := GetLastDuration("31d", true)
+	require.NoError(t, err)
+	assert.Len(t, entries[flatEntriesPlaceholder], 2)
+	require.NotNil(t, archive)
+
+	reopen()
+	require.NoError(t, os.Remove(archiveCutoffPath(archivePath)))
+	entries, err = GetLastDuration("31d", true)
+	require.NoError(t, err)
+	assert.Len(t, entries[flatEntriesPlaceholder], 2)
+	newest, ok, err = readArchiveCutoff(archivePath)
+	require.NoError(t, err)
+	require.True(t, ok, "the cutoff of older archives is found out on first use")
+	assert.True(t, old.Equal(newest))
+}
+
+func TestApplyRetention(t *testing.T) {
+	t.Cleanup(func() {
+		archive = nil
+		retention = nil
+	})
+	s := getTestMemoryStore(t, config{})
+	store = s
+	archive = getTestMemoryStore(t, config{})
+
+	retention = nil
+	_, err := ApplyRetention()
+	require.IsType(t, DidError{}, err)
+	retention = &retentionConfig{Buckets: map[string]retentionPolicy{"work": {Keep: "forever"}}}
+	_, err = ApplyRetention()
+	require.IsType(t, DidError{}, err)
+	retention = &retentionConfig{Buckets: map[string]retentionPolicy{"work": {Keep: "7d", Action: "shred"}}}
+	_, err = ApplyRetention()
+	require.IsType(t, DidError{}, err)
+
+	for _, bucketName := range []string{rootBucketName, "work", "scratch"} {
+		putEntry(t, s, bucketName, entry{Timestamp: time.Now().AddDate(0, 0, -10), Content: []byte("old")})
+		putEntry(t, s, bucketName, entry{Timestamp: time.Now().AddDate(0, 0, -3), Content: []byte("recent")})
+	}
+	retention = &retentionConfig{Buckets: map[string]retentionPolicy{
+		"work":    {Keep: "7d"},
+		"scratch": {Keep: "7d", Action: retentionDelete},
+	}}
+	result, err := ApplyRetention()
+	require.NoError(t, err)
+	assert.Equal(t, ArchiveResult{Archived: 1, Deleted: 1}, result)
+
+	left, err := collectEntries(s, func(string, entry) bool { return true })
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"root old", "root recent", "work recent", "scratch recent"},
+		lo.Map(left, func(e bucketEntry, _ int) string { return e.parentBucketName + " " + string(e.Content) }))
+	archived, err := collectEntries(archive, func(string, entry) bool { return true })
+	require.NoError(t, err)
+	require.Len(t, archived, 1)
+	assert.Equal(t, "work", archived[0].parentBucketName)
+
+	entries, err := GetLastDurationFromBucket("work", "11d", true)
+	require.NoError(t, err)
+	assert.Len(t, entries[flatEntriesPlaceholder], 2)
+}
+
+func TestApplyRetentionNestedBuckets(t *testing.T) {
+	t.Cleanup(func() {
+		archive = nil
+		retention = nil
+	})
+	s := getTestMemoryStore(t, config{})
+	store = s
+	archive = getTestMemoryStore(t, config{})
+	for _, bucketName := range []string{"work", "work/payments", "work/payments/refunds", "workshop"} {
+		putEntry(t, s, bucketName, entry{Timestamp: time.Now().AddDate(0, 0, -10), Content: []byte("old")})
+	}
+	retention = &retentionConfig{Buckets: map[string]retentionPolicy{
+		"work":          {Keep: "7d"},
+		"work/payments": {Keep: "7d", Action: retentionDelete},
+	}}
+	result, err := ApplyRetention()
+	require.NoError(t, err)
+	assert.Equal(t, ArchiveResult{Archived: 1, Deleted: 2}, result)
+
+	left, err := collectEntries(s, func(string, entry) bool { return true })
+	require.NoError(t, err)
+	assert.Equal(t, []string{"workshop"}, lo.Map(left, func(e bucketEntry, _ int) string { return e.parentBucketName }))
+	archived, err := collectEntries(archive, func(string, entry) bool { return true })
+	require.NoError(t, err)
+	assert.Equal(t, []string{"work"}, lo.Map(archived, func(e bucketEntry, _ int) string { return e.parentBucketName }),
+		"the nearest policy wins")
+}
+
+func TestBuckets(t *testing.T) {
+	store = &entryStoreMock{}
+	_, err := ListBuckets()
+	require.IsType(t, DidError{}, err)
+
+	store = getTestMemoryStore(t, config{})
+	require.NoError(t, AddEntryToBucket("work", "msg1"))
+	require.NoError(t, AddEntryToBucket("work", "msg2"))
+	require.NoError(t, AddEntryToBucket("home", "msg3"))
+
+	_, err = GetBucketStats("nope")
+	require.IsType(t, DidError{}, err)
+	require.IsType(t, DidError{}, RenameBucket("work", "work"))
+	require.IsType(t, DidError{}, RenameBucket("work", "_meta"))
+	require.IsType(t, DidError{}, RenameBucket("", "job"))
+	_, err = MergeBuckets("nope", "work")
+	require.IsType(t, DidError{}, err)
+
+	count, err := CopyBucket("home", "backup")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	count, err = MergeBuckets("home", "work")
+	require.NoError(t, err)
+	assert.Equal(t, 1, count)
+	require.NoError(t, RenameBucket("work", "job"))
+	stats, err := ListBuckets()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"backup", "job"}, lo.Map(stats, func(s BucketStats, _ int) string { return s.Name }))
+	job, err := GetBucketStats("job")
+	require.NoError(t, err)
+	assert.Equal(t, 3, job.Entries)
+
+	count, err = DeleteBucket("job")
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+	_, err = GetBucketStats("job")
+	require.IsType(t, DidError{}, err)
+	trashed, err := ListTrash()
+	require.NoError(t, err)
+	assert.Len(t, trashed, 3, "the entries of the deleted bucket must be recoverable")
+}
+
+func TestNestedBuckets(t *testing.T) {
+	t.Cleanup(func() { groupByBucket = false })
+	store = getTestMemoryStore(t, config{})
+	require.NoError(t, AddEntryToBucket("work", "msg1"))
+	require.NoError(t, AddEntryToBucket("work/payments", "msg2"))
+	require.NoError(t, AddEntryToBucket("work/payments/refunds", "msg3"))
+	require.NoError(t, AddEntryToBucket("workshop", "msg4"))
+	require.IsType(t, DidError{}, AddEntryToBucket("work//payments", "msg"))
+
+	today, err := GetTodayFromBucket("work")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"msg1", "msg2", "msg3"}, lo.Map(today, func(e Entry, _ int) string { return e.Content }))
+	today, err = GetTodayFromBucket("work/payments/refunds")
+	require.NoError(t, err)
+	assert.Len(t, today, 1)
+
+	SetGroupByBucket(true)
+	grouped, err := GetThisWeekFromBucket("work", false)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"work":                  {"msg1"},
+		"work/payments":         {"msg2"},
+		"work/payments/refunds": {"msg3"},
+	}, lo.MapValues(grouped, func(entries []Entry, _ string) []string {
+		return lo.Map(entries, func(e Entry, _ int) string { return e.Content })
+	}))
+	flat, err := GetThisWeekFromBucket("work", true)
+	require.NoError(t, err)
+	assert.Len(t, flat[flatEntriesPlaceholder], 3, "flat queries aren't grouped")
+
+	require.IsType(t, DidError{}, RenameBucket("work", "work/old"))
+	count, err := CopyBucket("work/payments", "archive")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	today, err = GetTodayFromBucket("archive/refunds")
+	require.NoError(t, err)
+	assert.Len(t, today, 1, "the nested buckets are copied to the same path")
+	count, err = DeleteBucket("work")
+	require.NoError(t, err)
+	assert.Equal(t, 3, count)
+	stats, err := ListBuckets()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"archive", "archive/refunds", "workshop"}, lo.Map(stats, func(s BucketStats, _ int) string {
+		return s.Name
+	}))
+}
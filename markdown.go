@@ -0,0 +1,1173 @@
+package godid
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+const (
+	markdownExt = ".md"
+	// markdownTimeFormat is the clock of the bullets, in the home location. Hand written bullets may leave out the
+	// seconds.
+	markdownTimeFormat = "15:04:05"
+
+	markdownFieldTags     = "tags"
+	markdownFieldProject  = "project"
+	markdownFieldDuration = "duration"
+	markdownFieldAuthor   = "author"
+	// markdownFieldZone holds the zone the entry was logged in, when it's not the home one
+	markdownFieldZone = "zone"
+
+	// markdownTrashFile and markdownLastChangeFile sit next to the parent bucket directories, their reserved names
+	// keeping them apart
+	markdownTrashFile      = trashBucketName + ".json"
+	markdownHistoryFile    = historyBucketName + ".json"
+	markdownLastChangeFile = reservedBucketPrefix + lastChangeKey + ".json"
+)
+
+var (
+	markdownBulletPattern = regexp.MustCompile(`^[-*] (\d{1,2}:\d{2}(?::\d{2})?) (.*)$`)
+	// markdownFieldPattern matches the inline fields ending a bullet, e.g. [project:: payments]
+	markdownFieldPattern = regexp.MustCompile(`\s*\[([^\[\]:]+):: ([^\[\]]*)\]$`)
+	// markdownSequencePattern matches the block id telling apart the entries logged in the same second, e.g. ^2
+	markdownSequencePattern = regexp.MustCompile(`\s+\^(\d+)$`)
+	// markdownContentFieldPattern and markdownContentSequencePattern match the end of a content that would be read as
+	// an inline field or a block id, along with the backslashes escaping it
+	markdownContentFieldPattern    = regexp.MustCompile(`(\\*)\[[^\[\]:]+:: [^\[\]]*\]$`)
+	markdownContentSequencePattern = regexp.MustCompile(`\s(\\*)\^\d+$`)
+)
+
+// markdownStore is an entryStore keeping a journal of Markdown files: every day bucket is a file named after the day
+// inside the directory of its parent bucket, e.g. root/2026-10-18.md or work/payments/2026-10-18.md for a nested one,
+// holding one bullet per entry:
+//
+//   - 14:32:05 Reviewed the billing migration [tags:: billing] [project:: payments]
+//
+// The files can be edited by hand, the lines that aren't bullets starting with a time are left alone. Timestamps are
+// kept to the second and newlines in the content are turned into spaces. A content ending like an inline field or a
+// block id gets a backslash before that end.
+type markdownStore struct {
+	dir string
+	// loc is the home location, deciding the day buckets and the clock of the bullets
+	loc *time.Location
+}
+
+// markdownBullet is an entry parsed out of the line of a day file
+type markdownBullet struct {
+	line  int
+	seq   uint64
+	entry entry
+}
+
+// newMarkdownStore creates an entryStore keeping the journal in the directory at the store path
+func newMarkdownStore(cfg config) (*markdownStore, error) {
+	dir, err := cfg.GetStorePath()
+	if err != nil {
+		return nil, err
+	}
+	loc, err := cfg.GetLocation()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &markdownStore{
+		dir: dir,
+		loc: loc,
+	}, nil
+}
+
+func (s *markdownStore) Put(parentBucketName string, e entry) (string, error) {
+	if err := checkMarkdownBucket(parentBucketName); err != nil {
+		return "", err
+	}
+	day, err := getBucketFromEntry(e, s.loc)
+	if err != nil {
+		return "", err
+	}
+	lines, bullets, err := s.readDay(parentBucketName, day)
+	if err != nil {
+		return "", err
+	}
+	if len(lines) == 0 {
+		lines = []string{"# " + day, ""}
+	}
+	e.Timestamp = e.Timestamp.Truncate(time.Second)
+	// the block ids of the trashed and edited entries stay taken, the day files only holding the current ones
+	last, err := s.lastRecordSequence(parentBucketName, e.Timestamp)
+	if err != nil {
+		return "", err
+	}
+	taken := make(map[uint64]bool)
+	for _, b := range bullets {
+		if b.entry.Timestamp.Equal(e.Timestamp) {
+			last = max(last, b.seq)
+			taken[b.seq] = true
+		}
+	}
+	seq := last + 1
+	if key := keptKey(e); key != nil && !taken[keySequence(key)] {
+		seq = keySequence(key)
+	}
+	lines = append(lines, s.formatBullet(e, seq))
+	if err := s.writeDay(parentBucketName, day, lines); err != nil {
+		return "", err
+	}
+	return formatID(encodeKey(e.Timestamp, seq)), nil
+}
+
+func (s *markdownStore) Get(parentBucketName string, id string) (entry, error) {
+	_, _, b, err := s.findBullet(parentBucketName, id)
+	if err != nil {
+		return entry{}, err
+	}
+	return b.entry, nil
+}
+
+func (s *markdownStore) Update(parentBucketName string, e entry) error {
+	lines, day, b, err := s.findBullet(parentBucketName, e.ID)
+	if err != nil {
+		return err
+	}
+	v, err := encodeValue(b.entry)
+	if err != nil {
+		return err
+	}
+	var records []revisionRecord
+	if err := readJSONFile(filepath.Join(s.dir, markdownHistoryFile), &records); err != nil {
+		return err
+	}
+	records = append(records, revisionRecord{Bucket: parentBucketName, ID: e.ID, ReplacedAt: time.Now(), Value: v})
+	if err := writeJSONFile(filepath.Join(s.dir, markdownHistoryFile), records); err != nil {
+		return err
+	}
+	e.Timestamp = b.entry.Timestamp
+	lines[b.line] = s.formatBullet(e, b.seq)
+	return s.writeDay(parentBucketName, day, lines)
+}
+
+func (s *markdownStore) SetHistory(parentBucketName string, id string, revisions []revision) error {
+	if _, err := parseID(id); err != nil {
+		return err
+	}
+	var records []revisionRecord
+	if err := readJSONFile(filepath.Join(s.dir, markdownHistoryFile), &records); err != nil {
+		return err
+	}
+	records = lo.Reject(records, func(r revisionRecord, _ int) bool {
+		return r.Bucket == parentBucketName && r.ID == id
+	})
+	for _, r := range revisions {
+		v, err := encodeValue(r.entry)
+		if err != nil {
+			return err
+		}
+		records = append(records, revisionRecord{Bucket: parentBucketName, ID: id, ReplacedAt: r.replacedAt, Value: v})
+	}
+	return writeJSONFile(filepath.Join(s.dir, markdownHistoryFile), records)
+}
+
+func (s *markdownStore) History(parentBucketName string, id string) ([]revision, error) {
+	if _, err := parseID(id); err != nil {
+		return nil, err
+	}
+	var records []revisionRecord
+	if err := readJSONFile(filepath.Join(s.dir, markdownHistoryFile), &records); err != nil {
+		return nil, err
+	}
+	result := make([]revision, 0)
+	for _, r := range records {
+		if r.Bucket != parentBucketName || r.ID != id {
+			continue
+		}
+		rev, err := r.revision(s.loc)
+		if err != nil {
+			return nil, err
+		}
+		if s.inHomeZone(rev.Timestamp) {
+			rev.Timestamp = rev.Timestamp.In(s.loc)
+		}
+		result = append(result, rev)
+	}
+	return result, nil
+}
+
+func (s *markdownStore) Delete(parentBucketName string, id string) error {
+	lines, day, b, err := s.findBullet(parentBucketName, id)
+	if err != nil {
+		return err
+	}
+	lines = append(lines[:b.line], lines[b.line+1:]...)
+	return s.writeDay(parentBucketName, day, lines)
+}
+
+func (s *markdownStore) GetRange(parentBucketName string, start, end time.Time) ([]entry, error) {
+	r, err := newDayRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+	first, last := r.bucketBounds(s.loc)
+	days, err := s.listDays(parentBucketName)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]entry, 0)
+	for _, day := range days {
+		if day < first || day > last {
+			continue
+		}
+		dayEntries, err := s.getDayEntries(parentBucketName, day)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range dayEntries {
+			if r.contains(e.Timestamp) {
+				result = append(result, e)
+			}
+		}
+	}
+	return result, nil
+}
+
+func (s *markdownStore) GetRangeWithAggregation(parentBucketName string, start, end time.Time, agg aggregationFunction) (any, error) {
+	if agg == nil {
+		return nil, errors.New("aggregation function is nil")
+	}
+	entries, err := s.GetRange(parentBucketName, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return agg(entries)
+}
+
+func (s *markdownStore) ForEach(fn func(parentBucketName string, e entry) error) error {
+	parentBuckets, err := s.listParentBuckets()
+	if err != nil {
+		return err
+	}
+	for _, parentBucketName := range parentBuckets {
+		days, err := s.listDays(parentBucketName)
+		if err != nil {
+			return err
+		}
+		for _, day := range days {
+			dayEntries, err := s.getDayEntries(parentBucketName, day)
+			if err != nil {
+				return err
+			}
+			for _, e := range dayEntries {
+				if err := fn(parentBucketName, e); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Search reads all the entries of the filtered buckets, the journal not keeping a full-text index
+func (s *markdownStore) Search(q searchQuery, f SearchFilter) ([]searchHit, error) {
+	candidates := make([]searchHit, 0)
+	err := s.ForEach(func(parentBucketName string, e entry) error {
+		if f.containsBucket(parentBucketName) && f.contains(e.Timestamp) {
+			candidates = append(candidates, searchHit{parentBucketName: parentBucketName, entry: e})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return q.scanSearch(candidates), nil
+}
+
+// Trash adds the entry to the trash file before removing its bullet, a failure leaving it in both rather than in none
+func (s *markdownStore) Trash(parentBucketName string, id string, deletedAt time.Time) error {
+	lines, day, b, err := s.findBullet(parentBucketName, id)
+	if err != nil {
+		return err
+	}
+	key, err := parseID(id)
+	if err != nil {
+		return err
+	}
+	v, err := encodeValue(b.entry)
+	if err != nil {
+		return err
+	}
+	records, err := s.readTrash()
+	if err != nil {
+		return err
+	}
+	records = append(records, trashRecord{Bucket: parentBucketName, ID: formatID(key), DeletedAt: deletedAt, Value: v})
+	if err := s.writeTrash(records); err != nil {
+		return err
+	}
+	lines = append(lines[:b.line], lines[b.line+1:]...)
+	return s.writeDay(parentBucketName, day, lines)
+}
+
+func (s *markdownStore) Untrash(parentBucketName string, id string) (entry, error) {
+	records, err := s.readTrash()
+	if err != nil {
+		return entry{}, err
+	}
+	_, i, ok := lo.FindIndexOf(records, func(r trashRecord) bool {
+		return r.Bucket == parentBucketName && r.ID == id
+	})
+	if !ok {
+		return entry{}, trashedEntryNotFoundError(parentBucketName, id)
+	}
+	if _, _, _, err := s.findBullet(parentBucketName, id); err == nil {
+		return entry{}, didErrorf("an entry with id %s already exists in bucket %s", id, parentBucketName)
+	}
+	t, err := s.trashed(records[i])
+	if err != nil {
+		return entry{}, err
+	}
+	key, err := parseID(id)
+	if err != nil {
+		return entry{}, err
+	}
+	day, err := getBucketFromEntry(t.entry, s.loc)
+	if err != nil {
+		return entry{}, err
+	}
+	lines, bullets, err := s.readDay(parentBucketName, day)
+	if err != nil {
+		return entry{}, err
+	}
+	if len(lines) == 0 {
+		lines = []string{"# " + day, ""}
+	}
+	// the bullet goes back where it was, before the ones logged after it
+	seq := keySequence(key)
+	at := len(lines)
+	for _, b := range bullets {
+		if b.entry.Timestamp.After(t.Timestamp) || (b.entry.Timestamp.Equal(t.Timestamp) && b.seq > seq) {
+			at = min(at, b.line)
+		}
+	}
+	lines = append(lines[:at], append([]string{s.formatBullet(t.entry, seq)}, lines[at:]...)...)
+	if err := s.writeDay(parentBucketName, day, lines); err != nil {
+		return entry{}, err
+	}
+	return t.entry, s.writeTrash(append(records[:i], records[i+1:]...))
+}
+
+func (s *markdownStore) ListTrash() ([]trashedEntry, error) {
+	records, err := s.readTrash()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]trashedEntry, 0, len(records))
+	for _, r := range records {
+		t, err := s.trashed(r)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, t)
+	}
+	sortTrash(result)
+	return result, nil
+}
+
+func (s *markdownStore) EmptyTrash() (int, error) {
+	records, err := s.readTrash()
+	if err != nil {
+		return 0, err
+	}
+	err = os.Remove(filepath.Join(s.dir, markdownTrashFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return 0, err
+	}
+	return len(records), nil
+}
+
+func (s *markdownStore) SetLastChange(c *change) error {
+	path := filepath.Join(s.dir, markdownLastChangeFile)
+	if c == nil {
+		err := os.Remove(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	v, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return replaceFile(path, func(tmp string) error {
+		return os.WriteFile(tmp, v, 0600)
+	})
+}
+
+func (s *markdownStore) LastChange() (*change, error) {
+	v, err := os.ReadFile(filepath.Join(s.dir, markdownLastChangeFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := &change{}
+	if err := json.Unmarshal(v, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *markdownStore) Buckets() ([]BucketStats, error) {
+	parentBuckets, err := s.listParentBuckets()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]BucketStats, 0)
+	for _, parentBucketName := range parentBuckets {
+		stats, err := s.bucketStats(parentBucketName)
+		if err != nil {
+			return nil, err
+		}
+		if stats.Entries > 0 {
+			result = append(result, stats)
+		}
+	}
+	return result, nil
+}
+
+func (s *markdownStore) ChildBuckets(parentBucketName string) ([]string, error) {
+	parentBuckets, err := s.listParentBuckets()
+	if err != nil {
+		return nil, err
+	}
+	return lo.Filter(parentBuckets, func(name string, _ int) bool {
+		return name != parentBucketName && isInBucket(name, parentBucketName)
+	}), nil
+}
+
+// treeEntries counts the entries of a parent bucket and of the ones nested in it
+func (s *markdownStore) treeEntries(parentBucketName string) (int, error) {
+	parentBuckets, err := s.listParentBuckets()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, name := range parentBuckets {
+		if !isInBucket(name, parentBucketName) {
+			continue
+		}
+		stats, err := s.bucketStats(name)
+		if err != nil {
+			return 0, err
+		}
+		count += stats.Entries
+	}
+	return count, nil
+}
+
+// RenameBucket moves the parent bucket directory, the notes next to the days and the nested buckets included. When
+// the new directory is already there, its contents are merged with the moved ones.
+func (s *markdownStore) RenameBucket(oldName, newName string) error {
+	if err := checkMarkdownBucket(newName); err != nil {
+		return err
+	}
+	count, err := s.treeEntries(oldName)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return bucketNotFoundError(oldName)
+	}
+	if count, err = s.treeEntries(newName); err != nil {
+		return err
+	}
+	if count > 0 {
+		return bucketExistsError(newName)
+	}
+	oldDir, newDir := s.bucketDir(oldName), s.bucketDir(newName)
+	if err := checkMoveDir(oldDir, newDir, false); err != nil {
+		return err
+	}
+	if err := moveDir(oldDir, newDir); err != nil {
+		return err
+	}
+	records, err := s.readTrash()
+	if err != nil {
+		return err
+	}
+	renamed := false
+	for i := range records {
+		if isInBucket(records[i].Bucket, oldName) {
+			records[i].Bucket, renamed = rebaseBucket(records[i].Bucket, oldName, newName), true
+		}
+	}
+	if renamed {
+		if err := s.writeTrash(records); err != nil {
+			return err
+		}
+	}
+	var revisions []revisionRecord
+	historyPath := filepath.Join(s.dir, markdownHistoryFile)
+	if err := readJSONFile(historyPath, &revisions); err != nil {
+		return err
+	}
+	renamed = false
+	for i := range revisions {
+		if isInBucket(revisions[i].Bucket, oldName) {
+			revisions[i].Bucket, renamed = rebaseBucket(revisions[i].Bucket, oldName, newName), true
+		}
+	}
+	if !renamed {
+		return nil
+	}
+	return writeJSONFile(historyPath, revisions)
+}
+
+// checkMoveDir makes sure moveDir won't have to overwrite a file, the day files being left out when they get merged
+// rather than moved
+func checkMoveDir(oldDir, newDir string, mergeDays bool) error {
+	dirEntries, err := os.ReadDir(oldDir)
+	if err != nil {
+		return err
+	}
+	for _, d := range dirEntries {
+		if _, ok := dayFileName(d); ok && mergeDays {
+			continue
+		}
+		dst := filepath.Join(newDir, d.Name())
+		info, err := os.Stat(dst)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || !info.IsDir() {
+			return didErrorf("%s already exists", dst)
+		}
+		if err := checkMoveDir(filepath.Join(oldDir, d.Name()), dst, mergeDays); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveDir moves the directory to the new path, merging it with the directory already there
+func moveDir(oldDir, newDir string) error {
+	if _, err := os.Stat(newDir); errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(filepath.Dir(newDir), 0700); err != nil {
+			return err
+		}
+		return os.Rename(oldDir, newDir)
+	}
+	dirEntries, err := os.ReadDir(oldDir)
+	if err != nil {
+		return err
+	}
+	for _, d := range dirEntries {
+		if err := moveDir(filepath.Join(oldDir, d.Name()), filepath.Join(newDir, d.Name())); err != nil {
+			return err
+		}
+	}
+	return os.Remove(oldDir)
+}
+
+// MergeBucket appends the bullets of every day file to the one of the same day in the other bucket, the entries whose
+// id is taken there, by a bullet, a trashed entry or a revision, getting the next free block id of their second. The
+// rest of the directory, like the notes next to the days, is moved like in RenameBucket.
+func (s *markdownStore) MergeBucket(src, dst string) (int, error) {
+	if err := checkMarkdownBucket(dst); err != nil {
+		return 0, err
+	}
+	count, err := s.treeEntries(src)
+	if err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return 0, bucketNotFoundError(src)
+	}
+	srcDir, dstDir := s.bucketDir(src), s.bucketDir(dst)
+	if err := checkMoveDir(srcDir, dstDir, true); err != nil {
+		return 0, err
+	}
+	records, err := s.readTrash()
+	if err != nil {
+		return 0, err
+	}
+	var revisions []revisionRecord
+	historyPath := filepath.Join(s.dir, markdownHistoryFile)
+	if err := readJSONFile(historyPath, &revisions); err != nil {
+		return 0, err
+	}
+	parentBuckets, err := s.listParentBuckets()
+	if err != nil {
+		return 0, err
+	}
+	// rekeyed maps the moved parent buckets to the ids given instead of the taken ones
+	rekeyed := make(map[string]map[string]string)
+	for _, name := range parentBuckets {
+		if !isInBucket(name, src) {
+			continue
+		}
+		if rekeyed[name], err = s.mergeDays(name, rebaseBucket(name, src, dst), records, revisions); err != nil {
+			return 0, err
+		}
+	}
+	if err := moveDir(srcDir, dstDir); err != nil {
+		return 0, err
+	}
+	for i, r := range records {
+		if isInBucket(r.Bucket, src) {
+			records[i].Bucket = rebaseBucket(r.Bucket, src, dst)
+			records[i].ID = lo.ValueOr(rekeyed[r.Bucket], r.ID, r.ID)
+		}
+	}
+	if err := s.writeTrash(records); err != nil {
+		return 0, err
+	}
+	for i, r := range revisions {
+		if isInBucket(r.Bucket, src) {
+			revisions[i].Bucket = rebaseBucket(r.Bucket, src, dst)
+			revisions[i].ID = lo.ValueOr(rekeyed[r.Bucket], r.ID, r.ID)
+		}
+	}
+	return count, writeJSONFile(historyPath, revisions)
+}
+
+// mergeDays appends the bullets of the day files of a single parent bucket to the ones of the new parent bucket,
+// removing the merged files. It returns the ids given instead of the ones taken in the new parent bucket, the ones of
+// the trashed entries included.
+func (s *markdownStore) mergeDays(oldName, newName string, records []trashRecord, revisions []revisionRecord) (map[string]string, error) {
+	taken := make(map[string]bool)
+	for _, r := range records {
+		taken[r.ID] = taken[r.ID] || r.Bucket == newName
+	}
+	for _, r := range revisions {
+		taken[r.ID] = taken[r.ID] || r.Bucket == newName
+	}
+	newDays, err := s.listDays(newName)
+	if err != nil {
+		return nil, err
+	}
+	for _, day := range newDays {
+		_, bullets, err := s.readDay(newName, day)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range bullets {
+			taken[b.entry.ID] = true
+		}
+	}
+	rekeyed := make(map[string]string)
+	// rekey gives a taken id the next block id free at its second
+	rekey := func(id string) (string, uint64, error) {
+		key, err := parseID(id)
+		if err != nil {
+			return "", 0, err
+		}
+		t, err := decodeKey(key)
+		if err != nil {
+			return "", 0, err
+		}
+		seq := keySequence(key)
+		for taken[formatID(encodeKey(t, seq))] {
+			seq++
+		}
+		newID := formatID(encodeKey(t, seq))
+		taken[newID], rekeyed[id] = true, newID
+		return newID, seq, nil
+	}
+	days, err := s.listDays(oldName)
+	if err != nil {
+		return nil, err
+	}
+	for _, day := range days {
+		lines, bullets, err := s.readDay(oldName, day)
+		if err != nil {
+			return nil, err
+		}
+		dayStart, err := time.ParseInLocation(dayFormat, day, s.loc)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range bullets {
+			seq := b.seq
+			if taken[b.entry.ID] {
+				if _, seq, err = rekey(b.entry.ID); err != nil {
+					return nil, err
+				}
+			}
+			taken[formatID(encodeKey(b.entry.Timestamp, seq))] = true
+			// the bullets numbered by their place in the file get their block id written, the new file numbering
+			// them differently
+			if _, parsed, _ := s.parseBullet(lines[b.line], dayStart); parsed != seq && (seq > 1 || parsed > 1) {
+				lines[b.line] = s.formatBullet(b.entry, seq)
+			}
+		}
+		newLines, _, err := s.readDay(newName, day)
+		if err != nil {
+			return nil, err
+		}
+		if len(newLines) > 0 {
+			// the bullets follow the ones of the day, the notes being set apart by a blank line
+			if lines[0] == "# "+day {
+				lines = lo.DropWhile(lines[1:], func(line string) bool { return line == "" })
+			}
+			if len(lines) > 0 {
+				if _, _, ok := s.parseBullet(lines[0], dayStart); !ok {
+					lines = append([]string{""}, lines...)
+				}
+			}
+			lines = append(newLines, lines...)
+		}
+		if err := s.writeDay(newName, day, lines); err != nil {
+			return nil, err
+		}
+		if err := os.Remove(s.dayPath(oldName, day)); err != nil {
+			return nil, err
+		}
+	}
+	for _, r := range records {
+		if r.Bucket == oldName && taken[r.ID] {
+			if _, _, err := rekey(r.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return rekeyed, nil
+}
+
+func (s *markdownStore) Close() error {
+	return nil
+}
+
+// checkMarkdownBucket makes sure every name of the parent bucket path can be used as a directory name, the nested
+// buckets being directories inside the one of their parent
+func checkMarkdownBucket(parentBucketName string) error {
+	if err := checkBucketPath(parentBucketName); err != nil {
+		return err
+	}
+	for _, segment := range strings.Split(parentBucketName, bucketPathSeparator) {
+		if strings.HasPrefix(segment, ".") || strings.Contains(segment, `\`) {
+			return didErrorf("invalid bucket name %s", parentBucketName)
+		}
+	}
+	return nil
+}
+
+func (s *markdownStore) bucketDir(parentBucketName string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(parentBucketName))
+}
+
+func (s *markdownStore) dayPath(parentBucketName, day string) string {
+	return filepath.Join(s.bucketDir(parentBucketName), day+markdownExt)
+}
+
+// readDay returns the lines of a day file along with the entries parsed out of them, no lines when there's no file
+func (s *markdownStore) readDay(parentBucketName, day string) ([]string, []markdownBullet, error) {
+	if checkMarkdownBucket(parentBucketName) != nil {
+		return nil, nil, nil
+	}
+	dayStart, err := time.ParseInLocation(dayFormat, day, s.loc)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := os.ReadFile(s.dayPath(parentBucketName, day))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	bullets := make([]markdownBullet, 0)
+	// the bullets without a block id get the lowest sequence numbers left at their time
+	used := make(map[int64]map[uint64]bool)
+	unnumbered := make([]int, 0)
+	for i, line := range lines {
+		e, seq, ok := s.parseBullet(line, dayStart)
+		if !ok {
+			continue
+		}
+		t := e.Timestamp.Unix()
+		if used[t] == nil {
+			used[t] = make(map[uint64]bool)
+		}
+		if seq > 0 && !used[t][seq] {
+			used[t][seq] = true
+		} else {
+			seq = 0
+			unnumbered = append(unnumbered, len(bullets))
+		}
+		bullets = append(bullets, markdownBullet{line: i, seq: seq, entry: e})
+	}
+	for _, i := range unnumbered {
+		b := &bullets[i]
+		t := b.entry.Timestamp.Unix()
+		b.seq = 1
+		for used[t][b.seq] {
+			b.seq++
+		}
+		used[t][b.seq] = true
+	}
+	for i := range bullets {
+		bullets[i].entry.ID = formatID(encodeKey(bullets[i].entry.Timestamp, bullets[i].seq))
+	}
+	return lines, bullets, nil
+}
+
+// lastRecordSequence returns the highest block id of the trashed entries and of the revisions of a parent bucket logged
+// at t, zero when there are none
+func (s *markdownStore) lastRecordSequence(parentBucketName string, t time.Time) (uint64, error) {
+	records, err := s.readTrash()
+	if err != nil {
+		return 0, err
+	}
+	var revisions []revisionRecord
+	if err := readJSONFile(filepath.Join(s.dir, markdownHistoryFile), &revisions); err != nil {
+		return 0, err
+	}
+	ids := lo.FilterMap(records, func(r trashRecord, _ int) (string, bool) {
+		return r.ID, r.Bucket == parentBucketName
+	})
+	ids = append(ids, lo.FilterMap(revisions, func(r revisionRecord, _ int) (string, bool) {
+		return r.ID, r.Bucket == parentBucketName
+	})...)
+	var last uint64
+	for _, id := range ids {
+		key, err := parseID(id)
+		if err != nil {
+			continue
+		}
+		if recordTime, err := decodeKey(key); err == nil && recordTime.Equal(t) {
+			last = max(last, keySequence(key))
+		}
+	}
+	return last, nil
+}
+
+// readTrash returns the records of the trash file, none when there's no file
+func (s *markdownStore) readTrash() ([]trashRecord, error) {
+	var result []trashRecord
+	err := readJSONFile(filepath.Join(s.dir, markdownTrashFile), &result)
+	return result, err
+}
+
+func (s *markdownStore) writeTrash(records []trashRecord) error {
+	return writeJSONFile(filepath.Join(s.dir, markdownTrashFile), records)
+}
+
+// readJSONFile decodes the file at path into v, leaving v alone when there's no file
+func readJSONFile(path string, v any) error {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(content, v)
+}
+
+func writeJSONFile(path string, v any) error {
+	content, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return replaceFile(path, func(tmp string) error {
+		return os.WriteFile(tmp, content, 0600)
+	})
+}
+
+func (s *markdownStore) writeDay(parentBucketName, day string, lines []string) error {
+	path := s.dayPath(parentBucketName, day)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return replaceFile(path, func(tmp string) error {
+		return os.WriteFile(tmp, []byte(strings.Join(lines, "\n")+"\n"), 0600)
+	})
+}
+
+// getDayEntries returns the entries of a day file sorted by time
+func (s *markdownStore) getDayEntries(parentBucketName, day string) ([]entry, error) {
+	_, bullets, err := s.readDay(parentBucketName, day)
+	if err != nil {
+		return nil, err
+	}
+	result := lo.Map(bullets, func(b markdownBullet, _ int) entry {
+		return b.entry
+	})
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Timestamp.Before(result[j].Timestamp)
+	})
+	return result, nil
+}
+
+// findBullet returns the lines of the day file holding the entry with the given id, along with its day and bullet
+func (s *markdownStore) findBullet(parentBucketName, id string) ([]string, string, markdownBullet, error) {
+	key, err := parseID(id)
+	if err != nil {
+		return nil, "", markdownBullet{}, err
+	}
+	timestamp, err := decodeKey(key)
+	if err != nil {
+		return nil, "", markdownBullet{}, err
+	}
+	day, err := getBucketFromTime(timestamp.In(s.loc))
+	if err != nil {
+		return nil, "", markdownBullet{}, err
+	}
+	lines, bullets, err := s.readDay(parentBucketName, day)
+	if err != nil {
+		return nil, "", markdownBullet{}, err
+	}
+	for _, b := range bullets {
+		if b.entry.ID == id {
+			return lines, day, b, nil
+		}
+	}
+	return nil, "", markdownBullet{}, entryNotFoundError(parentBucketName, id)
+}
+
+// bucketStats reads all the days of the parent bucket
+func (s *markdownStore) bucketStats(parentBucketName string) (BucketStats, error) {
+	stats := BucketStats{Name: parentBucketName}
+	days, err := s.listDays(parentBucketName)
+	if err != nil {
+		return stats, err
+	}
+	for _, day := range days {
+		dayEntries, err := s.getDayEntries(parentBucketName, day)
+		if err != nil {
+			return stats, err
+		}
+		for _, e := range dayEntries {
+			stats.addEntry(e.Timestamp.In(s.loc))
+		}
+		if len(dayEntries) > 0 {
+			stats.Days++
+		}
+	}
+	return stats, nil
+}
+
+// listParentBuckets returns the parent buckets in name order, the nested ones coming after their parent
+func (s *markdownStore) listParentBuckets() ([]string, error) {
+	result := make([]string, 0)
+	return result, s.walkParentBuckets("", &result)
+}
+
+func (s *markdownStore) walkParentBuckets(parentBucketName string, result *[]string) error {
+	dirEntries, err := os.ReadDir(s.bucketDir(parentBucketName))
+	if err != nil {
+		return err
+	}
+	for _, d := range dirEntries {
+		name := d.Name()
+		if parentBucketName != "" {
+			name = parentBucketName + bucketPathSeparator + name
+		}
+		if !d.IsDir() || checkMarkdownBucket(name) != nil {
+			continue
+		}
+		*result = append(*result, name)
+		if err := s.walkParentBuckets(name, result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// listDays returns the days of the parent bucket having a file, in chronological order
+func (s *markdownStore) listDays(parentBucketName string) ([]string, error) {
+	if checkMarkdownBucket(parentBucketName) != nil {
+		return nil, nil
+	}
+	dirEntries, err := os.ReadDir(s.bucketDir(parentBucketName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0)
+	for _, d := range dirEntries {
+		if day, ok := dayFileName(d); ok {
+			result = append(result, day)
+		}
+	}
+	return result, nil
+}
+
+// dayFileName returns the day of a day file
+func dayFileName(d os.DirEntry) (string, bool) {
+	day, ok := strings.CutSuffix(d.Name(), markdownExt)
+	if !ok || d.IsDir() {
+		return "", false
+	}
+	_, err := time.Parse(dayFormat, day)
+	return day, err == nil
+}
+
+// inHomeZone tells whether t is in the zone of the home location, which the bullets leave out
+func (s *markdownStore) inHomeZone(t time.Time) bool {
+	name, offset := t.Zone()
+	homeName, homeOffset := t.In(s.loc).Zone()
+	return name == homeName && offset == homeOffset
+}
+
+// trashed rebuilds a trashed entry like the bullets get parsed, in the home location unless logged elsewhere
+func (s *markdownStore) trashed(r trashRecord) (trashedEntry, error) {
+	t, err := r.trashed(s.loc)
+	if err != nil {
+		return trashedEntry{}, err
+	}
+	if s.inHomeZone(t.Timestamp) {
+		t.Timestamp = t.Timestamp.In(s.loc)
+	}
+	return t, nil
+}
+
+// formatBullet renders the line of an entry
+func (s *markdownStore) formatBullet(e entry, seq uint64) string {
+	var sb strings.Builder
+	sb.WriteString("- ")
+	sb.WriteString(e.Timestamp.In(s.loc).Format(markdownTimeFormat))
+	sb.WriteString(" ")
+	sb.WriteString(escapeContent(strings.Join(strings.Fields(string(e.Content)), " ")))
+	writeField := func(name, value string) {
+		fmt.Fprintf(&sb, " [%s:: %s]", name, value)
+	}
+	if len(e.Tags) > 0 {
+		writeField(markdownFieldTags, strings.Join(e.Tags, ", "))
+	}
+	if e.Project != "" {
+		writeField(markdownFieldProject, e.Project)
+	}
+	if e.Duration != 0 {
+		writeField(markdownFieldDuration, e.Duration.String())
+	}
+	if e.Author != "" {
+		writeField(markdownFieldAuthor, e.Author)
+	}
+	if !s.inHomeZone(e.Timestamp) {
+		name, _ := e.Timestamp.Zone()
+		writeField(markdownFieldZone, name+" "+e.Timestamp.Format("-07:00"))
+	}
+	for _, k := range sortedKeys(e.Extra) {
+		writeField(k, e.Extra[k])
+	}
+	if seq > 1 {
+		fmt.Fprintf(&sb, " ^%d", seq)
+	}
+	return sb.String()
+}
+
+// parseBullet reads the entry out of a line of the file of the day starting at dayStart, along with its sequence
+// number, 0 when the bullet has none
+func (s *markdownStore) parseBullet(line string, dayStart time.Time) (entry, uint64, bool) {
+	match := markdownBulletPattern.FindStringSubmatch(strings.TrimRight(line, " \t\r"))
+	if match == nil {
+		return entry{}, 0, false
+	}
+	clock := match[1]
+	if strings.Count(clock, ":") == 1 {
+		clock += ":00"
+	}
+	t, err := time.ParseInLocation(dayFormat+" "+markdownTimeFormat, dayStart.Format(dayFormat)+" "+clock, s.loc)
+	if err != nil {
+		return entry{}, 0, false
+	}
+	rest := match[2]
+	var seq uint64
+	if m := markdownSequencePattern.FindStringSubmatchIndex(rest); m != nil {
+		seq, _ = strconv.ParseUint(rest[m[2]:m[3]], 10, 64)
+		rest = rest[:m[0]]
+	}
+	e := entry{Timestamp: t}
+	fields := make([][2]string, 0)
+	for m := markdownFieldPattern.FindStringSubmatchIndex(rest); m != nil; m = markdownFieldPattern.FindStringSubmatchIndex(rest) {
+		// a field escaped by a backslash ends the content
+		if m[2] >= 2 && rest[m[2]-2] == '\\' {
+			break
+		}
+		fields = append(fields, [2]string{rest[m[2]:m[3]], rest[m[4]:m[5]]})
+		rest = rest[:m[0]]
+	}
+	for _, field := range fields {
+		if !s.parseField(&e, field[0], field[1]) {
+			if e.Extra == nil {
+				e.Extra = make(map[string]string)
+			}
+			e.Extra[field[0]] = field[1]
+		}
+	}
+	e.Content = []byte(unescapeContent(strings.TrimSpace(rest)))
+	return e, seq, true
+}
+
+// escapeContent adds a backslash before the end of a content that would be read as an inline field or a block id,
+// e.g. "Set [status:: done]" or "Shipped ^3". The backslashes already there are kept, unescapeContent taking away only
+// the added one.
+func escapeContent(content string) string {
+	for _, p := range []*regexp.Regexp{markdownContentFieldPattern, markdownContentSequencePattern} {
+		if m := p.FindStringSubmatchIndex(content); m != nil {
+			return content[:m[2]] + `\` + content[m[2]:]
+		}
+	}
+	return content
+}
+
+func unescapeContent(content string) string {
+	for _, p := range []*regexp.Regexp{markdownContentFieldPattern, markdownContentSequencePattern} {
+		if m := p.FindStringSubmatchIndex(content); m != nil {
+			if m[3] > m[2] {
+				return content[:m[2]] + content[m[2]+1:]
+			}
+			return content
+		}
+	}
+	return content
+}
+
+// parseField sets the metadata held by a known inline field, returning false for the other fields
+func (s *markdownStore) parseField(e *entry, name, value string) bool {
+	switch name {
+	case markdownFieldTags:
+		e.Tags = lo.Map(strings.Split(value, ","), func(tag string, _ int) string {
+			return strings.TrimSpace(tag)
+		})
+	case markdownFieldProject:
+		e.Project = value
+	case markdownFieldAuthor:
+		e.Author = value
+	case markdownFieldDuration:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return false
+		}
+		e.Duration = d
+	case markdownFieldZone:
+		zoneName, offsetString, _ := strings.Cut(value, " ")
+		offset, err := time.Parse("-07:00", offsetString)
+		if err != nil {
+			return false
+		}
+		_, seconds := offset.Zone()
+		e.Timestamp = e.Timestamp.In(time.FixedZone(zoneName, seconds))
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,161 @@
+package godid
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type markdownTestSuite struct {
+	storeTestSuite
+}
+
+func (s *markdownTestSuite) markdownStore() *markdownStore {
+	return s.store.(*markdownStore)
+}
+
+func (s *markdownTestSuite) readDay(day string) string {
+	content, err := os.ReadFile(s.markdownStore().dayPath(s.testBucketName, day))
+	s.Require().NoError(err)
+	return string(content)
+}
+
+func (s *markdownTestSuite) writeDay(day, content string) {
+	path := s.markdownStore().dayPath(s.testBucketName, day)
+	s.Require().NoError(os.MkdirAll(filepath.Dir(path), 0700))
+	s.Require().NoError(os.WriteFile(path, []byte(content), 0600))
+}
+
+func (s *markdownTestSuite) TestFormat() {
+	ts := timeFromString(s.T(), "2018-07-18T12:11:00Z")
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("Reviewed the\nmigration")})
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("msg2"), Metadata: Metadata{
+		Tags:     []string{"billing", "migration"},
+		Project:  "payments",
+		Duration: 90 * time.Minute,
+		Author:   "alice",
+		Extra:    map[string]string{"ticket": "PAY-12"},
+	}})
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts.Add(time.Hour).In(time.FixedZone("JST", 9*60*60)), Content: []byte("msg3")})
+	s.Equal(`# 2018-07-18
+
+- 12:11:00 Reviewed the migration
+- 12:11:00 msg2 [tags:: billing, migration] [project:: payments] [duration:: 1h30m0s] [author:: alice] [ticket:: PAY-12] ^2
+- 13:11:00 msg3 [zone:: JST +09:00]
+`, s.readDay("2018-07-18"))
+}
+
+func (s *markdownTestSuite) TestEscapedContent() {
+	ts := timeFromString(s.T(), "2018-07-18T12:11:00Z")
+	contents := []string{
+		"Shipped ^3",
+		"Set [status:: done]",
+		"[status:: done]",
+		"Two [a:: 1] [b:: 2]",
+		`Literal \^3`,
+		`Literal \\[status:: done]`,
+		"Not a field [status] ^x",
+	}
+	for _, content := range contents {
+		putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte(content)})
+		putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte(content), Metadata: Metadata{Tags: []string{"t"}}})
+	}
+	entries, err := s.store.GetRange(s.testBucketName, ts, ts)
+	s.Require().NoError(err)
+	s.Require().Len(entries, 2*len(contents))
+	for i, content := range contents {
+		s.Equal(content, string(entries[2*i].Content))
+		s.Equal(content, string(entries[2*i+1].Content))
+		s.Equal([]string{"t"}, entries[2*i+1].Tags)
+	}
+	s.Contains(s.readDay("2018-07-18"), `- 12:11:00 Set \[status:: done] [tags:: t] ^4`+"\n")
+	s.Contains(s.readDay("2018-07-18"), `- 12:11:00 Literal \\^3 ^9`+"\n")
+}
+
+func (s *markdownTestSuite) TestHandEdits() {
+	s.writeDay("2018-07-18", `# Wednesday
+
+Some notes, not an entry.
+
+- 09:15 Standup [project:: team]
+* 17:30:10 Deployed [tags:: ops] [mood:: happy]
+- 09:15 Coffee
+- not an entry
+`)
+	ts := timeFromString(s.T(), "2018-07-18T12:00:00Z")
+	entries, err := s.store.GetRange(s.testBucketName, ts, ts)
+	s.Require().NoError(err)
+	s.Require().Len(entries, 3)
+	s.Equal("Standup", string(entries[0].Content))
+	s.Equal("team", entries[0].Project)
+	s.True(timeFromString(s.T(), "2018-07-18T09:15:00Z").Equal(entries[0].Timestamp))
+	s.Equal("Coffee", string(entries[1].Content))
+	s.NotEqual(entries[0].ID, entries[1].ID)
+	s.Equal("Deployed", string(entries[2].Content))
+	s.Equal([]string{"ops"}, entries[2].Tags)
+	s.Equal(map[string]string{"mood": "happy"}, entries[2].Extra)
+
+	s.NoError(s.store.Update(s.testBucketName, entry{ID: entries[1].ID, Content: []byte("Tea")}))
+	s.NoError(s.store.Delete(s.testBucketName, entries[0].ID))
+	s.Equal(`# Wednesday
+
+Some notes, not an entry.
+
+* 17:30:10 Deployed [tags:: ops] [mood:: happy]
+- 09:15:00 Tea ^2
+- not an entry
+`, s.readDay("2018-07-18"))
+	e, err := s.store.Get(s.testBucketName, entries[1].ID)
+	s.NoError(err)
+	s.Equal("Tea", string(e.Content))
+}
+
+func (s *markdownTestSuite) TestMergeBucket() {
+	s.writeDay("2018-07-18", `# 2018-07-18
+
+Some notes, not an entry.
+
+- 09:15 Coffee
+- 09:15 Tea
+`)
+	dst := randString(10)
+	putEntry(s.T(), s.store, dst, entry{Timestamp: timeFromString(s.T(), "2018-07-18T09:15:00Z"), Content: []byte("Standup")})
+	count, err := s.store.(bucketManager).MergeBucket(s.testBucketName, dst)
+	s.NoError(err)
+	s.Equal(2, count)
+	content, err := os.ReadFile(s.markdownStore().dayPath(dst, "2018-07-18"))
+	s.Require().NoError(err)
+	s.Equal(`# 2018-07-18
+
+- 09:15:00 Standup
+
+Some notes, not an entry.
+
+- 09:15:00 Coffee ^2
+- 09:15:00 Tea ^3
+`, string(content))
+	_, err = os.Stat(s.markdownStore().bucketDir(s.testBucketName))
+	s.ErrorIs(err, os.ErrNotExist)
+}
+
+func (s *markdownTestSuite) TestInvalidBucket() {
+	ts := timeFromString(s.T(), "2018-07-18T12:11:00Z")
+	for _, name := range []string{"", "../escape", ".hidden", metaBucketName} {
+		_, err := s.store.Put(name, entry{Timestamp: ts, Content: []byte("msg1")})
+		s.IsType(DidError{}, err, name)
+	}
+}
+
+func TestMarkdownStore(t *testing.T) {
+	suite.Run(t, &markdownTestSuite{
+		storeTestSuite: storeTestSuite{
+			newStore: func(cfg config) entryStore {
+				return getTestMarkdownStore(t, cfg)
+			},
+			cleanup: cleanupTestMarkdownStore,
+		},
+	})
+}
@@ -0,0 +1,640 @@
+package godid
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// memoryStore is an entryStore keeping everything in memory, laid out like the bolt store: parent buckets holding
+// day buckets holding encoded entries. It's meant for tests and demos, nothing outlives the process.
+type memoryStore struct {
+	mu sync.RWMutex
+	// buckets maps the parent bucket names to their day buckets
+	buckets map[string]map[string]*memoryBucket
+	// trash maps the parent bucket names to their trashed entries, keyed like in the day buckets
+	trash map[string]map[string][]byte
+	// history maps the parent bucket names to the revisions of their entries, oldest first
+	history    map[string]map[string][][]byte
+	lastChange *change
+	// loc is the home location, deciding the day buckets
+	loc *time.Location
+}
+
+// memoryBucket is a day bucket, values being keyed like in bolt
+type memoryBucket struct {
+	sequence uint64
+	values   map[string][]byte
+}
+
+// newMemoryStore creates an empty in-memory entryStore
+func newMemoryStore(cfg config) (*memoryStore, error) {
+	loc, err := cfg.GetLocation()
+	if err != nil {
+		return nil, err
+	}
+	return &memoryStore{
+		buckets: make(map[string]map[string]*memoryBucket),
+		trash:   make(map[string]map[string][]byte),
+		history: make(map[string]map[string][][]byte),
+		loc:     loc,
+	}, nil
+}
+
+func (s *memoryStore) Put(parentBucketName string, e entry) (string, error) {
+	if err := checkBucketPath(parentBucketName); err != nil {
+		return "", err
+	}
+	bucketName, err := getBucketFromEntry(e, s.loc)
+	if err != nil {
+		return "", err
+	}
+	v, err := encodeValue(e)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	parentBucket, ok := s.buckets[parentBucketName]
+	if !ok {
+		parentBucket = make(map[string]*memoryBucket)
+		s.buckets[parentBucketName] = parentBucket
+	}
+	b, ok := parentBucket[bucketName]
+	if !ok {
+		b = &memoryBucket{values: make(map[string][]byte)}
+		parentBucket[bucketName] = b
+	}
+	key := keptKey(e)
+	if _, taken := b.values[string(key)]; key == nil || taken {
+		b.sequence++
+		key = encodeKey(e.Timestamp, b.sequence)
+	} else {
+		b.sequence = max(b.sequence, keySequence(key))
+	}
+	b.values[string(key)] = v
+	return formatID(key), nil
+}
+
+func (s *memoryStore) Get(parentBucketName string, id string) (entry, error) {
+	key, err := parseID(id)
+	if err != nil {
+		return entry{}, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b, err := s.findEntryBucket(parentBucketName, key)
+	if err != nil {
+		return entry{}, err
+	}
+	return decodeEntry(key, b.values[string(key)], s.loc)
+}
+
+func (s *memoryStore) Update(parentBucketName string, e entry) error {
+	key, err := parseID(e.ID)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.findEntryBucket(parentBucketName, key)
+	if err != nil {
+		return err
+	}
+	old, err := decodeEntry(key, b.values[string(key)], s.loc)
+	if err != nil {
+		return err
+	}
+	record, err := encodeRevisionRecord(parentBucketName, key, b.values[string(key)], time.Now())
+	if err != nil {
+		return err
+	}
+	e.Timestamp = old.Timestamp
+	v, err := encodeValue(e)
+	if err != nil {
+		return err
+	}
+	if s.history[parentBucketName] == nil {
+		s.history[parentBucketName] = make(map[string][][]byte)
+	}
+	s.history[parentBucketName][string(key)] = append(s.history[parentBucketName][string(key)], record)
+	b.values[string(key)] = v
+	return nil
+}
+
+func (s *memoryStore) Delete(parentBucketName string, id string) error {
+	key, err := parseID(id)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.findEntryBucket(parentBucketName, key)
+	if err != nil {
+		return err
+	}
+	delete(b.values, string(key))
+	return nil
+}
+
+func (s *memoryStore) GetRange(parentBucketName string, start, end time.Time) ([]entry, error) {
+	r, err := newDayRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+	first, last := r.bucketBounds(s.loc)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make([]entry, 0)
+	for _, bucketName := range sortedKeys(s.buckets[parentBucketName]) {
+		if bucketName < first || bucketName > last {
+			continue
+		}
+		bucketEntries, err := s.getBucketEntries(s.buckets[parentBucketName][bucketName])
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range bucketEntries {
+			if r.contains(e.Timestamp) {
+				result = append(result, e)
+			}
+		}
+	}
+	return result, nil
+}
+
+func (s *memoryStore) GetRangeWithAggregation(parentBucketName string, start, end time.Time, agg aggregationFunction) (any, error) {
+	if agg == nil {
+		return nil, errors.New("aggregation function is nil")
+	}
+	entries, err := s.GetRange(parentBucketName, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return agg(entries)
+}
+
+func (s *memoryStore) Search(q searchQuery, f SearchFilter) ([]searchHit, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	candidates := make([]searchHit, 0)
+	for parentBucketName, parentBucket := range s.buckets {
+		if !f.containsBucket(parentBucketName) {
+			continue
+		}
+		for _, b := range parentBucket {
+			bucketEntries, err := s.getBucketEntries(b)
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range bucketEntries {
+				if f.contains(e.Timestamp) {
+					candidates = append(candidates, searchHit{parentBucketName: parentBucketName, entry: e})
+				}
+			}
+		}
+	}
+	return q.scanSearch(candidates), nil
+}
+
+func (s *memoryStore) ForEach(fn func(parentBucketName string, e entry) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, parentBucketName := range sortedKeys(s.buckets) {
+		for _, bucketName := range sortedKeys(s.buckets[parentBucketName]) {
+			bucketEntries, err := s.getBucketEntries(s.buckets[parentBucketName][bucketName])
+			if err != nil {
+				return err
+			}
+			for _, e := range bucketEntries {
+				if err := fn(parentBucketName, e); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) History(parentBucketName string, id string) ([]revision, error) {
+	key, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make([]revision, 0)
+	for _, v := range s.history[parentBucketName][string(key)] {
+		r, err := decodeRevisionRecord(v)
+		if err != nil {
+			return nil, err
+		}
+		rev, err := r.revision(s.loc)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, rev)
+	}
+	return result, nil
+}
+
+func (s *memoryStore) SetHistory(parentBucketName string, id string, revisions []revision) error {
+	key, err := parseID(id)
+	if err != nil {
+		return err
+	}
+	records := make([][]byte, 0, len(revisions))
+	for _, r := range revisions {
+		v, err := encodeValue(r.entry)
+		if err != nil {
+			return err
+		}
+		record, err := encodeRevisionRecord(parentBucketName, key, v, r.replacedAt)
+		if err != nil {
+			return err
+		}
+		records = append(records, record)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.history[parentBucketName] == nil {
+		s.history[parentBucketName] = make(map[string][][]byte)
+	}
+	s.history[parentBucketName][string(key)] = records
+	return nil
+}
+
+func (s *memoryStore) Trash(parentBucketName string, id string, deletedAt time.Time) error {
+	key, err := parseID(id)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, err := s.findEntryBucket(parentBucketName, key)
+	if err != nil {
+		return err
+	}
+	record, err := encodeTrashRecord(parentBucketName, key, b.values[string(key)], deletedAt)
+	if err != nil {
+		return err
+	}
+	if s.trash[parentBucketName] == nil {
+		s.trash[parentBucketName] = make(map[string][]byte)
+	}
+	s.trash[parentBucketName][string(key)] = record
+	delete(b.values, string(key))
+	return nil
+}
+
+func (s *memoryStore) Untrash(parentBucketName string, id string) (entry, error) {
+	key, err := parseID(id)
+	if err != nil {
+		return entry{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.trash[parentBucketName][string(key)]
+	if !ok {
+		return entry{}, trashedEntryNotFoundError(parentBucketName, id)
+	}
+	if _, err := s.findEntryBucket(parentBucketName, key); err == nil {
+		return entry{}, didErrorf("an entry with id %s already exists in bucket %s", id, parentBucketName)
+	}
+	r, err := decodeTrashRecord(v)
+	if err != nil {
+		return entry{}, err
+	}
+	result, err := decodeEntry(key, r.Value, s.loc)
+	if err != nil {
+		return entry{}, err
+	}
+	bucketName, err := getBucketFromEntry(result, s.loc)
+	if err != nil {
+		return entry{}, err
+	}
+	parentBucket, ok := s.buckets[parentBucketName]
+	if !ok {
+		parentBucket = make(map[string]*memoryBucket)
+		s.buckets[parentBucketName] = parentBucket
+	}
+	b, ok := parentBucket[bucketName]
+	if !ok {
+		b = &memoryBucket{values: make(map[string][]byte)}
+		parentBucket[bucketName] = b
+	}
+	b.sequence = max(b.sequence, keySequence(key))
+	b.values[string(key)] = r.Value
+	delete(s.trash[parentBucketName], string(key))
+	return result, nil
+}
+
+func (s *memoryStore) ListTrash() ([]trashedEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make([]trashedEntry, 0)
+	for _, trashParent := range s.trash {
+		for _, v := range trashParent {
+			r, err := decodeTrashRecord(v)
+			if err != nil {
+				return nil, err
+			}
+			t, err := r.trashed(s.loc)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, t)
+		}
+	}
+	sortTrash(result)
+	return result, nil
+}
+
+func (s *memoryStore) EmptyTrash() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, trashParent := range s.trash {
+		count += len(trashParent)
+	}
+	s.trash = make(map[string]map[string][]byte)
+	return count, nil
+}
+
+func (s *memoryStore) SetLastChange(c *change) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastChange = c
+	return nil
+}
+
+func (s *memoryStore) LastChange() (*change, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastChange, nil
+}
+
+func (s *memoryStore) Buckets() ([]BucketStats, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make([]BucketStats, 0)
+	for _, parentBucketName := range sortedKeys(s.buckets) {
+		stats := BucketStats{Name: parentBucketName}
+		for _, b := range s.buckets[parentBucketName] {
+			bucketEntries, err := s.getBucketEntries(b)
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range bucketEntries {
+				stats.addEntry(e.Timestamp.In(s.loc))
+			}
+			if len(bucketEntries) > 0 {
+				stats.Days++
+			}
+		}
+		if stats.Entries > 0 {
+			result = append(result, stats)
+		}
+	}
+	return result, nil
+}
+
+func (s *memoryStore) ChildBuckets(parentBucketName string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make([]string, 0)
+	for _, name := range sortedKeys(s.buckets) {
+		if name != parentBucketName && isInBucket(name, parentBucketName) {
+			result = append(result, name)
+		}
+	}
+	return result, nil
+}
+
+// RenameBucket moves the parent bucket along with the ones nested in it
+func (s *memoryStore) RenameBucket(oldName, newName string) error {
+	if err := checkBucketPath(newName); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, parentBucket := range s.buckets {
+		if !isInBucket(name, newName) {
+			continue
+		}
+		for _, b := range parentBucket {
+			if len(b.values) > 0 {
+				return bucketExistsError(newName)
+			}
+		}
+	}
+	found := false
+	for _, name := range sortedKeys(s.buckets) {
+		if !isInBucket(name, oldName) {
+			continue
+		}
+		found = true
+		if err := s.renameParentBucket(name, rebaseBucket(name, oldName, newName)); err != nil {
+			return err
+		}
+	}
+	if !found {
+		return bucketNotFoundError(oldName)
+	}
+	return nil
+}
+
+// renameParentBucket moves a single parent bucket, its trash and its history
+func (s *memoryStore) renameParentBucket(oldName, newName string) error {
+	for key, v := range s.trash[oldName] {
+		record, err := renameRecord(v, newName)
+		if err != nil {
+			return err
+		}
+		if s.trash[newName] == nil {
+			s.trash[newName] = make(map[string][]byte)
+		}
+		s.trash[newName][key] = record
+	}
+	for key, revisions := range s.history[oldName] {
+		for _, v := range revisions {
+			record, err := renameRecord(v, newName)
+			if err != nil {
+				return err
+			}
+			if s.history[newName] == nil {
+				s.history[newName] = make(map[string][][]byte)
+			}
+			s.history[newName][key] = append(s.history[newName][key], record)
+		}
+	}
+	s.buckets[newName] = s.buckets[oldName]
+	delete(s.buckets, oldName)
+	delete(s.trash, oldName)
+	delete(s.history, oldName)
+	return nil
+}
+
+// MergeBucket moves the parent bucket along with the ones nested in it into another that may hold entries already.
+// The entries whose key is taken there, by an entry or a trashed one, get the next sequence number of their day, like
+// in bolt.
+func (s *memoryStore) MergeBucket(src, dst string) (int, error) {
+	if err := checkBucketPath(dst); err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	found := false
+	for name, parentBucket := range s.buckets {
+		for _, b := range parentBucket {
+			found = found || (isInBucket(name, src) && len(b.values) > 0)
+		}
+	}
+	if !found {
+		return 0, bucketNotFoundError(src)
+	}
+	moved := 0
+	for _, name := range sortedKeys(s.buckets) {
+		if !isInBucket(name, src) {
+			continue
+		}
+		count, err := s.mergeParentBucket(name, rebaseBucket(name, src, dst))
+		if err != nil {
+			return moved, err
+		}
+		moved += count
+	}
+	return moved, nil
+}
+
+// mergeParentBucket moves a single parent bucket, its trash and its history into another, returning how many entries
+// were moved
+func (s *memoryStore) mergeParentBucket(oldName, newName string) (int, error) {
+	if s.buckets[newName] == nil {
+		s.buckets[newName] = make(map[string]*memoryBucket)
+	}
+	newParent := s.buckets[newName]
+	dayBucket := func(day string) *memoryBucket {
+		if newParent[day] == nil {
+			newParent[day] = &memoryBucket{values: make(map[string][]byte)}
+		}
+		return newParent[day]
+	}
+	// rekeyed maps the keys taken in the new parent bucket to the ones given instead
+	rekeyed := make(map[string]string)
+	moved := 0
+	for _, day := range sortedKeys(s.buckets[oldName]) {
+		b, dst := s.buckets[oldName][day], dayBucket(day)
+		dst.sequence = max(dst.sequence, b.sequence)
+		for _, k := range sortedKeys(b.values) {
+			key := k
+			_, taken := dst.values[k]
+			if _, trashed := s.trash[newName][k]; taken || trashed {
+				var err error
+				if key, err = dst.nextKey(k); err != nil {
+					return moved, err
+				}
+				rekeyed[k] = key
+			}
+			dst.values[key] = b.values[k]
+			moved++
+		}
+	}
+	for _, k := range sortedKeys(s.trash[oldName]) {
+		key, ok := rekeyed[k]
+		if !ok {
+			key = k
+			_, trashed := s.trash[newName][k]
+			if _, err := s.findEntryBucket(newName, []byte(k)); err == nil || trashed {
+				t, err := decodeKey([]byte(k))
+				if err != nil {
+					return moved, err
+				}
+				if key, err = dayBucket(t.In(s.loc).Format(dayFormat)).nextKey(k); err != nil {
+					return moved, err
+				}
+				rekeyed[k] = key
+			}
+		}
+		id := ""
+		if key != k {
+			id = formatID([]byte(key))
+		}
+		record, err := rekeyRecord(s.trash[oldName][k], newName, id)
+		if err != nil {
+			return moved, err
+		}
+		if s.trash[newName] == nil {
+			s.trash[newName] = make(map[string][]byte)
+		}
+		s.trash[newName][key] = record
+	}
+	for k, revisions := range s.history[oldName] {
+		key, id := k, ""
+		if newKey, ok := rekeyed[k]; ok {
+			key, id = newKey, formatID([]byte(newKey))
+		}
+		for _, v := range revisions {
+			record, err := rekeyRecord(v, newName, id)
+			if err != nil {
+				return moved, err
+			}
+			if s.history[newName] == nil {
+				s.history[newName] = make(map[string][][]byte)
+			}
+			s.history[newName][key] = append(s.history[newName][key], record)
+		}
+	}
+	delete(s.buckets, oldName)
+	delete(s.trash, oldName)
+	delete(s.history, oldName)
+	return moved, nil
+}
+
+// nextKey returns a new key for the entry logged at the time of k, taking the next sequence number of the day bucket
+func (b *memoryBucket) nextKey(k string) (string, error) {
+	t, err := decodeKey([]byte(k))
+	if err != nil {
+		return "", err
+	}
+	b.sequence++
+	return string(encodeKey(t, b.sequence)), nil
+}
+
+func (s *memoryStore) Close() error {
+	return nil
+}
+
+// getBucketEntries returns all the entries of a day bucket sorted by time
+func (s *memoryStore) getBucketEntries(b *memoryBucket) ([]entry, error) {
+	result := make([]entry, 0, len(b.values))
+	for _, k := range sortedKeys(b.values) {
+		e, err := decodeEntry([]byte(k), b.values[k], s.loc)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+// findEntryBucket returns the day bucket holding key, looking into every day bucket of the parent like the bolt store
+// does for misfiled entries
+func (s *memoryStore) findEntryBucket(parentBucketName string, key []byte) (*memoryBucket, error) {
+	for _, b := range s.buckets[parentBucketName] {
+		if _, ok := b.values[string(key)]; ok {
+			return b, nil
+		}
+	}
+	return nil, entryNotFoundError(parentBucketName, formatID(key))
+}
+
+// sortedKeys returns the keys of m in byte order, the order bolt iterates keys in
+func sortedKeys[V any](m map[string]V) []string {
+	result := lo.Keys(m)
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,90 @@
+package godid
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type memoryTestSuite struct {
+	storeTestSuite
+}
+
+func (s *memoryTestSuite) TestReservedBuckets() {
+	_, err := s.store.Put(metaBucketName, entry{Timestamp: timeFromString(s.T(), "2018-07-18T12:11:00Z"), Content: []byte("msg1")})
+	s.IsType(DidError{}, err)
+}
+
+func TestMemoryStore(t *testing.T) {
+	suite.Run(t, &memoryTestSuite{
+		storeTestSuite: storeTestSuite{
+			newStore: func(cfg config) entryStore {
+				return getTestMemoryStore(t, cfg)
+			},
+			cleanup: func() {},
+		},
+	})
+}
+
+// TestMemoryStoreMatchesBolt runs the same random operations against both stores, expecting the same results
+func TestMemoryStoreMatchesBolt(t *testing.T) {
+	defer cleanupTestBoltStore()
+	cfg := config{HomeTimezone: "Europe/Bucharest"}
+	stores := []entryStore{getTestBoltStore(t, cfg), getTestMemoryStore(t, cfg)}
+	defer stores[0].Close()
+	zones := []*time.Location{time.UTC, time.FixedZone("PDT", -7*60*60), time.FixedZone("JST", 9*60*60)}
+	buckets := []string{"root", "work"}
+	start := timeFromString(t, "2018-07-16T00:00:00Z")
+	r := rand.New(rand.NewSource(42))
+
+	getAll := func(s entryStore, bucket string) []entry {
+		entries, err := s.GetRange(bucket, start.AddDate(0, 0, -1), start.AddDate(0, 0, 15))
+		require.NoError(t, err)
+		return entries
+	}
+	for i := 0; i < 300; i++ {
+		bucket := buckets[r.Intn(len(buckets))]
+		existing := getAll(stores[0], bucket)
+		switch op := r.Intn(10); {
+		case op < 6 || len(existing) == 0:
+			// a few minutes apart over two weeks, so some timestamps collide
+			ts := start.Add(time.Duration(r.Intn(14*24*6)) * 10 * time.Minute).In(zones[r.Intn(len(zones))])
+			e := entry{Timestamp: ts, Content: []byte(randString(8)), Metadata: Metadata{Tags: []string{randString(3)}}}
+			for _, s := range stores {
+				putEntry(t, s, bucket, e)
+			}
+		case op < 8:
+			e := existing[r.Intn(len(existing))]
+			e.Content = []byte(randString(8))
+			for _, s := range stores {
+				require.NoError(t, s.Update(bucket, e))
+			}
+		default:
+			id := existing[r.Intn(len(existing))].ID
+			for _, s := range stores {
+				require.NoError(t, s.Delete(bucket, id))
+			}
+		}
+	}
+
+	for _, bucket := range append(buckets, "missing") {
+		for i := 0; i < 20; i++ {
+			from := start.Add(time.Duration(r.Intn(14*24)) * time.Hour).In(zones[r.Intn(len(zones))])
+			to := from.Add(time.Duration(r.Intn(5*24)) * time.Hour)
+			expected, err := stores[0].GetRange(bucket, from, to)
+			require.NoError(t, err)
+			actual, err := stores[1].GetRange(bucket, from, to)
+			require.NoError(t, err)
+			require.Equal(t, len(expected), len(actual))
+			for j := range expected {
+				require.Equal(t, expected[j].ID, actual[j].ID)
+				require.Equal(t, expected[j].Timestamp.String(), actual[j].Timestamp.String())
+				require.Equal(t, expected[j].Content, actual[j].Content)
+				require.Equal(t, expected[j].Metadata, actual[j].Metadata)
+			}
+		}
+	}
+}
@@ -0,0 +1,328 @@
+package godid
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// reservedBucketPrefix starts the names of the top level buckets used by the store itself
+	reservedBucketPrefix = "_"
+	metaBucketName       = reservedBucketPrefix + "meta"
+	schemaVersionKey     = "schema_version"
+)
+
+var (
+	errDryRun = errors.New("dry run")
+	// errReadOnly is returned when a read only store would have to be changed
+	errReadOnly = errors.New("the store is open read only")
+)
+
+// MigrationResult describes a migration applied to the store
+type MigrationResult struct {
+	Version     int
+	Description string
+	// Changes is the number of entries touched by the migration
+	Changes int
+}
+
+// boltMigration upgrades the store to the next schema version, returning the number of entries it changed
+type boltMigration struct {
+	description string
+	apply       func(tx *bolt.Tx) (int, error)
+}
+
+// boltMigrations are applied in order. The schema version of a store is the number of migrations applied to it,
+// stores created before versioning was introduced are at version 0.
+var boltMigrations = []boltMigration{
+	{
+		description: "rewrite second precision keys to collision-proof keys",
+		apply:       migrateLegacyKeys,
+	},
+	{
+		description: "build the full-text search index",
+		apply:       migrateSearchIndex,
+	},
+	{
+		description: "nest the buckets with a path in their name under their parent",
+		apply:       migrateBucketPaths,
+	},
+	{
+		description: "rename the buckets whose names start with " + reservedBucketPrefix + ", now kept for the store",
+		apply:       migrateReservedBucketNames,
+	},
+}
+
+// Migrate brings the store to the latest schema version. With dryRun the changes are rolled back, only being reported.
+func (s *boltStore) Migrate(dryRun bool) ([]MigrationResult, error) {
+	var version int
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		version, err = getSchemaVersion(tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	results := make([]MigrationResult, 0)
+	if version == len(boltMigrations) {
+		return results, nil
+	}
+	if s.db.IsReadOnly() {
+		return nil, errReadOnly
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		version, err := getSchemaVersion(tx)
+		if err != nil {
+			return err
+		}
+		for ; version < len(boltMigrations); version++ {
+			m := boltMigrations[version]
+			changes, err := m.apply(tx)
+			if err != nil {
+				return fmt.Errorf("migration to version %d failed: %w", version+1, err)
+			}
+			results = append(results, MigrationResult{
+				Version:     version + 1,
+				Description: m.description,
+				Changes:     changes,
+			})
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return setSchemaVersion(tx, version)
+	})
+	if err != nil && err != errDryRun {
+		return nil, err
+	}
+	return results, nil
+}
+
+func getSchemaVersion(tx *bolt.Tx) (int, error) {
+	meta := tx.Bucket([]byte(metaBucketName))
+	if meta == nil {
+		return 0, nil
+	}
+	v := meta.Get([]byte(schemaVersionKey))
+	if v == nil {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(string(v))
+	if err != nil {
+		return 0, fmt.Errorf("invalid schema version %q: %w", v, err)
+	}
+	if version > len(boltMigrations) {
+		return 0, didErrorf("the store has schema version %d, newer than the supported %d, please upgrade did", version, len(boltMigrations))
+	}
+	return version, nil
+}
+
+func setSchemaVersion(tx *bolt.Tx, version int) error {
+	meta, err := tx.CreateBucketIfNotExists([]byte(metaBucketName))
+	if err != nil {
+		return err
+	}
+	return meta.Put([]byte(schemaVersionKey), []byte(strconv.Itoa(version)))
+}
+
+func isReservedBucket(name []byte) bool {
+	return len(name) > 0 && string(name[:1]) == reservedBucketPrefix
+}
+
+// isStoreBucket tells whether a top level bucket is one the store keeps its own data in
+func isStoreBucket(name []byte) bool {
+	switch string(name) {
+	case metaBucketName, indexBucketName, trashBucketName, historyBucketName, quarantineBucketName:
+		return true
+	}
+	return false
+}
+
+// migrateLegacyKeys rewrites the entries stored under RFC3339 keys to the collision-proof key format, keeping the
+// offset of the old key as the zone of the entry. The keys that aren't RFC3339 are left for did fsck.
+func migrateLegacyKeys(tx *bolt.Tx) (int, error) {
+	changes := 0
+	err := forEachDayBucket(tx, func(_ []byte, b *bolt.Bucket) error {
+		legacy := make(map[string][]byte)
+		err := b.ForEach(func(k, v []byte) error {
+			if !isKey(k) {
+				legacy[string(k)] = append([]byte(nil), v...)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		keys := make([]string, 0, len(legacy))
+		for k := range legacy {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			timestamp, err := time.Parse(timeFormat, k)
+			if err != nil {
+				// did fsck quarantines the keys that can't be read
+				continue
+			}
+			seq, err := b.NextSequence()
+			if err != nil {
+				return err
+			}
+			v, err := encodeValue(entry{Timestamp: timestamp, Content: legacy[k]})
+			if err != nil {
+				return err
+			}
+			if err := b.Put(encodeKey(timestamp, seq), v); err != nil {
+				return err
+			}
+			if err := b.Delete([]byte(k)); err != nil {
+				return err
+			}
+			changes++
+		}
+		return nil
+	})
+	return changes, err
+}
+
+// migrateBucketPaths moves the top level buckets named after a path, e.g. work/payments, under the buckets of their
+// parents. Their entries keep their keys, the search index, trash and history being keyed by the path already.
+func migrateBucketPaths(tx *bolt.Tx) (int, error) {
+	names := make([]string, 0)
+	err := tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+		if !isReservedBucket(name) && bytes.Contains(name, []byte(bucketPathSeparator)) {
+			names = append(names, string(name))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	changes := 0
+	for _, name := range names {
+		if checkBucketPath(name) != nil {
+			continue
+		}
+		src := tx.Bucket([]byte(name))
+		dst, err := createParentBucket(tx, name)
+		if err != nil {
+			return changes, err
+		}
+		err = src.ForEach(func(k, v []byte) error {
+			if v != nil {
+				return dst.Put(k, v)
+			}
+			b := src.Bucket(k)
+			target, err := dst.CreateBucketIfNotExists(k)
+			if err != nil {
+				return err
+			}
+			if err := target.SetSequence(max(target.Sequence(), b.Sequence())); err != nil {
+				return err
+			}
+			return b.ForEach(func(key, v []byte) error {
+				if v == nil {
+					return nil
+				}
+				changes++
+				return target.Put(key, v)
+			})
+		})
+		if err != nil {
+			return changes, err
+		}
+		if err := tx.DeleteBucket([]byte(name)); err != nil {
+			return changes, err
+		}
+	}
+	return changes, nil
+}
+
+// migrateReservedBucketNames renames the buckets created before the names starting with the reserved prefix were kept
+// for the store, their entries being skipped by everything else. The prefix is dropped, a number being appended when
+// the name is taken, e.g. _work becomes work or work-2. The earlier migrations skipped those buckets, so they're run
+// again.
+func migrateReservedBucketNames(tx *bolt.Tx) (int, error) {
+	names := make([]string, 0)
+	err := tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+		if isReservedBucket(name) && !isStoreBucket(name) {
+			names = append(names, string(name))
+		}
+		return nil
+	})
+	if err != nil || len(names) == 0 {
+		return 0, err
+	}
+	changes := 0
+	for _, name := range names {
+		newName := freeBucketName(tx, name)
+		src := tx.Bucket([]byte(name))
+		dst, err := tx.CreateBucket([]byte(newName))
+		if err != nil {
+			return changes, err
+		}
+		copied, err := copyBoltBucket(dst, src)
+		if err != nil {
+			return changes, err
+		}
+		if err := tx.DeleteBucket([]byte(name)); err != nil {
+			return changes, err
+		}
+		getLogger().WithFields(logrus.Fields{
+			"component": "bolt",
+			"method":    "migrateReservedBucketNames",
+		}).Warnf("renamed the bucket %s to %s, names starting with %s being reserved", name, newName, reservedBucketPrefix)
+		changes += copied
+	}
+	for _, m := range []func(*bolt.Tx) (int, error){migrateLegacyKeys, migrateBucketPaths, migrateSearchIndex} {
+		if _, err := m(tx); err != nil {
+			return changes, err
+		}
+	}
+	return changes, nil
+}
+
+// freeBucketName returns the name of a reserved bucket without the reserved prefix, made unique among the top level
+// buckets
+func freeBucketName(tx *bolt.Tx, name string) string {
+	base := strings.TrimLeft(name, reservedBucketPrefix)
+	if base == "" || strings.HasPrefix(base, bucketPathSeparator) {
+		base = "bucket" + base
+	}
+	newName := base
+	for i := 2; tx.Bucket([]byte(newName)) != nil; i++ {
+		newName = fmt.Sprintf("%s-%d", base, i)
+	}
+	return newName
+}
+
+// copyBoltBucket copies the keys, nested buckets and sequences of src to dst, returning the number of values copied
+func copyBoltBucket(dst, src *bolt.Bucket) (int, error) {
+	if err := dst.SetSequence(max(dst.Sequence(), src.Sequence())); err != nil {
+		return 0, err
+	}
+	copied := 0
+	err := src.ForEach(func(k, v []byte) error {
+		if v != nil {
+			copied++
+			return dst.Put(k, v)
+		}
+		b, err := dst.CreateBucketIfNotExists(k)
+		if err != nil {
+			return err
+		}
+		n, err := copyBoltBucket(b, src.Bucket(k))
+		copied += n
+		return err
+	})
+	return copied, err
+}
@@ -0,0 +1,277 @@
+package godid
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// openFixtureStore opens a copy of one of the stores in testdata
+func openFixtureStore(t *testing.T, fixture string) *boltStore {
+	content, err := os.ReadFile(filepath.Join("testdata", fixture))
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), fixture)
+	require.NoError(t, os.WriteFile(path, content, 0600))
+	s, err := newBoltStore(config{StorePath: path, HomeTimezone: "UTC"}, false)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		s.Close()
+	})
+	return s
+}
+
+func requireFixtureEntries(t *testing.T, s *boltStore) {
+	start := timeFromString(t, "2018-07-17T00:00:00Z")
+	end := timeFromString(t, "2018-07-20T00:00:00Z")
+	entries, err := s.GetRange("root", start, end)
+	require.NoError(t, err)
+	requireEntriesEqual(t, []entry{
+		{Timestamp: timeFromString(t, "2018-07-17T09:15:00Z"), Content: []byte("Reviewed the billing migration")},
+		{Timestamp: timeFromString(t, "2018-07-17T14:02:31Z"), Content: []byte("Paired on the release script")},
+		{Timestamp: timeFromString(t, "2018-07-18T07:00:00Z"), Content: []byte("Standup notes")},
+		{Timestamp: timeFromString(t, "2018-07-20T16:45:12Z"), Content: []byte("Deployed v1.2.0")},
+	}, entries)
+	_, offset := entries[2].Timestamp.Zone()
+	assert.Equal(t, 3*60*60, offset)
+
+	entries, err = s.GetRange("personal", start, end)
+	require.NoError(t, err)
+	requireEntriesEqual(t, []entry{
+		{Timestamp: timeFromString(t, "2018-07-18T19:30:00Z"), Content: []byte("Fixed the bike")},
+	}, entries)
+}
+
+func getTestSchemaVersion(t *testing.T, s *boltStore) int {
+	var version int
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		version, err = getSchemaVersion(tx)
+		return err
+	})
+	require.NoError(t, err)
+	return version
+}
+
+func TestMigrateLegacyStore(t *testing.T) {
+	s := openFixtureStore(t, "legacy.db")
+	require.Equal(t, 0, getTestSchemaVersion(t, s))
+	expected := []MigrationResult{
+		{Version: 1, Description: boltMigrations[0].description, Changes: 5},
+		{Version: 2, Description: boltMigrations[1].description, Changes: 5},
+		{Version: 3, Description: boltMigrations[2].description, Changes: 0},
+		{Version: 4, Description: boltMigrations[3].description, Changes: 0},
+	}
+
+	results, err := s.Migrate(true)
+	require.NoError(t, err)
+	assert.Equal(t, expected, results)
+	assert.Equal(t, 0, getTestSchemaVersion(t, s))
+	s.db.View(func(tx *bolt.Tx) error {
+		return forEachDayBucket(tx, func(_ []byte, b *bolt.Bucket) error {
+			return b.ForEach(func(k, _ []byte) error {
+				assert.False(t, isKey(k), "dry run must not rewrite %s", k)
+				return nil
+			})
+		})
+	})
+
+	results, err = s.Migrate(false)
+	require.NoError(t, err)
+	assert.Equal(t, expected, results)
+	assert.Equal(t, len(boltMigrations), getTestSchemaVersion(t, s))
+	requireFixtureEntries(t, s)
+
+	results, err = s.Migrate(false)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+	requireFixtureEntries(t, s)
+}
+
+func TestMigrateUnversionedStore(t *testing.T) {
+	s := openFixtureStore(t, "unversioned.db")
+	require.Equal(t, 0, getTestSchemaVersion(t, s))
+	requireFixtureEntries(t, s)
+
+	results, err := s.Migrate(false)
+	require.NoError(t, err)
+	assert.Equal(t, []MigrationResult{
+		{Version: 1, Description: boltMigrations[0].description, Changes: 0},
+		{Version: 2, Description: boltMigrations[1].description, Changes: 5},
+		{Version: 3, Description: boltMigrations[2].description, Changes: 0},
+		{Version: 4, Description: boltMigrations[3].description, Changes: 0},
+	}, results)
+	assert.Equal(t, len(boltMigrations), getTestSchemaVersion(t, s))
+	requireFixtureEntries(t, s)
+}
+
+func TestMigrateCorruptKey(t *testing.T) {
+	s := openFixtureStore(t, "corrupt_key.db")
+	results, err := s.Migrate(false)
+	require.NoError(t, err, "a key that can't be read mustn't fail the migration")
+	require.Len(t, results, len(boltMigrations))
+	assert.Equal(t, 5, results[0].Changes)
+	requireFixtureEntries(t, s)
+
+	problems, err := s.Check(true)
+	require.NoError(t, err)
+	assert.Equal(t, []CheckProblem{
+		{Bucket: "root", Day: "2018-07-17", Key: `"garbage"`, Description: "invalid key", Repaired: true},
+	}, problems)
+	requireFixtureEntries(t, s)
+}
+
+func TestCheckBeforeMigrating(t *testing.T) {
+	s := openFixtureStore(t, "corrupt_key.db")
+	problems, err := s.Check(true)
+	require.NoError(t, err)
+	assert.Equal(t, []CheckProblem{
+		{Bucket: "root", Day: "2018-07-17", Key: `"garbage"`, Description: "invalid key", Repaired: true},
+	}, problems, "the legacy keys aren't damaged")
+	assert.Equal(t, 0, getTestSchemaVersion(t, s))
+
+	_, err = s.Migrate(false)
+	require.NoError(t, err)
+	requireFixtureEntries(t, s)
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return setSchemaVersion(tx, len(boltMigrations)+1)
+	})
+	require.NoError(t, err)
+	_, err = s.Check(false)
+	assert.IsType(t, DidError{}, err, "a newer store mustn't be checked")
+}
+
+func TestMigrateSearchIndex(t *testing.T) {
+	s := openFixtureStore(t, "legacy.db")
+	_, err := s.Migrate(false)
+	require.NoError(t, err)
+
+	q, err := parseSearchQuery("billing migration")
+	require.NoError(t, err)
+	hits, err := s.Search(q, SearchFilter{})
+	require.NoError(t, err)
+	require.Len(t, hits, 1)
+	assert.Equal(t, "root", hits[0].parentBucketName)
+	assert.Equal(t, "Reviewed the billing migration", string(hits[0].entry.Content))
+
+	q, err = parseSearchQuery("bike")
+	require.NoError(t, err)
+	hits, err = s.Search(q, SearchFilter{})
+	require.NoError(t, err)
+	require.Len(t, hits, 1)
+	assert.Equal(t, "personal", hits[0].parentBucketName)
+}
+
+func TestMigrateBucketPaths(t *testing.T) {
+	s := openFixtureStore(t, "unversioned.db")
+	ts := timeFromString(t, "2018-07-18T10:00:00Z")
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucket([]byte("work/payments"))
+		if err != nil {
+			return err
+		}
+		day, err := b.CreateBucket([]byte("2018-07-18"))
+		if err != nil {
+			return err
+		}
+		v, err := encodeValue(entry{Timestamp: ts, Content: []byte("Refunded the order")})
+		if err != nil {
+			return err
+		}
+		return day.Put(encodeKey(ts, 1), v)
+	})
+	require.NoError(t, err)
+
+	results, err := s.Migrate(false)
+	require.NoError(t, err)
+	require.Len(t, results, len(boltMigrations))
+	assert.Equal(t, 1, results[2].Changes)
+	requireFixtureEntries(t, s)
+	entries, err := s.GetRange("work/payments", ts, ts)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, formatID(encodeKey(ts, 1)), entries[0].ID)
+	s.db.View(func(tx *bolt.Tx) error {
+		assert.Nil(t, tx.Bucket([]byte("work/payments")))
+		assert.NotNil(t, tx.Bucket([]byte("work")).Bucket([]byte("/payments")))
+		return nil
+	})
+}
+
+func TestMigrateReservedBucketNames(t *testing.T) {
+	s := openFixtureStore(t, "unversioned.db")
+	ts := timeFromString(t, "2018-07-18T10:00:00Z")
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		for i, name := range []string{"_work", "_root"} {
+			b, err := tx.CreateBucket([]byte(name))
+			if err != nil {
+				return err
+			}
+			day, err := b.CreateBucket([]byte("2018-07-18"))
+			if err != nil {
+				return err
+			}
+			if err := day.Put([]byte(ts.Format(timeFormat)), []byte(fmt.Sprintf("Written to %s", name))); err != nil {
+				return err
+			}
+			if i == 0 {
+				continue
+			}
+			if err := day.SetSequence(7); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	results, err := s.Migrate(false)
+	require.NoError(t, err)
+	require.Len(t, results, len(boltMigrations))
+	assert.Equal(t, 2, results[3].Changes)
+	requireFixtureEntries(t, s)
+	for bucket, content := range map[string]string{"work": "Written to _work", "root-2": "Written to _root"} {
+		entries, err := s.GetRange(bucket, ts, ts)
+		require.NoError(t, err)
+		require.Len(t, entries, 1, bucket)
+		assert.Equal(t, content, string(entries[0].Content))
+	}
+	s.db.View(func(tx *bolt.Tx) error {
+		assert.Nil(t, tx.Bucket([]byte("_work")))
+		assert.Nil(t, tx.Bucket([]byte("_root")))
+		assert.Equal(t, uint64(8), tx.Bucket([]byte("root-2")).Bucket([]byte("2018-07-18")).Sequence())
+		return nil
+	})
+
+	q, err := parseSearchQuery("_work")
+	require.NoError(t, err)
+	hits, err := s.Search(q, SearchFilter{})
+	require.NoError(t, err)
+	require.Len(t, hits, 1)
+	assert.Equal(t, "work", hits[0].parentBucketName)
+}
+
+func TestMigrateNewerStore(t *testing.T) {
+	s := openFixtureStore(t, "unversioned.db")
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return setSchemaVersion(tx, len(boltMigrations)+1)
+	})
+	require.NoError(t, err)
+	_, err = s.Migrate(false)
+	assert.IsType(t, DidError{}, err)
+}
+
+func TestReservedBuckets(t *testing.T) {
+	s := openFixtureStore(t, "unversioned.db")
+	_, err := s.Migrate(false)
+	require.NoError(t, err)
+	_, err = s.Put(metaBucketName, entry{Timestamp: time.Now(), Content: []byte("msg1")})
+	assert.IsType(t, DidError{}, err)
+	requireFixtureEntries(t, s)
+}
@@ -21,15 +21,27 @@ var _ entryStore = &entryStoreMock{}
 //			CloseFunc: func() error {
 //				panic("mock out the Close method")
 //			},
+//			DeleteFunc: func(parentBucketName string, id string) error {
+//				panic("mock out the Delete method")
+//			},
+//			ForEachFunc: func(fn func(parentBucketName string, e entry) error) error {
+//				panic("mock out the ForEach method")
+//			},
+//			GetFunc: func(parentBucketName string, id string) (entry, error) {
+//				panic("mock out the Get method")
+//			},
 //			GetRangeFunc: func(parentBucketName string, start time.Time, end time.Time) ([]entry, error) {
 //				panic("mock out the GetRange method")
 //			},
 //			GetRangeWithAggregationFunc: func(parentBucketName string, start time.Time, end time.Time, agg aggregationFunction) (any, error) {
 //				panic("mock out the GetRangeWithAggregation method")
 //			},
-//			PutFunc: func(s string, entryMoqParam entry) error {
+//			PutFunc: func(parentBucketName string, e entry) (string, error) {
 //				panic("mock out the Put method")
 //			},
+//			UpdateFunc: func(parentBucketName string, e entry) error {
+//				panic("mock out the Update method")
+//			},
 //		}
 //
 //		// use mockedentryStore in code that requires entryStore
@@ -40,6 +52,15 @@ type entryStoreMock struct {
 	// CloseFunc mocks the Close method.
 	CloseFunc func() error
 
+	// DeleteFunc mocks the Delete method.
+	DeleteFunc func(parentBucketName string, id string) error
+
+	// ForEachFunc mocks the ForEach method.
+	ForEachFunc func(fn func(parentBucketName string, e entry) error) error
+
+	// GetFunc mocks the Get method.
+	GetFunc func(parentBucketName string, id string) (entry, error)
+
 	// GetRangeFunc mocks the GetRange method.
 	GetRangeFunc func(parentBucketName string, start time.Time, end time.Time) ([]entry, error)
 
@@ -47,13 +68,35 @@ type entryStoreMock struct {
 	GetRangeWithAggregationFunc func(parentBucketName string, start time.Time, end time.Time, agg aggregationFunction) (any, error)
 
 	// PutFunc mocks the Put method.
-	PutFunc func(s string, entryMoqParam entry) error
+	PutFunc func(parentBucketName string, e entry) (string, error)
+
+	// UpdateFunc mocks the Update method.
+	UpdateFunc func(parentBucketName string, e entry) error
 
 	// calls tracks calls to the methods.
 	calls struct {
 		// Close holds details about calls to the Close method.
 		Close []struct {
 		}
+		// Delete holds details about calls to the Delete method.
+		Delete []struct {
+			// ParentBucketName is the parentBucketName argument value.
+			ParentBucketName string
+			// ID is the id argument value.
+			ID string
+		}
+		// ForEach holds details about calls to the ForEach method.
+		ForEach []struct {
+			// Fn is the fn argument value.
+			Fn func(parentBucketName string, e entry) error
+		}
+		// Get holds details about calls to the Get method.
+		Get []struct {
+			// ParentBucketName is the parentBucketName argument value.
+			ParentBucketName string
+			// ID is the id argument value.
+			ID string
+		}
 		// GetRange holds details about calls to the GetRange method.
 		GetRange []struct {
 			// ParentBucketName is the parentBucketName argument value.
@@ -76,16 +119,27 @@ type entryStoreMock struct {
 		}
 		// Put holds details about calls to the Put method.
 		Put []struct {
-			// S is the s argument value.
-			S string
-			// EntryMoqParam is the entryMoqParam argument value.
-			EntryMoqParam entry
+			// ParentBucketName is the parentBucketName argument value.
+			ParentBucketName string
+			// E is the e argument value.
+			E entry
+		}
+		// Update holds details about calls to the Update method.
+		Update []struct {
+			// ParentBucketName is the parentBucketName argument value.
+			ParentBucketName string
+			// E is the e argument value.
+			E entry
 		}
 	}
 	lockClose                   sync.RWMutex
+	lockDelete                  sync.RWMutex
+	lockForEach                 sync.RWMutex
+	lockGet                     sync.RWMutex
 	lockGetRange                sync.RWMutex
 	lockGetRangeWithAggregation sync.RWMutex
 	lockPut                     sync.RWMutex
+	lockUpdate                  sync.RWMutex
 }
 
 // Close calls CloseFunc.
@@ -115,6 +169,110 @@ func (mock *entryStoreMock) CloseCalls() []struct {
 	return calls
 }
 
+// Delete calls DeleteFunc.
+func (mock *entryStoreMock) Delete(parentBucketName string, id string) error {
+	if mock.DeleteFunc == nil {
+		panic("entryStoreMock.DeleteFunc: method is nil but entryStore.Delete was just called")
+	}
+	callInfo := struct {
+		ParentBucketName string
+		ID               string
+	}{
+		ParentBucketName: parentBucketName,
+		ID:               id,
+	}
+	mock.lockDelete.Lock()
+	mock.calls.Delete = append(mock.calls.Delete, callInfo)
+	mock.lockDelete.Unlock()
+	return mock.DeleteFunc(parentBucketName, id)
+}
+
+// DeleteCalls gets all the calls that were made to Delete.
+// Check the length with:
+//
+//	len(mockedentryStore.DeleteCalls())
+func (mock *entryStoreMock) DeleteCalls() []struct {
+	ParentBucketName string
+	ID               string
+} {
+	var calls []struct {
+		ParentBucketName string
+		ID               string
+	}
+	mock.lockDelete.RLock()
+	calls = mock.calls.Delete
+	mock.lockDelete.RUnlock()
+	return calls
+}
+
+// ForEach calls ForEachFunc.
+func (mock *entryStoreMock) ForEach(fn func(parentBucketName string, e entry) error) error {
+	if mock.ForEachFunc == nil {
+		panic("entryStoreMock.ForEachFunc: method is nil but entryStore.ForEach was just called")
+	}
+	callInfo := struct {
+		Fn func(parentBucketName string, e entry) error
+	}{
+		Fn: fn,
+	}
+	mock.lockForEach.Lock()
+	mock.calls.ForEach = append(mock.calls.ForEach, callInfo)
+	mock.lockForEach.Unlock()
+	return mock.ForEachFunc(fn)
+}
+
+// ForEachCalls gets all the calls that were made to ForEach.
+// Check the length with:
+//
+//	len(mockedentryStore.ForEachCalls())
+func (mock *entryStoreMock) ForEachCalls() []struct {
+	Fn func(parentBucketName string, e entry) error
+} {
+	var calls []struct {
+		Fn func(parentBucketName string, e entry) error
+	}
+	mock.lockForEach.RLock()
+	calls = mock.calls.ForEach
+	mock.lockForEach.RUnlock()
+	return calls
+}
+
+// Get calls GetFunc.
+func (mock *entryStoreMock) Get(parentBucketName string, id string) (entry, error) {
+	if mock.GetFunc == nil {
+		panic("entryStoreMock.GetFunc: method is nil but entryStore.Get was just called")
+	}
+	callInfo := struct {
+		ParentBucketName string
+		ID               string
+	}{
+		ParentBucketName: parentBucketName,
+		ID:               id,
+	}
+	mock.lockGet.Lock()
+	mock.calls.Get = append(mock.calls.Get, callInfo)
+	mock.lockGet.Unlock()
+	return mock.GetFunc(parentBucketName, id)
+}
+
+// GetCalls gets all the calls that were made to Get.
+// Check the length with:
+//
+//	len(mockedentryStore.GetCalls())
+func (mock *entryStoreMock) GetCalls() []struct {
+	ParentBucketName string
+	ID               string
+} {
+	var calls []struct {
+		ParentBucketName string
+		ID               string
+	}
+	mock.lockGet.RLock()
+	calls = mock.calls.Get
+	mock.lockGet.RUnlock()
+	return calls
+}
+
 // GetRange calls GetRangeFunc.
 func (mock *entryStoreMock) GetRange(parentBucketName string, start time.Time, end time.Time) ([]entry, error) {
 	if mock.GetRangeFunc == nil {
@@ -200,21 +358,21 @@ func (mock *entryStoreMock) GetRangeWithAggregationCalls() []struct {
 }
 
 // Put calls PutFunc.
-func (mock *entryStoreMock) Put(s string, entryMoqParam entry) error {
+func (mock *entryStoreMock) Put(parentBucketName string, e entry) (string, error) {
 	if mock.PutFunc == nil {
 		panic("entryStoreMock.PutFunc: method is nil but entryStore.Put was just called")
 	}
 	callInfo := struct {
-		S             string
-		EntryMoqParam entry
+		ParentBucketName string
+		E                entry
 	}{
-		S:             s,
-		EntryMoqParam: entryMoqParam,
+		ParentBucketName: parentBucketName,
+		E:                e,
 	}
 	mock.lockPut.Lock()
 	mock.calls.Put = append(mock.calls.Put, callInfo)
 	mock.lockPut.Unlock()
-	return mock.PutFunc(s, entryMoqParam)
+	return mock.PutFunc(parentBucketName, e)
 }
 
 // PutCalls gets all the calls that were made to Put.
@@ -222,15 +380,51 @@ func (mock *entryStoreMock) Put(s string, entryMoqParam entry) error {
 //
 //	len(mockedentryStore.PutCalls())
 func (mock *entryStoreMock) PutCalls() []struct {
-	S             string
-	EntryMoqParam entry
+	ParentBucketName string
+	E                entry
 } {
 	var calls []struct {
-		S             string
-		EntryMoqParam entry
+		ParentBucketName string
+		E                entry
 	}
 	mock.lockPut.RLock()
 	calls = mock.calls.Put
 	mock.lockPut.RUnlock()
 	return calls
 }
+
+// Update calls UpdateFunc.
+func (mock *entryStoreMock) Update(parentBucketName string, e entry) error {
+	if mock.UpdateFunc == nil {
+		panic("entryStoreMock.UpdateFunc: method is nil but entryStore.Update was just called")
+	}
+	callInfo := struct {
+		ParentBucketName string
+		E                entry
+	}{
+		ParentBucketName: parentBucketName,
+		E:                e,
+	}
+	mock.lockUpdate.Lock()
+	mock.calls.Update = append(mock.calls.Update, callInfo)
+	mock.lockUpdate.Unlock()
+	return mock.UpdateFunc(parentBucketName, e)
+}
+
+// UpdateCalls gets all the calls that were made to Update.
+// Check the length with:
+//
+//	len(mockedentryStore.UpdateCalls())
+func (mock *entryStoreMock) UpdateCalls() []struct {
+	ParentBucketName string
+	E                entry
+} {
+	var calls []struct {
+		ParentBucketName string
+		E                entry
+	}
+	mock.lockUpdate.RLock()
+	calls = mock.calls.Update
+	mock.lockUpdate.RUnlock()
+	return calls
+}
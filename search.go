@@ -0,0 +1,208 @@
+package godid
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// SearchFilter narrows down a search
+type SearchFilter struct {
+	// Bucket limits the search to one parent bucket and the ones nested in it, all of them are searched when empty
+	Bucket string
+	// From and To limit the search to the entries logged between the two days, inclusive. Either can be left unset.
+	From time.Time
+	To   time.Time
+	// Limit caps the number of results, 0 meaning no limit
+	Limit int
+}
+
+// SearchResult is an entry matching a search
+type SearchResult struct {
+	Entry
+	// Bucket is the parent bucket holding the entry
+	Bucket string
+	// Score tells how relevant the entry is, higher is better
+	Score float64
+}
+
+// containsBucket tells whether the filter lets the entries of the parent bucket through
+func (f SearchFilter) containsBucket(parentBucketName string) bool {
+	return f.Bucket == "" || isInBucket(parentBucketName, f.Bucket)
+}
+
+// contains tells whether t falls between the From and To days of the filter
+func (f SearchFilter) contains(t time.Time) bool {
+	if !f.From.IsZero() && t.Before(startOfDay(f.From)) {
+		return false
+	}
+	if !f.To.IsZero() && !t.Before(startOfDay(f.To).AddDate(0, 0, 1)) {
+		return false
+	}
+	return true
+}
+
+// searcher is implemented by the stores able to look up entries by their words
+type searcher interface {
+	Search(q searchQuery, f SearchFilter) ([]searchHit, error)
+}
+
+// searchHit is an entry matching a search, along with its parent bucket
+type searchHit struct {
+	parentBucketName string
+	entry            entry
+	score            float64
+}
+
+// searchWord is a word of a query, matching either a whole token or, for prefix words, the tokens starting with it
+type searchWord struct {
+	text   string
+	prefix bool
+}
+
+func (w searchWord) matches(token string) bool {
+	if w.prefix {
+		return strings.HasPrefix(token, w.text)
+	}
+	return token == w.text
+}
+
+// searchClause is a word or, when made of several words, a phrase matching consecutive tokens
+type searchClause []searchWord
+
+// count returns the number of times the clause occurs in tokens
+func (c searchClause) count(tokens []string) int {
+	result := 0
+	for i := 0; i+len(c) <= len(tokens); i++ {
+		matched := true
+		for j, w := range c {
+			if !w.matches(tokens[i+j]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			result++
+		}
+	}
+	return result
+}
+
+// searchQuery holds clauses which all have to match an entry
+type searchQuery []searchClause
+
+// parseSearchQuery splits a query into clauses. Quoted text is a phrase, other words are matched anywhere in the entry
+// and a trailing * turns a word into a prefix.
+func parseSearchQuery(query string) (searchQuery, error) {
+	parts := strings.Split(query, `"`)
+	if len(parts)%2 == 0 {
+		return nil, didErrorf("unterminated phrase in search query %s", query)
+	}
+	result := make(searchQuery, 0)
+	for i, part := range parts {
+		phrase := i%2 == 1
+		var clause searchClause
+		for _, raw := range strings.Fields(part) {
+			words := parseSearchWords(raw)
+			if phrase {
+				clause = append(clause, words...)
+			} else if len(words) > 0 {
+				result = append(result, words)
+			}
+		}
+		if len(clause) > 0 {
+			result = append(result, clause)
+		}
+	}
+	if len(result) == 0 {
+		return nil, didErrorf("empty search query")
+	}
+	return result, nil
+}
+
+// parseSearchWords tokenizes a word of a query, punctuation splitting it into a phrase, e.g. v1.2 into v1 2
+func parseSearchWords(raw string) []searchWord {
+	tokens := tokenize(raw)
+	result := make([]searchWord, 0, len(tokens))
+	for _, token := range tokens {
+		result = append(result, searchWord{text: token})
+	}
+	if len(result) > 0 && strings.HasSuffix(raw, "*") {
+		result[len(result)-1].prefix = true
+	}
+	return result
+}
+
+// counts returns how many times each clause occurs in tokens and whether all of them do
+func (q searchQuery) counts(tokens []string) ([]int, bool) {
+	result := make([]int, len(q))
+	matched := true
+	for i, c := range q {
+		result[i] = c.count(tokens)
+		matched = matched && result[i] > 0
+	}
+	return result, matched
+}
+
+// score ranks an entry by tf-idf, counts being the occurrences of each clause in the entry, df the number of entries
+// holding each clause and total the number of entries searched
+func score(counts, df []int, total int) float64 {
+	result := 0.0
+	for i, count := range counts {
+		idf := math.Log(1 + float64(total)/float64(max(df[i], 1)))
+		result += (1 + math.Log(float64(count))) * idf
+	}
+	return result
+}
+
+// scanSearch searches entries without an index, for the stores that read them all anyway
+func (q searchQuery) scanSearch(candidates []searchHit) []searchHit {
+	counts := make([][]int, len(candidates))
+	matched := make([]bool, len(candidates))
+	df := make([]int, len(q))
+	for i, hit := range candidates {
+		counts[i], matched[i] = q.counts(tokenize(indexedText(hit.entry)))
+		for j, count := range counts[i] {
+			if count > 0 {
+				df[j]++
+			}
+		}
+	}
+	result := make([]searchHit, 0)
+	for i, hit := range candidates {
+		if !matched[i] {
+			continue
+		}
+		hit.score = score(counts[i], df, len(candidates))
+		result = append(result, hit)
+	}
+	return result
+}
+
+// rankHits sorts the hits by score, the most recent first among equal ones, keeping at most limit of them
+func rankHits(hits []searchHit, limit int) []searchHit {
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		return hits[i].entry.Timestamp.After(hits[j].entry.Timestamp)
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// indexedText is the searchable text of an entry: its content, tags and project
+func indexedText(e entry) string {
+	return strings.Join(append([]string{string(e.Content), e.Project}, e.Tags...), " ")
+}
+
+// tokenize splits text into lower case words, anything but letters and digits separating them
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
@@ -0,0 +1,150 @@
+package godid
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenize(t *testing.T) {
+	assert.Equal(t, []string{"fixed", "the", "billing", "migration", "v1", "2"}, tokenize("Fixed the billing-migration (v1.2)!"))
+	assert.Equal(t, []string{"café", "über"}, tokenize("Café, Über"))
+	assert.Empty(t, tokenize(" -- "))
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	testCases := []struct {
+		name        string
+		query       string
+		shouldError bool
+		expected    searchQuery
+	}{
+		{
+			name:        "empty",
+			query:       "  ",
+			shouldError: true,
+		},
+		{
+			name:        "only punctuation",
+			query:       `"" ...`,
+			shouldError: true,
+		},
+		{
+			name:        "unterminated phrase",
+			query:       `"billing migration`,
+			shouldError: true,
+		},
+		{
+			name:  "words",
+			query: "Billing migration",
+			expected: searchQuery{
+				{{text: "billing"}},
+				{{text: "migration"}},
+			},
+		},
+		{
+			name:  "phrase",
+			query: `deploy "billing migration"`,
+			expected: searchQuery{
+				{{text: "deploy"}},
+				{{text: "billing"}, {text: "migration"}},
+			},
+		},
+		{
+			name:  "prefix",
+			query: `migr* "billing mig*"`,
+			expected: searchQuery{
+				{{text: "migr", prefix: true}},
+				{{text: "billing"}, {text: "mig", prefix: true}},
+			},
+		},
+		{
+			name:  "punctuated word",
+			query: "v1.2",
+			expected: searchQuery{
+				{{text: "v1"}, {text: "2"}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := parseSearchQuery(tc.query)
+			if tc.shouldError {
+				assert.IsType(t, DidError{}, err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSearchClauseCount(t *testing.T) {
+	tokens := tokenize("reviewed the billing migration, then reverted the billing fix")
+	testCases := []struct {
+		query    string
+		expected int
+	}{
+		{query: "billing", expected: 2},
+		{query: "bill*", expected: 2},
+		{query: "re*", expected: 2},
+		{query: `"the billing"`, expected: 2},
+		{query: `"billing migration"`, expected: 1},
+		{query: `"migration billing"`, expected: 0},
+		{query: `"the bill* fix"`, expected: 1},
+		{query: "bill", expected: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.query, func(t *testing.T) {
+			q, err := parseSearchQuery(tc.query)
+			require.NoError(t, err)
+			require.Len(t, q, 1)
+			assert.Equal(t, tc.expected, q[0].count(tokens))
+		})
+	}
+}
+
+func TestSearchFilterContains(t *testing.T) {
+	day := timeFromString(t, "2018-07-18T00:00:00Z")
+	testCases := []struct {
+		name     string
+		filter   SearchFilter
+		ts       time.Time
+		expected bool
+	}{
+		{name: "no bounds", ts: day, expected: true},
+		{name: "start of from", filter: SearchFilter{From: day.Add(12 * time.Hour)}, ts: day, expected: true},
+		{name: "before from", filter: SearchFilter{From: day}, ts: day.Add(-time.Nanosecond)},
+		{name: "end of to", filter: SearchFilter{To: day}, ts: day.Add(24*time.Hour - time.Nanosecond), expected: true},
+		{name: "after to", filter: SearchFilter{To: day}, ts: day.Add(24 * time.Hour)},
+		{name: "between", filter: SearchFilter{From: day, To: day.AddDate(0, 0, 2)}, ts: day.AddDate(0, 0, 1), expected: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.filter.contains(tc.ts))
+		})
+	}
+}
+
+func TestRankHits(t *testing.T) {
+	ts := timeFromString(t, "2018-07-18T12:00:00Z")
+	hits := []searchHit{
+		{entry: entry{ID: "old", Timestamp: ts}, score: 1},
+		{entry: entry{ID: "best", Timestamp: ts}, score: 2},
+		{entry: entry{ID: "new", Timestamp: ts.Add(time.Hour)}, score: 1},
+	}
+	ids := func(hits []searchHit) []string {
+		result := make([]string, 0, len(hits))
+		for _, hit := range hits {
+			result = append(result, hit.entry.ID)
+		}
+		return result
+	}
+	assert.Equal(t, []string{"best", "new", "old"}, ids(rankHits(hits, 0)))
+	assert.Equal(t, []string{"best", "new"}, ids(rankHits(hits, 2)))
+}
@@ -0,0 +1,778 @@
+package godid
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	// registers the sqlite driver
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema holds the statements bringing the schema to each version, tracked through PRAGMA user_version
+var sqliteSchema = []string{
+	`CREATE TABLE entries (
+		seq INTEGER PRIMARY KEY AUTOINCREMENT,
+		parent_bucket TEXT NOT NULL,
+		day TEXT NOT NULL,
+		timestamp INTEGER NOT NULL,
+		content TEXT NOT NULL
+	);
+	CREATE INDEX entries_by_day ON entries (parent_bucket, day, timestamp);`,
+	// zone and utc_offset keep the zone the entry was logged in, timestamp is always in UTC
+	`ALTER TABLE entries ADD COLUMN zone TEXT;
+	ALTER TABLE entries ADD COLUMN utc_offset INTEGER;`,
+	// metadata holds the JSON encoded Metadata of the entry
+	`ALTER TABLE entries ADD COLUMN metadata TEXT;`,
+	// trash holds the deleted entries along with the time they were deleted, meta the last change to undo
+	`CREATE TABLE trash AS SELECT * FROM entries WHERE 0;
+	ALTER TABLE trash ADD COLUMN deleted_at INTEGER NOT NULL DEFAULT 0;
+	CREATE TABLE meta (key TEXT PRIMARY KEY, value BLOB NOT NULL);`,
+	// history holds the earlier versions of the edited entries along with the time they were replaced
+	`CREATE TABLE history AS SELECT * FROM entries WHERE 0;
+	ALTER TABLE history ADD COLUMN replaced_at INTEGER NOT NULL DEFAULT 0;
+	CREATE INDEX history_by_entry ON history (parent_bucket, seq);`,
+}
+
+const (
+	sqliteEntryColumns = "seq, timestamp, zone, utc_offset, content, metadata"
+	// sqliteColumns are all the columns of the entries table, the trash having them too
+	sqliteColumns = "seq, parent_bucket, day, timestamp, content, zone, utc_offset, metadata"
+	// sqliteInBucket matches the rows of a parent bucket and of the ones nested in it, taking the bucket and the bucket
+	// followed by the path separator
+	sqliteInBucket = "(parent_bucket = ? OR instr(parent_bucket, ?) = 1)"
+)
+
+type sqliteStore struct {
+	db       *sql.DB
+	path     string
+	readOnly bool
+	timeout  time.Duration
+	// loc is the home location, deciding the day buckets
+	loc *time.Location
+}
+
+// newSQLiteStore creates new entryStore with an embedded sqlite database as a backend.
+// Entries are kept in the entries table, with the timestamp in unix nanoseconds and the day bucket alongside.
+func newSQLiteStore(cfg config, readOnly bool) (*sqliteStore, error) {
+	path, err := cfg.GetStorePath()
+	if err != nil {
+		return nil, err
+	}
+	loc, err := cfg.GetLocation()
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := cfg.GetLockTimeout()
+	if err != nil {
+		return nil, err
+	}
+	db, err := openSQLite(path, readOnly, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteStore{
+		db:       db,
+		path:     path,
+		readOnly: readOnly,
+		timeout:  timeout,
+		loc:      loc,
+	}, nil
+}
+
+// openSQLite opens the sqlite database at path, bringing it to the latest schema version. A read only database
+// has to exist and be up to date already.
+func openSQLite(path string, readOnly bool, timeout time.Duration) (*sql.DB, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(%d)", path, timeout.Milliseconds())
+	if readOnly {
+		if _, err := os.Stat(path); err != nil {
+			return nil, err
+		}
+		dsn += "&mode=ro"
+	}
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if readOnly {
+		err = checkSQLiteSchema(db)
+	} else {
+		err = migrateSQLiteSchema(db)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func checkSQLiteSchema(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	if version < len(sqliteSchema) {
+		return errReadOnly
+	}
+	return nil
+}
+
+func migrateSQLiteSchema(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	for ; version < len(sqliteSchema); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqliteSchema[version]); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *sqliteStore) Put(parentBucketName string, e entry) (string, error) {
+	if err := checkBucketPath(parentBucketName); err != nil {
+		return "", err
+	}
+	bucketName, err := getBucketFromEntry(e, s.loc)
+	if err != nil {
+		return "", err
+	}
+	metadata, err := json.Marshal(e.Metadata)
+	if err != nil {
+		return "", err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+	// the sequence is shared by every bucket, the one of a kept id mustn't be used by another entry, trashed ones included
+	var seq any
+	if key := keptKey(e); key != nil {
+		var taken int
+		err := tx.QueryRow(
+			"SELECT (SELECT COUNT(*) FROM entries WHERE seq = ?1) + (SELECT COUNT(*) FROM trash WHERE seq = ?1)", keySequence(key),
+		).Scan(&taken)
+		if err != nil {
+			return "", err
+		}
+		if taken == 0 {
+			seq = keySequence(key)
+		}
+	}
+	zone, offset := e.Timestamp.Zone()
+	res, err := tx.Exec(
+		"INSERT INTO entries (seq, parent_bucket, day, timestamp, zone, utc_offset, content, metadata) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		seq, parentBucketName, bucketName, e.Timestamp.UnixNano(), zone, offset, string(e.Content), string(metadata),
+	)
+	if err != nil {
+		return "", err
+	}
+	inserted, err := res.LastInsertId()
+	if err != nil {
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return formatID(encodeKey(e.Timestamp, uint64(inserted))), nil
+}
+
+func (s *sqliteStore) Get(parentBucketName string, id string) (entry, error) {
+	key, err := parseID(id)
+	if err != nil {
+		return entry{}, err
+	}
+	timestamp, err := decodeKey(key)
+	if err != nil {
+		return entry{}, err
+	}
+	row := s.db.QueryRow(
+		"SELECT "+sqliteEntryColumns+" FROM entries WHERE seq = ? AND parent_bucket = ? AND timestamp = ?",
+		keySequence(key), parentBucketName, timestamp.UnixNano(),
+	)
+	result, err := s.scanEntry(row)
+	if err == sql.ErrNoRows {
+		return entry{}, entryNotFoundError(parentBucketName, id)
+	}
+	return result, err
+}
+
+func (s *sqliteStore) Update(parentBucketName string, e entry) error {
+	key, err := parseID(e.ID)
+	if err != nil {
+		return err
+	}
+	timestamp, err := decodeKey(key)
+	if err != nil {
+		return err
+	}
+	metadata, err := json.Marshal(e.Metadata)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	args := []any{keySequence(key), parentBucketName, timestamp.UnixNano()}
+	result, err := tx.Exec(
+		"INSERT INTO history ("+sqliteColumns+", replaced_at) SELECT "+sqliteColumns+", ? FROM entries WHERE seq = ? AND parent_bucket = ? AND timestamp = ?",
+		append([]any{time.Now().UnixNano()}, args...)...,
+	)
+	if err := checkAffected(result, err, parentBucketName, e.ID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		"UPDATE entries SET content = ?, metadata = ? WHERE seq = ? AND parent_bucket = ? AND timestamp = ?",
+		append([]any{string(e.Content), string(metadata)}, args...)...,
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore) History(parentBucketName string, id string) ([]revision, error) {
+	key, err := parseID(id)
+	if err != nil {
+		return nil, err
+	}
+	timestamp, err := decodeKey(key)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.db.Query(
+		"SELECT replaced_at, "+sqliteEntryColumns+" FROM history WHERE seq = ? AND parent_bucket = ? AND timestamp = ? ORDER BY replaced_at, rowid",
+		keySequence(key), parentBucketName, timestamp.UnixNano(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make([]revision, 0)
+	for rows.Next() {
+		var replacedAt int64
+		e, err := s.scanEntry(rows, &replacedAt)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, revision{entry: e, replacedAt: time.Unix(0, replacedAt)})
+	}
+	return result, rows.Err()
+}
+
+func (s *sqliteStore) SetHistory(parentBucketName string, id string, revisions []revision) error {
+	key, err := parseID(id)
+	if err != nil {
+		return err
+	}
+	timestamp, err := decodeKey(key)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(
+		"DELETE FROM history WHERE seq = ? AND parent_bucket = ? AND timestamp = ?",
+		keySequence(key), parentBucketName, timestamp.UnixNano(),
+	)
+	if err != nil {
+		return err
+	}
+	for _, r := range revisions {
+		day, err := getBucketFromEntry(r.entry, s.loc)
+		if err != nil {
+			return err
+		}
+		metadata, err := json.Marshal(r.Metadata)
+		if err != nil {
+			return err
+		}
+		zone, offset := r.Timestamp.Zone()
+		_, err = tx.Exec(
+			"INSERT INTO history ("+sqliteColumns+", replaced_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			keySequence(key), parentBucketName, day, timestamp.UnixNano(), string(r.Content), zone, offset, string(metadata),
+			r.replacedAt.UnixNano(),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore) Delete(parentBucketName string, id string) error {
+	key, err := parseID(id)
+	if err != nil {
+		return err
+	}
+	timestamp, err := decodeKey(key)
+	if err != nil {
+		return err
+	}
+	result, err := s.db.Exec(
+		"DELETE FROM entries WHERE seq = ? AND parent_bucket = ? AND timestamp = ?",
+		keySequence(key), parentBucketName, timestamp.UnixNano(),
+	)
+	return checkAffected(result, err, parentBucketName, id)
+}
+
+func (s *sqliteStore) GetRange(parentBucketName string, start, end time.Time) ([]entry, error) {
+	r, err := newDayRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+	first, last := r.bucketBounds(s.loc)
+	rows, err := s.db.Query(
+		`SELECT `+sqliteEntryColumns+` FROM entries
+		WHERE parent_bucket = ? AND day BETWEEN ? AND ? AND timestamp >= ? AND timestamp < ?
+		ORDER BY day, timestamp, seq`,
+		parentBucketName, first, last, r.start.UnixNano(), r.end.UnixNano(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make([]entry, 0)
+	for rows.Next() {
+		e, err := s.scanEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, e)
+	}
+	return result, rows.Err()
+}
+
+func (s *sqliteStore) GetRangeWithAggregation(parentBucketName string, start, end time.Time, agg aggregationFunction) (any, error) {
+	if agg == nil {
+		return nil, errors.New("aggregation function is nil")
+	}
+	entries, err := s.GetRange(parentBucketName, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return agg(entries)
+}
+
+// Search reads all the entries of the filtered buckets, the sqlite store not keeping a full-text index
+func (s *sqliteStore) Search(q searchQuery, f SearchFilter) ([]searchHit, error) {
+	query := "SELECT parent_bucket, " + sqliteEntryColumns + " FROM entries"
+	args := make([]any, 0)
+	if f.Bucket != "" {
+		query += " WHERE " + sqliteInBucket
+		args = append(args, f.Bucket, f.Bucket+bucketPathSeparator)
+	}
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	candidates := make([]searchHit, 0)
+	for rows.Next() {
+		var parentBucketName string
+		e, err := s.scanEntry(rows, &parentBucketName)
+		if err != nil {
+			return nil, err
+		}
+		if f.contains(e.Timestamp) {
+			candidates = append(candidates, searchHit{parentBucketName: parentBucketName, entry: e})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return q.scanSearch(candidates), nil
+}
+
+func (s *sqliteStore) ForEach(fn func(parentBucketName string, e entry) error) error {
+	rows, err := s.db.Query("SELECT parent_bucket, " + sqliteEntryColumns + " FROM entries ORDER BY parent_bucket, day, timestamp")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var parentBucketName string
+		e, err := s.scanEntry(rows, &parentBucketName)
+		if err != nil {
+			return err
+		}
+		if err := fn(parentBucketName, e); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (s *sqliteStore) Trash(parentBucketName string, id string, deletedAt time.Time) error {
+	key, err := parseID(id)
+	if err != nil {
+		return err
+	}
+	timestamp, err := decodeKey(key)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	args := []any{keySequence(key), parentBucketName, timestamp.UnixNano()}
+	result, err := tx.Exec(
+		"INSERT INTO trash ("+sqliteColumns+", deleted_at) SELECT "+sqliteColumns+", ? FROM entries WHERE seq = ? AND parent_bucket = ? AND timestamp = ?",
+		append([]any{deletedAt.UnixNano()}, args...)...,
+	)
+	if err := checkAffected(result, err, parentBucketName, id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM entries WHERE seq = ? AND parent_bucket = ? AND timestamp = ?", args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *sqliteStore) Untrash(parentBucketName string, id string) (entry, error) {
+	key, err := parseID(id)
+	if err != nil {
+		return entry{}, err
+	}
+	timestamp, err := decodeKey(key)
+	if err != nil {
+		return entry{}, err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return entry{}, err
+	}
+	defer tx.Rollback()
+	args := []any{keySequence(key), parentBucketName, timestamp.UnixNano()}
+	result, err := s.scanEntry(tx.QueryRow(
+		"SELECT "+sqliteEntryColumns+" FROM trash WHERE seq = ? AND parent_bucket = ? AND timestamp = ?", args...,
+	))
+	if err == sql.ErrNoRows {
+		return entry{}, trashedEntryNotFoundError(parentBucketName, id)
+	}
+	if err != nil {
+		return entry{}, err
+	}
+	var taken int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM entries WHERE seq = ?", keySequence(key)).Scan(&taken); err != nil {
+		return entry{}, err
+	}
+	if taken > 0 {
+		return entry{}, didErrorf("an entry with id %s already exists in bucket %s", id, parentBucketName)
+	}
+	_, err = tx.Exec(
+		"INSERT INTO entries ("+sqliteColumns+") SELECT "+sqliteColumns+" FROM trash WHERE seq = ? AND parent_bucket = ? AND timestamp = ?",
+		args...,
+	)
+	if err != nil {
+		return entry{}, err
+	}
+	if _, err := tx.Exec("DELETE FROM trash WHERE seq = ? AND parent_bucket = ? AND timestamp = ?", args...); err != nil {
+		return entry{}, err
+	}
+	return result, tx.Commit()
+}
+
+func (s *sqliteStore) ListTrash() ([]trashedEntry, error) {
+	rows, err := s.db.Query("SELECT parent_bucket, deleted_at, " + sqliteEntryColumns + " FROM trash ORDER BY deleted_at DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make([]trashedEntry, 0)
+	for rows.Next() {
+		var (
+			parentBucketName string
+			deletedAt        int64
+		)
+		e, err := s.scanEntry(rows, &parentBucketName, &deletedAt)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, trashedEntry{parentBucketName: parentBucketName, entry: e, deletedAt: time.Unix(0, deletedAt)})
+	}
+	return result, rows.Err()
+}
+
+func (s *sqliteStore) EmptyTrash() (int, error) {
+	result, err := s.db.Exec("DELETE FROM trash")
+	if err != nil {
+		return 0, err
+	}
+	count, err := result.RowsAffected()
+	return int(count), err
+}
+
+func (s *sqliteStore) SetLastChange(c *change) error {
+	if c == nil {
+		_, err := s.db.Exec("DELETE FROM meta WHERE key = ?", lastChangeKey)
+		return err
+	}
+	v, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec("INSERT OR REPLACE INTO meta (key, value) VALUES (?, ?)", lastChangeKey, v)
+	return err
+}
+
+func (s *sqliteStore) LastChange() (*change, error) {
+	var v []byte
+	err := s.db.QueryRow("SELECT value FROM meta WHERE key = ?", lastChangeKey).Scan(&v)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := &change{}
+	if err := json.Unmarshal(v, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (s *sqliteStore) Buckets() ([]BucketStats, error) {
+	rows, err := s.db.Query(
+		"SELECT parent_bucket, COUNT(*), COUNT(DISTINCT day), MIN(timestamp), MAX(timestamp) FROM entries GROUP BY parent_bucket ORDER BY parent_bucket",
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make([]BucketStats, 0)
+	for rows.Next() {
+		var (
+			stats       BucketStats
+			first, last int64
+		)
+		if err := rows.Scan(&stats.Name, &stats.Entries, &stats.Days, &first, &last); err != nil {
+			return nil, err
+		}
+		stats.First, stats.Last = time.Unix(0, first).In(s.loc), time.Unix(0, last).In(s.loc)
+		result = append(result, stats)
+	}
+	return result, rows.Err()
+}
+
+func (s *sqliteStore) ChildBuckets(parentBucketName string) ([]string, error) {
+	prefix := parentBucketName + bucketPathSeparator
+	rows, err := s.db.Query(
+		"SELECT DISTINCT parent_bucket FROM entries WHERE instr(parent_bucket, ?) = 1 ORDER BY parent_bucket", prefix,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	result := make([]string, 0)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		result = append(result, name)
+	}
+	return result, rows.Err()
+}
+
+// RenameBucket moves the rows of the entries, trash and history tables, the ids coming from the global sequence
+func (s *sqliteStore) RenameBucket(oldName, newName string) error {
+	if err := checkBucketPath(newName); err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var count int
+	err = tx.QueryRow("SELECT COUNT(*) FROM entries WHERE "+sqliteInBucket, newName, newName+bucketPathSeparator).Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return bucketExistsError(newName)
+	}
+	if _, err := moveBucketRows(tx, oldName, newName); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MergeBucket moves the rows like RenameBucket, into a parent bucket that may hold entries already, the ids being
+// unique across the buckets
+func (s *sqliteStore) MergeBucket(src, dst string) (int, error) {
+	if err := checkBucketPath(dst); err != nil {
+		return 0, err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	moved, err := moveBucketRows(tx, src, dst)
+	if err != nil {
+		return 0, err
+	}
+	return moved, tx.Commit()
+}
+
+// moveBucketRows moves the rows of a parent bucket and of the ones nested in it, returning how many entries were moved
+func moveBucketRows(tx *sql.Tx, oldName, newName string) (int, error) {
+	// the rows of the nested buckets keep the rest of their path
+	rename := " SET parent_bucket = ? || substr(parent_bucket, ?) WHERE " + sqliteInBucket
+	args := []any{newName, len([]rune(oldName)) + 1, oldName, oldName + bucketPathSeparator}
+	result, err := tx.Exec("UPDATE entries"+rename, args...)
+	if err != nil {
+		return 0, err
+	}
+	moved, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if moved == 0 {
+		return 0, bucketNotFoundError(oldName)
+	}
+	for _, table := range []string{"trash", "history"} {
+		if _, err := tx.Exec("UPDATE "+table+rename, args...); err != nil {
+			return 0, err
+		}
+	}
+	return int(moved), nil
+}
+
+func (s *sqliteStore) Backup(path string) error {
+	return replaceFile(path, func(tmp string) error {
+		_, err := s.db.Exec("VACUUM INTO ?", tmp)
+		return err
+	})
+}
+
+func (s *sqliteStore) Restore(path string) error {
+	if err := checkSnapshotPath(path, s.path); err != nil {
+		return err
+	}
+	if err := checkSQLiteSnapshot(path); err != nil {
+		return err
+	}
+	closed := false
+	err := replaceFile(s.path, func(tmp string) error {
+		if err := copyFile(path, tmp); err != nil {
+			return err
+		}
+		closed = true
+		return s.db.Close()
+	})
+	if closed {
+		db, openErr := openSQLite(s.path, s.readOnly, s.timeout)
+		if openErr != nil {
+			return openErr
+		}
+		s.db = db
+	}
+	return err
+}
+
+func (s *sqliteStore) Close() error {
+	return s.db.Close()
+}
+
+// scanEntry reads an entry out of a row holding the sqliteEntryColumns, preceded by the columns scanned into extra
+func (s *sqliteStore) scanEntry(row interface{ Scan(...any) error }, extra ...any) (entry, error) {
+	var (
+		seq       uint64
+		timestamp int64
+		zoneName  sql.NullString
+		offset    sql.NullInt64
+		content   string
+		metadata  sql.NullString
+	)
+	if err := row.Scan(append(extra, &seq, &timestamp, &zoneName, &offset, &content, &metadata)...); err != nil {
+		return entry{}, err
+	}
+	var zone *time.Location
+	if zoneName.Valid && offset.Valid {
+		zone = time.FixedZone(zoneName.String, int(offset.Int64))
+	}
+	var meta Metadata
+	if metadata.Valid {
+		if err := json.Unmarshal([]byte(metadata.String), &meta); err != nil {
+			return entry{}, err
+		}
+	}
+	t := inZone(time.Unix(0, timestamp), zone, s.loc)
+	return entry{
+		ID:        formatID(encodeKey(t, seq)),
+		Timestamp: t,
+		Content:   []byte(content),
+		Metadata:  meta,
+	}, nil
+}
+
+// checkSQLiteSnapshot makes sure the sqlite file at path passes the integrity check, has a supported schema and holds
+// the entries table
+func checkSQLiteSnapshot(path string) error {
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?mode=ro", path))
+	if err != nil {
+		return invalidSnapshotError(path, err)
+	}
+	defer db.Close()
+	var result string
+	if err := db.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		return invalidSnapshotError(path, err)
+	}
+	if result != "ok" {
+		return invalidSnapshotError(path, errors.New(result))
+	}
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return invalidSnapshotError(path, err)
+	}
+	if version == 0 || version > len(sqliteSchema) {
+		return invalidSnapshotError(path, fmt.Errorf("unsupported schema version %d", version))
+	}
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM entries").Scan(&count); err != nil {
+		return invalidSnapshotError(path, err)
+	}
+	return nil
+}
+
+// checkAffected turns a statement that didn't touch any entry into a not found error
+func checkAffected(result sql.Result, err error, parentBucketName, id string) error {
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return entryNotFoundError(parentBucketName, id)
+	}
+	return nil
+}
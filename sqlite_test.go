@@ -0,0 +1,40 @@
+package godid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type sqliteTestSuite struct {
+	storeTestSuite
+}
+
+func (s *sqliteTestSuite) TestPut() {
+	ts := timeFromString(s.T(), "2018-07-18T12:11:00Z")
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("msg1")})
+	_, err := s.store.Put(s.testBucketName, entry{Content: []byte("msg2")})
+	s.Error(err)
+
+	var (
+		day       string
+		timestamp int64
+		content   string
+	)
+	row := s.store.(*sqliteStore).db.QueryRow("SELECT day, timestamp, content FROM entries WHERE parent_bucket = ?", s.testBucketName)
+	s.Require().NoError(row.Scan(&day, &timestamp, &content))
+	s.Equal("2018-07-18", day)
+	s.Equal(ts.UnixNano(), timestamp)
+	s.Equal("msg1", content)
+}
+
+func TestSQLiteStore(t *testing.T) {
+	suite.Run(t, &sqliteTestSuite{
+		storeTestSuite: storeTestSuite{
+			newStore: func(cfg config) entryStore {
+				return getTestSQLiteStore(t, cfg)
+			},
+			cleanup: cleanupTestSQLiteStore,
+		},
+	})
+}
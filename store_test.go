@@ -0,0 +1,825 @@
+package godid
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/suite"
+)
+
+// storeTestSuite holds the tests every entryStore implementation has to pass
+type storeTestSuite struct {
+	suite.Suite
+	newStore       func(cfg config) entryStore
+	cleanup        func()
+	store          entryStore
+	testBucketName string
+}
+
+func (s *storeTestSuite) SetupSuite() {
+	s.cleanup()
+}
+
+func (s *storeTestSuite) SetupTest() {
+	s.store = s.newStore(config{})
+	s.testBucketName = randString(10)
+}
+
+func (s *storeTestSuite) TearDownTest() {
+	s.store.Close()
+	s.store = nil
+	s.cleanup()
+}
+
+func (s *storeTestSuite) TestGetRange() {
+	entries := []entry{
+		{Timestamp: timeFromString(s.T(), "2018-07-18T12:11:00Z"), Content: []byte("msg1")},
+		{Timestamp: timeFromString(s.T(), "2018-07-18T13:32:00Z"), Content: []byte("msg2")},
+		{Timestamp: timeFromString(s.T(), "2018-07-18T14:21:00Z"), Content: []byte("msg3")},
+		{Timestamp: timeFromString(s.T(), "2018-07-19T09:11:00Z"), Content: []byte("msg4")},
+		{Timestamp: timeFromString(s.T(), "2018-07-19T23:15:00Z"), Content: []byte("msg5")},
+		{Timestamp: timeFromString(s.T(), "2018-07-20T10:11:00Z"), Content: []byte("msg6")},
+	}
+	for _, entry := range entries {
+		putEntry(s.T(), s.store, s.testBucketName, entry)
+	}
+	testCases := []struct {
+		start       time.Time
+		end         time.Time
+		shouldError bool
+		expected    []entry
+	}{
+		{
+			shouldError: true,
+		},
+		{
+			start:       time.Now().Add(1 * time.Hour),
+			end:         time.Now(),
+			shouldError: true,
+		},
+		{
+			start:    timeFromString(s.T(), "2018-06-20T10:11:00Z"),
+			end:      timeFromString(s.T(), "2018-06-21T10:11:00Z"),
+			expected: []entry{},
+		},
+		{
+			start:    timeFromString(s.T(), "2018-07-20T09:11:00Z"),
+			end:      timeFromString(s.T(), "2018-07-20T10:11:00Z"),
+			expected: []entry{{Timestamp: timeFromString(s.T(), "2018-07-20T10:11:00Z"), Content: []byte("msg6")}},
+		},
+		{
+			start: timeFromString(s.T(), "2018-07-18T12:11:00Z"),
+			end:   timeFromString(s.T(), "2018-07-19T09:11:00Z"),
+			expected: []entry{
+				{Timestamp: timeFromString(s.T(), "2018-07-18T12:11:00Z"), Content: []byte("msg1")},
+				{Timestamp: timeFromString(s.T(), "2018-07-18T13:32:00Z"), Content: []byte("msg2")},
+				{Timestamp: timeFromString(s.T(), "2018-07-18T14:21:00Z"), Content: []byte("msg3")},
+				{Timestamp: timeFromString(s.T(), "2018-07-19T09:11:00Z"), Content: []byte("msg4")},
+				{Timestamp: timeFromString(s.T(), "2018-07-19T23:15:00Z"), Content: []byte("msg5")},
+			},
+		},
+		{
+			start: timeFromString(s.T(), "2018-06-18T12:11:00Z"),
+			end:   timeFromString(s.T(), "2018-09-19T09:11:00Z"),
+			expected: []entry{
+				{Timestamp: timeFromString(s.T(), "2018-07-18T12:11:00Z"), Content: []byte("msg1")},
+				{Timestamp: timeFromString(s.T(), "2018-07-18T13:32:00Z"), Content: []byte("msg2")},
+				{Timestamp: timeFromString(s.T(), "2018-07-18T14:21:00Z"), Content: []byte("msg3")},
+				{Timestamp: timeFromString(s.T(), "2018-07-19T09:11:00Z"), Content: []byte("msg4")},
+				{Timestamp: timeFromString(s.T(), "2018-07-19T23:15:00Z"), Content: []byte("msg5")},
+				{Timestamp: timeFromString(s.T(), "2018-07-20T10:11:00Z"), Content: []byte("msg6")},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		entries, err := s.store.GetRange(s.testBucketName, tc.start, tc.end)
+		if tc.shouldError {
+			s.Error(err)
+		} else {
+			s.NoError(err)
+			requireEntriesEqual(s.T(), tc.expected, entries)
+		}
+	}
+}
+
+func (s *storeTestSuite) TestGetRangeWithAggregation() {
+	entries := []entry{
+		{Timestamp: timeFromString(s.T(), "2018-07-18T12:11:00Z"), Content: []byte("msg1")},
+		{Timestamp: timeFromString(s.T(), "2018-07-18T13:32:00Z"), Content: []byte("msg2")},
+		{Timestamp: timeFromString(s.T(), "2018-07-18T14:21:00Z"), Content: []byte("msg3")},
+		{Timestamp: timeFromString(s.T(), "2018-07-19T09:11:00Z"), Content: []byte("msg4")},
+		{Timestamp: timeFromString(s.T(), "2018-07-19T23:15:00Z"), Content: []byte("msg5")},
+		{Timestamp: timeFromString(s.T(), "2018-07-20T10:11:00Z"), Content: []byte("msg6")},
+	}
+	for _, entry := range entries {
+		putEntry(s.T(), s.store, s.testBucketName, entry)
+	}
+	testCases := []struct {
+		start       time.Time
+		end         time.Time
+		agg         aggregationFunction
+		shouldError bool
+		expected    []entry
+	}{
+		{
+			shouldError: true,
+		},
+		{
+			start:       time.Now().Add(1 * time.Hour),
+			end:         time.Now(),
+			shouldError: true,
+			agg: func(e []entry) (any, error) {
+				return nil, nil
+			},
+		},
+		{
+			start:       time.Now().Add(1 * time.Hour),
+			end:         time.Now(),
+			shouldError: true,
+		},
+		{
+			start:       timeFromString(s.T(), "2018-06-20T10:11:00Z"),
+			end:         timeFromString(s.T(), "2018-06-21T10:11:00Z"),
+			shouldError: true,
+		},
+		{
+			start: timeFromString(s.T(), "2018-06-20T10:11:00Z"),
+			end:   timeFromString(s.T(), "2018-06-21T10:11:00Z"),
+			agg: func(e []entry) (any, error) {
+				return nil, errors.New("BOOM")
+			},
+			shouldError: true,
+		},
+		{
+			start: timeFromString(s.T(), "2018-06-18T12:11:00Z"),
+			end:   timeFromString(s.T(), "2018-09-19T09:11:00Z"),
+			agg: func(e []entry) (any, error) {
+				return e, nil
+			},
+			expected: []entry{
+				{Timestamp: timeFromString(s.T(), "2018-07-18T12:11:00Z"), Content: []byte("msg1")},
+				{Timestamp: timeFromString(s.T(), "2018-07-18T13:32:00Z"), Content: []byte("msg2")},
+				{Timestamp: timeFromString(s.T(), "2018-07-18T14:21:00Z"), Content: []byte("msg3")},
+				{Timestamp: timeFromString(s.T(), "2018-07-19T09:11:00Z"), Content: []byte("msg4")},
+				{Timestamp: timeFromString(s.T(), "2018-07-19T23:15:00Z"), Content: []byte("msg5")},
+				{Timestamp: timeFromString(s.T(), "2018-07-20T10:11:00Z"), Content: []byte("msg6")},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		result, err := s.store.GetRangeWithAggregation(s.testBucketName, tc.start, tc.end, tc.agg)
+		if tc.shouldError {
+			s.Error(err)
+		} else {
+			s.NoError(err)
+			entries, ok := result.([]entry)
+			s.True(ok)
+			requireEntriesEqual(s.T(), tc.expected, entries)
+		}
+	}
+}
+
+func (s *storeTestSuite) TestPutSameTimestamp() {
+	ts := timeFromString(s.T(), "2018-07-18T12:11:00Z")
+	for _, content := range []string{"msg1", "msg2", "msg3"} {
+		putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte(content)})
+	}
+	entries, err := s.store.GetRange(s.testBucketName, ts, ts)
+	s.NoError(err)
+	requireEntriesEqual(s.T(), []entry{
+		{Timestamp: ts, Content: []byte("msg1")},
+		{Timestamp: ts, Content: []byte("msg2")},
+		{Timestamp: ts, Content: []byte("msg3")},
+	}, entries)
+}
+
+func (s *storeTestSuite) TestPutKeepsID() {
+	ts := timeFromString(s.T(), "2018-07-18T12:11:00Z")
+	id := formatID(encodeKey(ts, 42))
+	s.Equal(id, putEntry(s.T(), s.store, s.testBucketName, entry{ID: id, Timestamp: ts, Content: []byte("msg1")}))
+	taken := putEntry(s.T(), s.store, s.testBucketName, entry{ID: id, Timestamp: ts, Content: []byte("msg2")})
+	s.NotEqual(id, taken, "a taken id can't be kept")
+	other := putEntry(s.T(), s.store, s.testBucketName, entry{ID: id, Timestamp: ts.Add(time.Second), Content: []byte("msg3")})
+	s.NotEqual(id, other, "the id of another time can't be kept")
+
+	e, err := s.store.Get(s.testBucketName, id)
+	s.NoError(err)
+	s.Equal("msg1", string(e.Content))
+	entries, err := s.store.GetRange(s.testBucketName, ts, ts)
+	s.NoError(err)
+	s.Equal([]string{id, taken, other}, lo.Map(entries, func(e entry, _ int) string { return e.ID }))
+}
+
+func (s *storeTestSuite) TestPutSkipsUsedIDs() {
+	ts := timeFromString(s.T(), "2018-07-18T12:11:00Z")
+	trashed := putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("msg1")})
+	s.NoError(s.store.(trasher).Trash(s.testBucketName, trashed, ts))
+	added := putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("msg2")})
+	s.NotEqual(trashed, added, "a new entry mustn't take the id of a trashed one")
+	s.NoError(s.store.Update(s.testBucketName, entry{ID: added, Content: []byte("msg2 edited")}))
+	s.NoError(s.store.Delete(s.testBucketName, added))
+	s.NotEqual(added, putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("msg3")}),
+		"a new entry mustn't take the history of a deleted one")
+	_, err := s.store.(trasher).Untrash(s.testBucketName, trashed)
+	s.NoError(err)
+}
+
+func (s *storeTestSuite) TestUpdate() {
+	ts := timeFromString(s.T(), "2018-07-18T12:11:00Z")
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("typo")})
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("msg2")})
+	entries, err := s.store.GetRange(s.testBucketName, ts, ts)
+	s.Require().NoError(err)
+	s.Require().Len(entries, 2)
+	s.NotEqual(entries[0].ID, entries[1].ID)
+
+	s.NoError(s.store.Update(s.testBucketName, entry{ID: entries[0].ID, Content: []byte("msg1")}))
+	updated, err := s.store.GetRange(s.testBucketName, ts, ts)
+	s.NoError(err)
+	s.Equal([]entry{
+		{ID: entries[0].ID, Timestamp: entries[0].Timestamp, Content: []byte("msg1")},
+		entries[1],
+	}, updated)
+
+	err = s.store.Update(randString(10), entry{ID: entries[0].ID, Content: []byte("msg1")})
+	s.IsType(DidError{}, err)
+	err = s.store.Update(s.testBucketName, entry{ID: formatID(encodeKey(ts, 100)), Content: []byte("msg1")})
+	s.IsType(DidError{}, err)
+	err = s.store.Update(s.testBucketName, entry{ID: "bad", Content: []byte("msg1")})
+	s.IsType(DidError{}, err)
+}
+
+func (s *storeTestSuite) TestDelete() {
+	ts := timeFromString(s.T(), "2018-07-18T12:11:00Z")
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("msg1")})
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("msg2")})
+	entries, err := s.store.GetRange(s.testBucketName, ts, ts)
+	s.Require().NoError(err)
+	s.Require().Len(entries, 2)
+
+	s.NoError(s.store.Delete(s.testBucketName, entries[0].ID))
+	remaining, err := s.store.GetRange(s.testBucketName, ts, ts)
+	s.NoError(err)
+	s.Equal(entries[1:], remaining)
+
+	s.IsType(DidError{}, s.store.Delete(s.testBucketName, entries[0].ID))
+	s.IsType(DidError{}, s.store.Delete(s.testBucketName, "bad"))
+}
+
+func (s *storeTestSuite) TestHistory() {
+	ts := timeFromString(s.T(), "2018-07-18T12:11:00Z")
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("msg1")})
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("msg2")})
+	entries, err := s.store.GetRange(s.testBucketName, ts, ts)
+	s.Require().NoError(err)
+	s.Require().Len(entries, 2)
+
+	h := s.store.(historian)
+	revisions, err := h.History(s.testBucketName, entries[0].ID)
+	s.NoError(err)
+	s.Empty(revisions)
+	before := time.Now()
+	s.NoError(s.store.Update(s.testBucketName, entry{ID: entries[0].ID, Content: []byte("msg1 edited"), Metadata: Metadata{Project: "p"}}))
+	s.NoError(s.store.Update(s.testBucketName, entry{ID: entries[0].ID, Content: []byte("msg1 edited again")}))
+	s.IsType(DidError{}, s.store.Update(s.testBucketName, entry{ID: formatID(encodeKey(ts, 100)), Content: []byte("nothing")}))
+
+	revisions, err = h.History(s.testBucketName, entries[0].ID)
+	s.NoError(err)
+	s.Require().Len(revisions, 2)
+	s.Equal(entries[0], revisions[0].entry)
+	s.Equal(entry{ID: entries[0].ID, Timestamp: entries[0].Timestamp, Content: []byte("msg1 edited"), Metadata: Metadata{Project: "p"}}, revisions[1].entry)
+	s.False(revisions[0].replacedAt.Before(before.Truncate(time.Second)))
+	s.False(revisions[1].replacedAt.Before(revisions[0].replacedAt))
+
+	revisions, err = h.History(s.testBucketName, entries[1].ID)
+	s.NoError(err)
+	s.Empty(revisions, "the other entries must keep their own history")
+	revisions, err = h.History(randString(10), entries[0].ID)
+	s.NoError(err)
+	s.Empty(revisions)
+	_, err = h.History(s.testBucketName, "bad")
+	s.IsType(DidError{}, err)
+}
+
+func (s *storeTestSuite) TestSetHistory() {
+	importer, ok := s.store.(historyImporter)
+	if !ok {
+		s.T().Skip("the store can't take in the history of an entry")
+	}
+	ts := timeFromString(s.T(), "2018-07-18T12:11:00Z")
+	from := putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("msg1")})
+	to := putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("msg1 edited again")})
+	s.NoError(s.store.Update(s.testBucketName, entry{ID: from, Content: []byte("msg1 edited")}))
+	s.NoError(s.store.Update(s.testBucketName, entry{ID: from, Content: []byte("msg1 edited again")}))
+	h := s.store.(historian)
+	revisions, err := h.History(s.testBucketName, from)
+	s.Require().NoError(err)
+
+	s.NoError(importer.SetHistory(s.testBucketName, to, revisions))
+	moved, err := h.History(s.testBucketName, to)
+	s.NoError(err)
+	s.Require().Len(moved, 2)
+	s.Equal([]string{"msg1", "msg1 edited"}, lo.Map(moved, func(r revision, _ int) string { return string(r.Content) }))
+	s.Equal(to, moved[0].ID)
+	s.True(revisions[1].replacedAt.Equal(moved[1].replacedAt))
+
+	s.NoError(importer.SetHistory(s.testBucketName, to, revisions[:1]))
+	moved, err = h.History(s.testBucketName, to)
+	s.NoError(err)
+	s.Len(moved, 1, "the earlier history is replaced")
+	revisions, err = h.History(s.testBucketName, from)
+	s.NoError(err)
+	s.Len(revisions, 2)
+}
+
+func (s *storeTestSuite) TestTrash() {
+	ts := timeFromString(s.T(), "2018-07-18T12:11:00Z")
+	deletedAt := timeFromString(s.T(), "2018-07-20T10:00:00Z")
+	otherBucketName := randString(10)
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("billing"), Metadata: Metadata{Project: "p"}})
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("msg2")})
+	putEntry(s.T(), s.store, otherBucketName, entry{Timestamp: ts, Content: []byte("msg3")})
+	entries, err := s.store.GetRange(s.testBucketName, ts, ts)
+	s.Require().NoError(err)
+	s.Require().Len(entries, 2)
+	others, err := s.store.GetRange(otherBucketName, ts, ts)
+	s.Require().NoError(err)
+	s.Require().Len(others, 1)
+
+	t := s.store.(trasher)
+	s.NoError(t.Trash(s.testBucketName, entries[0].ID, deletedAt))
+	s.NoError(t.Trash(otherBucketName, others[0].ID, deletedAt.Add(time.Hour)))
+	s.IsType(DidError{}, t.Trash(s.testBucketName, entries[0].ID, deletedAt))
+	remaining, err := s.store.GetRange(s.testBucketName, ts, ts)
+	s.NoError(err)
+	s.Equal(entries[1:], remaining)
+	q, err := parseSearchQuery("billing")
+	s.Require().NoError(err)
+	hits, err := s.store.(searcher).Search(q, SearchFilter{})
+	s.NoError(err)
+	s.Empty(hits)
+	seen := 0
+	s.NoError(s.store.ForEach(func(string, entry) error {
+		seen++
+		return nil
+	}))
+	s.Equal(1, seen, "the trashed entries must not be visited")
+
+	trashed, err := t.ListTrash()
+	s.NoError(err)
+	s.Require().Len(trashed, 2)
+	s.Equal(otherBucketName, trashed[0].parentBucketName)
+	s.Equal(s.testBucketName, trashed[1].parentBucketName)
+	s.Equal(entries[0], trashed[1].entry)
+	s.True(deletedAt.Equal(trashed[1].deletedAt))
+
+	_, err = t.Untrash(otherBucketName, entries[1].ID)
+	s.IsType(DidError{}, err)
+	e, err := t.Untrash(s.testBucketName, entries[0].ID)
+	s.NoError(err)
+	s.Equal(entries[0], e)
+	_, err = t.Untrash(s.testBucketName, entries[0].ID)
+	s.IsType(DidError{}, err)
+	restored, err := s.store.GetRange(s.testBucketName, ts, ts)
+	s.NoError(err)
+	s.Equal(entries, restored, "the entry must keep its id")
+	hits, err = s.store.(searcher).Search(q, SearchFilter{})
+	s.NoError(err)
+	s.Len(hits, 1)
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("msg4")})
+	restored, err = s.store.GetRange(s.testBucketName, ts, ts)
+	s.NoError(err)
+	s.Len(restored, 3, "a new entry mustn't take the id of the restored one")
+
+	count, err := t.EmptyTrash()
+	s.NoError(err)
+	s.Equal(1, count)
+	trashed, err = t.ListTrash()
+	s.NoError(err)
+	s.Empty(trashed)
+	_, err = t.Untrash(otherBucketName, others[0].ID)
+	s.IsType(DidError{}, err)
+}
+
+func (s *storeTestSuite) TestBuckets() {
+	m := s.store.(bucketManager)
+	stats, err := m.Buckets()
+	s.NoError(err)
+	s.Empty(stats)
+
+	ts := timeFromString(s.T(), "2018-07-18T12:11:00Z")
+	otherBucketName := randString(10)
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("billing")})
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts.Add(time.Hour), Content: []byte("msg2")})
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts.AddDate(0, 0, 2), Content: []byte("msg3")})
+	putEntry(s.T(), s.store, otherBucketName, entry{Timestamp: ts, Content: []byte("msg4")})
+	entries, err := s.store.GetRange(s.testBucketName, ts, ts.AddDate(0, 0, 2))
+	s.Require().NoError(err)
+	s.Require().Len(entries, 3)
+	s.NoError(s.store.(trasher).Trash(s.testBucketName, entries[1].ID, ts))
+	s.NoError(s.store.Update(s.testBucketName, entry{ID: entries[0].ID, Content: []byte("billing edited")}))
+
+	stats, err = m.Buckets()
+	s.NoError(err)
+	s.Require().Len(stats, 2)
+	s.Less(stats[0].Name, stats[1].Name, "the buckets are listed by name")
+	byName := lo.KeyBy(stats, func(stat BucketStats) string { return stat.Name })
+	s.Require().Contains(byName, s.testBucketName)
+	s.Require().Contains(byName, otherBucketName)
+	s.Equal(2, byName[s.testBucketName].Entries)
+	s.Equal(2, byName[s.testBucketName].Days)
+	s.True(entries[0].Timestamp.Equal(byName[s.testBucketName].First))
+	s.True(entries[2].Timestamp.Equal(byName[s.testBucketName].Last))
+	other := byName[otherBucketName]
+	s.Equal(BucketStats{Name: otherBucketName, Entries: 1, Days: 1, First: other.First, Last: other.First}, other)
+
+	s.IsType(DidError{}, m.RenameBucket(s.testBucketName, otherBucketName))
+	s.IsType(DidError{}, m.RenameBucket(randString(10), randString(10)))
+	newName := randString(10)
+	s.NoError(m.RenameBucket(s.testBucketName, newName))
+	renamed, err := s.store.GetRange(newName, ts, ts.AddDate(0, 0, 2))
+	s.NoError(err)
+	s.Equal([]string{entries[0].ID, entries[2].ID}, []string{renamed[0].ID, renamed[1].ID}, "the entries must keep their ids")
+	old, err := s.store.GetRange(s.testBucketName, ts, ts.AddDate(0, 0, 2))
+	s.NoError(err)
+	s.Empty(old)
+	revisions, err := s.store.(historian).History(newName, entries[0].ID)
+	s.NoError(err)
+	s.Len(revisions, 1, "the history must follow")
+	trashed, err := s.store.(trasher).ListTrash()
+	s.NoError(err)
+	s.Require().Len(trashed, 1)
+	s.Equal(newName, trashed[0].parentBucketName, "the trash must follow")
+	_, err = s.store.(trasher).Untrash(newName, entries[1].ID)
+	s.NoError(err)
+	q, err := parseSearchQuery("billing")
+	s.Require().NoError(err)
+	hits, err := s.store.(searcher).Search(q, SearchFilter{})
+	s.NoError(err)
+	s.Require().Len(hits, 1)
+	s.Equal(newName, hits[0].parentBucketName)
+	putEntry(s.T(), s.store, newName, entry{Timestamp: ts, Content: []byte("msg5")})
+	renamed, err = s.store.GetRange(newName, ts, ts)
+	s.NoError(err)
+	s.Len(renamed, 3, "a new entry mustn't take the id of a renamed one")
+}
+
+func (s *storeTestSuite) TestNestedBuckets() {
+	m := s.store.(bucketManager)
+	ts := timeFromString(s.T(), "2018-07-18T12:11:00Z")
+	payments := s.testBucketName + "/payments"
+	refunds := payments + "/refunds"
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("msg1")})
+	putEntry(s.T(), s.store, payments, entry{Timestamp: ts, Content: []byte("msg2")})
+	putEntry(s.T(), s.store, refunds, entry{Timestamp: ts, Content: []byte("billing")})
+	_, err := s.store.Put(s.testBucketName+"//x", entry{Timestamp: ts, Content: []byte("msg")})
+	s.IsType(DidError{}, err)
+	_, err = s.store.Put(payments+"/", entry{Timestamp: ts, Content: []byte("msg")})
+	s.IsType(DidError{}, err)
+
+	for _, name := range []string{s.testBucketName, payments, refunds} {
+		entries, err := s.store.GetRange(name, ts, ts)
+		s.NoError(err)
+		s.Len(entries, 1, "the nested buckets keep their own entries")
+	}
+	children, err := m.ChildBuckets(s.testBucketName)
+	s.NoError(err)
+	s.Equal([]string{payments, refunds}, children)
+	children, err = m.ChildBuckets(refunds)
+	s.NoError(err)
+	s.Empty(children)
+	stats, err := m.Buckets()
+	s.NoError(err)
+	s.Equal([]string{s.testBucketName, payments, refunds}, lo.Map(stats, func(b BucketStats, _ int) string {
+		return b.Name
+	}))
+
+	q, err := parseSearchQuery("billing")
+	s.Require().NoError(err)
+	hits, err := s.store.(searcher).Search(q, SearchFilter{Bucket: s.testBucketName})
+	s.NoError(err)
+	s.Len(hits, 1, "the search filter includes the nested buckets")
+
+	entries, err := s.store.GetRange(refunds, ts, ts)
+	s.Require().NoError(err)
+	s.NoError(s.store.(trasher).Trash(refunds, entries[0].ID, ts))
+	newName := randString(10)
+	s.NoError(m.RenameBucket(payments, newName))
+	renamed, err := s.store.GetRange(newName, ts, ts)
+	s.NoError(err)
+	s.Len(renamed, 1)
+	children, err = m.ChildBuckets(s.testBucketName)
+	s.NoError(err)
+	s.Empty(children, "the nested buckets move along")
+	trashed, err := s.store.(trasher).ListTrash()
+	s.NoError(err)
+	s.Require().Len(trashed, 1)
+	s.Equal(newName+"/refunds", trashed[0].parentBucketName)
+	_, err = s.store.(trasher).Untrash(newName+"/refunds", entries[0].ID)
+	s.NoError(err)
+	children, err = m.ChildBuckets(newName)
+	s.NoError(err)
+	s.Equal([]string{newName + "/refunds"}, children)
+}
+
+func (s *storeTestSuite) TestMergeBucket() {
+	m := s.store.(bucketManager)
+	ts := timeFromString(s.T(), "2018-07-18T12:11:00Z")
+	dst := randString(10)
+	payments := s.testBucketName + "/payments"
+	taken := putEntry(s.T(), s.store, dst, entry{Timestamp: ts, Content: []byte("dst")})
+	dstTrashed := putEntry(s.T(), s.store, dst, entry{Timestamp: ts.Add(3 * time.Hour), Content: []byte("dst trashed")})
+	edited := putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("billing")})
+	kept := putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts.Add(time.Hour), Content: []byte("msg2")})
+	trashed := putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts.Add(2 * time.Hour), Content: []byte("msg3")})
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts.Add(3 * time.Hour), Content: []byte("msg4")})
+	nested := putEntry(s.T(), s.store, payments, entry{Timestamp: ts, Content: []byte("msg5")})
+	s.NoError(s.store.Update(s.testBucketName, entry{ID: edited, Content: []byte("billing edited")}))
+	s.NoError(s.store.(trasher).Trash(s.testBucketName, trashed, ts))
+	s.NoError(s.store.(trasher).Trash(dst, dstTrashed, ts))
+
+	_, err := m.MergeBucket(randString(10), dst)
+	s.IsType(DidError{}, err)
+	count, err := m.MergeBucket(s.testBucketName, dst)
+	s.NoError(err)
+	s.Equal(4, count)
+	merged, err := s.store.GetRange(dst, ts, ts)
+	s.NoError(err)
+	s.Require().Len(merged, 4)
+	byContent := lo.KeyBy(merged, func(e entry) string { return string(e.Content) })
+	s.Equal(taken, byContent["dst"].ID)
+	s.Equal(kept, byContent["msg2"].ID, "the entries must keep their ids")
+	moved := byContent["billing edited"].ID
+	s.NotEqual(taken, moved, "a taken id must be replaced")
+	s.NotEqual(dstTrashed, byContent["msg4"].ID, "the id of a trashed entry is taken too")
+	revisions, err := s.store.(historian).History(dst, moved)
+	s.NoError(err)
+	s.Require().Len(revisions, 1, "the history must follow")
+	s.Equal("billing", string(revisions[0].Content))
+	trashList, err := s.store.(trasher).ListTrash()
+	s.NoError(err)
+	s.Equal([]string{dst, dst}, lo.Map(trashList, func(t trashedEntry, _ int) string { return t.parentBucketName }),
+		"the trash must follow")
+	_, err = s.store.(trasher).Untrash(dst, trashed)
+	s.NoError(err)
+	_, err = s.store.(trasher).Untrash(dst, dstTrashed)
+	s.NoError(err)
+	nestedEntries, err := s.store.GetRange(dst+"/payments", ts, ts)
+	s.NoError(err)
+	s.Require().Len(nestedEntries, 1, "the nested buckets are merged to the same path")
+	s.Equal(nested, nestedEntries[0].ID)
+	old, err := s.store.GetRange(s.testBucketName, ts, ts.AddDate(0, 0, 1))
+	s.NoError(err)
+	s.Empty(old)
+	children, err := m.ChildBuckets(s.testBucketName)
+	s.NoError(err)
+	s.Empty(children)
+
+	q, err := parseSearchQuery("billing")
+	s.Require().NoError(err)
+	hits, err := s.store.(searcher).Search(q, SearchFilter{})
+	s.NoError(err)
+	s.Require().Len(hits, 1)
+	s.Equal(dst, hits[0].parentBucketName)
+	s.Equal(moved, hits[0].entry.ID)
+	putEntry(s.T(), s.store, dst, entry{Timestamp: ts, Content: []byte("msg6")})
+	merged, err = s.store.GetRange(dst, ts, ts)
+	s.NoError(err)
+	s.Len(merged, 7, "a new entry mustn't take the id of a merged one")
+}
+
+func (s *storeTestSuite) TestLastChange() {
+	u := s.store.(undoer)
+	c, err := u.LastChange()
+	s.NoError(err)
+	s.Nil(c)
+	expected := &change{Kind: ChangeEdit, Bucket: s.testBucketName, ID: "id", Previous: []byte("previous")}
+	s.NoError(u.SetLastChange(expected))
+	c, err = u.LastChange()
+	s.NoError(err)
+	s.Equal(expected, c)
+	s.NoError(u.SetLastChange(nil))
+	c, err = u.LastChange()
+	s.NoError(err)
+	s.Nil(c)
+}
+
+func (s *storeTestSuite) TestGet() {
+	ts := timeFromString(s.T(), "2018-07-18T12:11:00Z")
+	id := putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("msg1")})
+	entries, err := s.store.GetRange(s.testBucketName, ts, ts)
+	s.Require().NoError(err)
+	s.Require().Len(entries, 1)
+	s.Equal(id, entries[0].ID, "Put returns the id of the entry")
+
+	e, err := s.store.Get(s.testBucketName, entries[0].ID)
+	s.NoError(err)
+	s.Equal(entries[0], e)
+
+	_, err = s.store.Get(randString(10), entries[0].ID)
+	s.IsType(DidError{}, err)
+	_, err = s.store.Get(s.testBucketName, formatID(encodeKey(ts, 100)))
+	s.IsType(DidError{}, err)
+	_, err = s.store.Get(s.testBucketName, "bad")
+	s.IsType(DidError{}, err)
+}
+
+func (s *storeTestSuite) TestMetadata() {
+	ts := timeFromString(s.T(), "2018-07-18T12:11:00Z")
+	meta := Metadata{
+		Tags:     []string{"billing", "migration"},
+		Project:  "payments",
+		Duration: 2 * time.Hour,
+		Author:   "alice",
+		Extra:    map[string]string{"ticket": "PAY-12"},
+	}
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("msg1"), Metadata: meta})
+	entries, err := s.store.GetRange(s.testBucketName, ts, ts)
+	s.Require().NoError(err)
+	s.Require().Len(entries, 1)
+	s.Equal(meta, entries[0].Metadata)
+
+	updated := Metadata{Tags: []string{"billing"}}
+	s.NoError(s.store.Update(s.testBucketName, entry{ID: entries[0].ID, Content: []byte("msg2"), Metadata: updated}))
+	e, err := s.store.Get(s.testBucketName, entries[0].ID)
+	s.NoError(err)
+	s.Equal("msg2", string(e.Content))
+	s.Equal(updated, e.Metadata)
+}
+
+func (s *storeTestSuite) TestForEach() {
+	ts := timeFromString(s.T(), "2018-07-18T12:11:00Z")
+	otherBucketName := randString(10)
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("msg1")})
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts.AddDate(0, 0, 1), Content: []byte("msg2")})
+	putEntry(s.T(), s.store, otherBucketName, entry{Timestamp: ts, Content: []byte("msg3"), Metadata: Metadata{Project: "p"}})
+	seen := make(map[string][]string)
+	err := s.store.ForEach(func(parentBucketName string, e entry) error {
+		seen[parentBucketName] = append(seen[parentBucketName], string(e.Content)+e.Project)
+		return nil
+	})
+	s.NoError(err)
+	s.Equal(map[string][]string{
+		s.testBucketName: {"msg1", "msg2"},
+		otherBucketName:  {"msg3p"},
+	}, seen)
+
+	stop := errors.New("stop")
+	s.ErrorIs(s.store.ForEach(func(string, entry) error { return stop }), stop)
+}
+
+func (s *storeTestSuite) TestSearch() {
+	ts := timeFromString(s.T(), "2018-07-18T12:11:00Z")
+	otherBucketName := randString(10)
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("Reviewed the billing migration")})
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts.Add(time.Hour), Content: []byte("Migration of the billing tables, billing is hard")})
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts.AddDate(0, 0, 1), Content: []byte("Deployed"), Metadata: Metadata{Tags: []string{"billing"}}})
+	putEntry(s.T(), s.store, otherBucketName, entry{Timestamp: ts.AddDate(0, 0, 2), Content: []byte("Paid the billing")})
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts.AddDate(0, 0, 3), Content: []byte("Standup")})
+
+	testCases := []struct {
+		query    string
+		filter   SearchFilter
+		expected []string
+	}{
+		{
+			query:    "billing",
+			expected: []string{"Migration of the billing tables, billing is hard", "Paid the billing", "Deployed", "Reviewed the billing migration"},
+		},
+		{
+			query:    "billing",
+			filter:   SearchFilter{Bucket: s.testBucketName},
+			expected: []string{"Migration of the billing tables, billing is hard", "Deployed", "Reviewed the billing migration"},
+		},
+		{
+			query:    "billing",
+			filter:   SearchFilter{From: ts.AddDate(0, 0, 1), To: ts.AddDate(0, 0, 2)},
+			expected: []string{"Paid the billing", "Deployed"},
+		},
+		{
+			query:    "MIGRATION billing",
+			expected: []string{"Migration of the billing tables, billing is hard", "Reviewed the billing migration"},
+		},
+		{
+			query:    `"billing migration"`,
+			expected: []string{"Reviewed the billing migration"},
+		},
+		{
+			query:    "dep*",
+			expected: []string{"Deployed"},
+		},
+		{
+			query:    "nothing",
+			expected: []string{},
+		},
+	}
+	for _, tc := range testCases {
+		q, err := parseSearchQuery(tc.query)
+		s.Require().NoError(err)
+		hits, err := s.store.(searcher).Search(q, tc.filter)
+		s.Require().NoError(err)
+		actual := make([]string, 0)
+		for _, hit := range rankHits(hits, 0) {
+			actual = append(actual, string(hit.entry.Content))
+		}
+		s.Equal(tc.expected, actual, tc.query)
+	}
+
+	q, err := parseSearchQuery("billing")
+	s.Require().NoError(err)
+	hits, err := s.store.(searcher).Search(q, SearchFilter{Bucket: otherBucketName})
+	s.Require().NoError(err)
+	s.Require().Len(hits, 1)
+	s.Equal(otherBucketName, hits[0].parentBucketName)
+	s.NoError(s.store.Update(otherBucketName, entry{ID: hits[0].entry.ID, Content: []byte("Paid the rent")}))
+	s.NoError(s.store.Delete(s.testBucketName, formatID(encodeKey(ts, 1))))
+	hits, err = s.store.(searcher).Search(q, SearchFilter{})
+	s.Require().NoError(err)
+	s.Len(hits, 2)
+}
+
+func (s *storeTestSuite) TestBackupRestore() {
+	ts := timeFromString(s.T(), "2018-07-18T12:11:00Z")
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("msg1")})
+	b, ok := s.store.(backuper)
+	if !ok {
+		s.T().Skip("the store doesn't support backups")
+	}
+	snapshot := filepath.Join(s.T().TempDir(), "snapshot")
+	s.Require().NoError(b.Backup(snapshot))
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts.Add(time.Hour), Content: []byte("msg2")})
+
+	garbage := filepath.Join(s.T().TempDir(), "garbage")
+	s.Require().NoError(os.WriteFile(garbage, []byte(strings.Repeat("garbage", 1000)), 0600))
+	for _, path := range []string{garbage, garbage + "-missing"} {
+		s.IsType(DidError{}, b.Restore(path), path)
+		entries, err := s.store.GetRange(s.testBucketName, ts, ts)
+		s.NoError(err)
+		s.Len(entries, 2, "an invalid snapshot must leave the store untouched")
+	}
+
+	s.Require().NoError(b.Restore(snapshot))
+	entries, err := s.store.GetRange(s.testBucketName, ts, ts)
+	s.NoError(err)
+	requireEntriesEqual(s.T(), []entry{{Timestamp: ts, Content: []byte("msg1")}}, entries)
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts.Add(time.Hour), Content: []byte("msg2")})
+	entries, err = s.store.GetRange(s.testBucketName, ts, ts)
+	s.NoError(err)
+	s.Len(entries, 2)
+}
+
+func (s *storeTestSuite) TestZoneIsKept() {
+	bucharest, err := time.LoadLocation("Europe/Bucharest")
+	s.Require().NoError(err)
+	ts := timeFromString(s.T(), "2018-07-18T12:11:00Z").In(bucharest)
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("msg1")})
+	entries, err := s.store.GetRange(s.testBucketName, ts, ts)
+	s.Require().NoError(err)
+	s.Require().Len(entries, 1)
+	s.True(ts.Equal(entries[0].Timestamp))
+	s.Equal("2018-07-18 15:11:00 +0300 EEST", entries[0].Timestamp.String())
+
+	s.NoError(s.store.Update(s.testBucketName, entry{ID: entries[0].ID, Content: []byte("msg2")}))
+	entries, err = s.store.GetRange(s.testBucketName, ts, ts)
+	s.Require().NoError(err)
+	s.Require().Len(entries, 1)
+	s.Equal("2018-07-18 15:11:00 +0300 EEST", entries[0].Timestamp.String())
+}
+
+func (s *storeTestSuite) TestHomeTimezone() {
+	s.store.Close()
+	s.cleanup()
+	s.store = s.newStore(config{HomeTimezone: "America/Los_Angeles"})
+	losAngeles, err := time.LoadLocation("America/Los_Angeles")
+	s.Require().NoError(err)
+
+	// logged while travelling, the evening of the 17th back home
+	ts := timeFromString(s.T(), "2018-07-18T03:00:00Z").In(time.FixedZone("JST", 9*60*60))
+	putEntry(s.T(), s.store, s.testBucketName, entry{Timestamp: ts, Content: []byte("msg1")})
+
+	testCases := []struct {
+		day      time.Time
+		expected int
+	}{
+		{day: timeFromString(s.T(), "2018-07-17T12:00:00Z").In(losAngeles), expected: 1},
+		{day: timeFromString(s.T(), "2018-07-18T12:00:00Z").In(losAngeles), expected: 0},
+		{day: timeFromString(s.T(), "2018-07-18T12:00:00Z"), expected: 1},
+		{day: timeFromString(s.T(), "2018-07-17T12:00:00Z"), expected: 0},
+		{day: timeFromString(s.T(), "2018-07-18T12:00:00Z").In(time.FixedZone("JST", 9*60*60)), expected: 1},
+	}
+	for _, tc := range testCases {
+		entries, err := s.store.GetRange(s.testBucketName, tc.day, tc.day)
+		s.NoError(err)
+		s.Len(entries, tc.expected, tc.day.String())
+	}
+	entries, err := s.store.GetRange(s.testBucketName, ts, ts)
+	s.Require().NoError(err)
+	s.Require().Len(entries, 1)
+	s.Equal(ts.String(), entries[0].Timestamp.String())
+}
@@ -0,0 +1,225 @@
+package godid
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+
+	homedir "github.com/mitchellh/go-homedir"
+)
+
+// sqliteHeader starts every sqlite file, telling them apart from the bolt ones
+var sqliteHeader = []byte("SQLite format 3\x00")
+
+// SyncResult reports what Sync changed
+type SyncResult struct {
+	// Added is the number of entries copied from the other store
+	Added int
+	// Duplicates is the number of entries of the other store already present, in the store or in its archive
+	Duplicates int
+	// Deleted is the number of entries of the other store left out because they were deleted from this one and are
+	// still in its trash
+	Deleted int
+	// Conflicts lists the entries logged at the same time in the same bucket of both stores, but differing
+	Conflicts []SyncConflict
+}
+
+// SyncConflict is a pair of differing entries logged at the same time in the same bucket, the local one being kept
+type SyncConflict struct {
+	Bucket string
+	Local  Entry
+	Other  Entry
+}
+
+// syncKey groups the entries logged in the same second in the same bucket, the Markdown journals keeping seconds only.
+// Within a group the entries are paired by their ID, sync copying them along, then by their content for the entries
+// that couldn't keep their ID, e.g. in a journal, or were copied before sync kept them.
+type syncKey struct {
+	parentBucketName string
+	timestamp        int64
+}
+
+func newSyncKey(parentBucketName string, e entry) syncKey {
+	return syncKey{parentBucketName: parentBucketName, timestamp: e.Timestamp.Unix()}
+}
+
+// syncStores copies the entries of src missing from dst into it. The entries moved to arch, which can be nil, count
+// as present, and the ones in the trash of dst aren't brought back.
+func syncStores(dst, src, arch entryStore) (SyncResult, error) {
+	result := SyncResult{Conflicts: make([]SyncConflict, 0)}
+	local := make(map[syncKey][]entry)
+	for _, s := range []entryStore{dst, arch} {
+		if s == nil {
+			continue
+		}
+		err := s.ForEach(func(parentBucketName string, e entry) error {
+			k := newSyncKey(parentBucketName, e)
+			local[k] = append(local[k], e)
+			return nil
+		})
+		if err != nil {
+			return result, err
+		}
+	}
+	deleted := make(map[syncKey][]entry)
+	if t, ok := dst.(trasher); ok {
+		trashed, err := t.ListTrash()
+		if err != nil {
+			return result, err
+		}
+		for _, e := range trashed {
+			k := newSyncKey(e.parentBucketName, e.entry)
+			deleted[k] = append(deleted[k], e.entry)
+		}
+	}
+	// the other store is read in full first, so it's not held open while this one gets written
+	others := make(map[syncKey][]entry)
+	keys := make([]syncKey, 0)
+	err := src.ForEach(func(parentBucketName string, e entry) error {
+		k := newSyncKey(parentBucketName, e)
+		if _, ok := others[k]; !ok {
+			keys = append(keys, k)
+		}
+		others[k] = append(others[k], e)
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	// several entries can share a key, the ones with the same ID are paired first, differing when edited in one of the
+	// stores. Then the identical ones are paired, then the deleted ones, the differing ones left in both stores are
+	// conflicts and the rest is missing from this store.
+	for _, k := range keys {
+		unmatched := append([]entry(nil), local[k]...)
+		rest := make([]entry, 0)
+		for _, e := range others[k] {
+			if i := indexOfID(unmatched, e.ID); i >= 0 {
+				if isSameEntry(unmatched[i], e) {
+					result.Duplicates++
+				} else {
+					result.Conflicts = append(result.Conflicts, SyncConflict{
+						Bucket: k.parentBucketName,
+						Local:  unmatched[i].public(),
+						Other:  e.public(),
+					})
+				}
+				unmatched = append(unmatched[:i], unmatched[i+1:]...)
+			} else if i := indexOfID(deleted[k], e.ID); i >= 0 {
+				deleted[k] = append(deleted[k][:i], deleted[k][i+1:]...)
+				result.Deleted++
+			} else {
+				rest = append(rest, e)
+			}
+		}
+		missing := make([]entry, 0)
+		for _, e := range rest {
+			if i := indexOfSameEntry(unmatched, e); i >= 0 {
+				unmatched = append(unmatched[:i], unmatched[i+1:]...)
+				result.Duplicates++
+			} else if i := indexOfSameEntry(deleted[k], e); i >= 0 {
+				deleted[k] = append(deleted[k][:i], deleted[k][i+1:]...)
+				result.Deleted++
+			} else {
+				missing = append(missing, e)
+			}
+		}
+		for i, e := range missing {
+			if i < len(unmatched) {
+				result.Conflicts = append(result.Conflicts, SyncConflict{
+					Bucket: k.parentBucketName,
+					Local:  unmatched[i].public(),
+					Other:  e.public(),
+				})
+				continue
+			}
+			if _, err := dst.Put(k.parentBucketName, e); err != nil {
+				return result, err
+			}
+			result.Added++
+		}
+	}
+	return result, nil
+}
+
+func indexOfSameEntry(entries []entry, e entry) int {
+	for i, candidate := range entries {
+		if isSameEntry(candidate, e) {
+			return i
+		}
+	}
+	return -1
+}
+
+func indexOfID(entries []entry, id string) int {
+	if id == "" {
+		return -1
+	}
+	for i, candidate := range entries {
+		if candidate.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func isSameEntry(a, b entry) bool {
+	return bytes.Equal(a.Content, b.Content) && reflect.DeepEqual(a.Metadata, b.Metadata)
+}
+
+// openSyncSource opens the store at path read only, telling the backend from the content of the file, directories
+// being Markdown journals
+func openSyncSource(cfg config, path string) (entryStore, error) {
+	path, err := homedir.Expand(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, didErrorf("no store at %s", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	storePath, err := cfg.GetStorePath()
+	if err != nil {
+		return nil, err
+	}
+	if own, err := os.Stat(storePath); err == nil && os.SameFile(own, info) {
+		return nil, didErrorf("can't sync the store with itself")
+	}
+	cfg.StorePath = path
+	cfg.Backend, err = detectBackend(path, info)
+	if err != nil {
+		return nil, err
+	}
+	s, err := openStore(cfg, true)
+	if errors.Is(err, errReadOnly) {
+		return nil, didErrorf("the store %s uses an older format, run did migrate on it first", path)
+	}
+	if _, ok := err.(DidError); err != nil && !ok {
+		return nil, didErrorf("invalid store %s: %v", path, err)
+	}
+	return s, err
+}
+
+func detectBackend(path string, info os.FileInfo) (string, error) {
+	if info.IsDir() {
+		return backendMarkdown, nil
+	}
+	header := make([]byte, len(sqliteHeader))
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	_, err = io.ReadFull(f, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	if bytes.Equal(header, sqliteHeader) {
+		return backendSQLite, nil
+	}
+	return backendBolt, nil
+}
@@ -0,0 +1,152 @@
+package godid
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncStores(t *testing.T) {
+	ts := timeFromString(t, "2018-07-18T12:11:00Z")
+	local := getTestMemoryStore(t, config{})
+	other := getTestMemoryStore(t, config{})
+	putEntry(t, local, "root", entry{Timestamp: ts, Content: []byte("both")})
+	putEntry(t, other, "root", entry{Timestamp: ts, Content: []byte("both")})
+	putEntry(t, local, "root", entry{Timestamp: ts.Add(1 * time.Second), Content: []byte("edited on the laptop")})
+	putEntry(t, other, "root", entry{Timestamp: ts.Add(1 * time.Second), Content: []byte("edited on the desktop")})
+	putEntry(t, local, "root", entry{Timestamp: ts.Add(2 * time.Second), Content: []byte("laptop")})
+	putEntry(t, other, "root", entry{Timestamp: ts.Add(3 * time.Second), Content: []byte("desktop"), Metadata: Metadata{Tags: []string{"x"}}})
+	putEntry(t, other, "work", entry{Timestamp: ts, Content: []byte("both")})
+	for _, content := range []string{"same second 1", "same second 2"} {
+		putEntry(t, other, "root", entry{Timestamp: ts.Add(4 * time.Second), Content: []byte(content)})
+	}
+
+	result, err := syncStores(local, other, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 4, result.Added)
+	assert.Equal(t, 1, result.Duplicates)
+	require.Len(t, result.Conflicts, 1)
+	assert.Equal(t, "root", result.Conflicts[0].Bucket)
+	assert.Equal(t, "edited on the laptop", result.Conflicts[0].Local.Content)
+	assert.Equal(t, "edited on the desktop", result.Conflicts[0].Other.Content)
+
+	entries, err := local.GetRange("root", ts, ts)
+	require.NoError(t, err)
+	assert.Len(t, entries, 6)
+	assert.Equal(t, "desktop", string(entries[3].Content))
+	assert.Equal(t, []string{"x"}, entries[3].Tags)
+	assert.True(t, ts.Add(3*time.Second).Equal(entries[3].Timestamp))
+
+	result, err = syncStores(local, other, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.Added, "syncing again should change nothing")
+	assert.Equal(t, 5, result.Duplicates)
+	assert.Len(t, result.Conflicts, 1)
+
+	result, err = syncStores(other, local, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Added)
+	assert.Len(t, result.Conflicts, 1)
+}
+
+func TestSyncStoresAfterDelete(t *testing.T) {
+	ts := timeFromString(t, "2018-07-18T12:11:00Z")
+	local := getTestMemoryStore(t, config{})
+	other := getTestMemoryStore(t, config{})
+	arch := getTestMemoryStore(t, config{})
+	for _, content := range []string{"kept", "deleted", "archived"} {
+		putEntry(t, other, "root", entry{Timestamp: ts, Content: []byte(content)})
+	}
+	result, err := syncStores(local, other, arch)
+	require.NoError(t, err)
+	require.Equal(t, 3, result.Added)
+
+	entries, err := local.GetRange("root", ts, ts)
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	require.NoError(t, local.Trash("root", entries[1].ID, ts.Add(time.Hour)))
+	_, err = archiveEntries(arch, local, []bucketEntry{{parentBucketName: "root", entry: entries[2]}})
+	require.NoError(t, err)
+
+	result, err = syncStores(local, other, arch)
+	require.NoError(t, err)
+	assert.Equal(t, SyncResult{Duplicates: 2, Deleted: 1, Conflicts: []SyncConflict{}}, result,
+		"the deleted and archived entries must not come back")
+	entries, err = local.GetRange("root", ts, ts)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestSyncStoresByID(t *testing.T) {
+	ts := timeFromString(t, "2018-07-18T12:11:00Z")
+	local := getTestMemoryStore(t, config{})
+	other := getTestMemoryStore(t, config{})
+	first := putEntry(t, other, "root", entry{Timestamp: ts, Content: []byte("standup")})
+	second := putEntry(t, other, "root", entry{Timestamp: ts, Content: []byte("standup")})
+	result, err := syncStores(local, other, nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Added)
+	_, err = local.Get("root", first)
+	require.NoError(t, err, "the copies keep their ids")
+
+	require.NoError(t, local.Trash("root", second, ts.Add(time.Hour)))
+	require.NoError(t, other.Update("root", entry{ID: first, Timestamp: ts, Content: []byte("standup and planning")}))
+	result, err = syncStores(local, other, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.Added, "the edited entry isn't a new one")
+	assert.Equal(t, 1, result.Deleted)
+	require.Len(t, result.Conflicts, 1)
+	assert.Equal(t, first, result.Conflicts[0].Local.ID)
+	assert.Equal(t, "standup and planning", result.Conflicts[0].Other.Content)
+}
+
+func TestSyncStoresSecondPrecision(t *testing.T) {
+	defer cleanupTestMarkdownStore()
+	ts := timeFromString(t, "2018-07-18T12:11:00Z").Add(123 * time.Millisecond)
+	journal := getTestMarkdownStore(t, config{})
+	other := getTestMemoryStore(t, config{})
+	putEntry(t, other, "root", entry{Timestamp: ts, Content: []byte("msg1")})
+
+	result, err := syncStores(journal, other, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Added)
+	result, err = syncStores(journal, other, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.Added, "the journal keeping seconds shouldn't make the entry look new")
+	assert.Equal(t, 1, result.Duplicates)
+}
+
+func TestOpenSyncSource(t *testing.T) {
+	defer cleanupTestBoltStore()
+	defer cleanupTestSQLiteStore()
+	defer cleanupTestMarkdownStore()
+	ts := timeFromString(t, "2018-07-18T12:11:00Z")
+	own := getTestBoltStore(t, config{})
+	defer own.Close()
+	other := getTestSQLiteStore(t, config{})
+	putEntry(t, other, "root", entry{Timestamp: ts, Content: []byte("msg1")})
+	require.NoError(t, other.Close())
+	cfg := config{StorePath: "test.db"}
+
+	s, err := openSyncSource(cfg, "test.sqlite")
+	require.NoError(t, err)
+	assert.IsType(t, &sqliteStore{}, s)
+	require.NoError(t, s.Close())
+
+	getTestMarkdownStore(t, config{})
+	s, err = openSyncSource(cfg, "test-journal")
+	require.NoError(t, err)
+	assert.IsType(t, &markdownStore{}, s)
+
+	_, err = openSyncSource(cfg, "test.db")
+	assert.IsType(t, DidError{}, err)
+	_, err = openSyncSource(cfg, "missing.db")
+	assert.IsType(t, DidError{}, err)
+
+	require.NoError(t, os.WriteFile("test.sqlite", []byte("not a store"), 0600))
+	_, err = openSyncSource(cfg, "test.sqlite")
+	assert.IsType(t, DidError{}, err)
+}
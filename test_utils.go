@@ -1,7 +1,9 @@
 package godid
 
 import (
+	"context"
 	"math/rand"
+	"net"
 	"os"
 	"testing"
 	"time"
@@ -23,14 +25,95 @@ func randString(n int) string {
 	return string(b)
 }
 
-func getTestBoltStore(t *testing.T) *boltStore {
+func getTestBoltStore(t testing.TB, cfg config) *boltStore {
 	cleanupTestBoltStore()
-	var err error
-	testStore, err := newBoltStore(config{StorePath: "test.db"})
+	cfg.StorePath = "test.db"
+	testStore, err := newBoltStore(cfg, false)
 	require.NoError(t, err)
 	return testStore
 }
 
 func cleanupTestBoltStore() {
 	os.Remove("test.db")
+	os.Remove(lockInfoPath("test.db"))
+}
+
+func getTestSQLiteStore(t testing.TB, cfg config) *sqliteStore {
+	cleanupTestSQLiteStore()
+	cfg.StorePath = "test.sqlite"
+	cfg.Backend = backendSQLite
+	testStore, err := newSQLiteStore(cfg, false)
+	require.NoError(t, err)
+	return testStore
+}
+
+func cleanupTestSQLiteStore() {
+	os.Remove("test.sqlite")
+}
+
+func getTestMemoryStore(t testing.TB, cfg config) *memoryStore {
+	cfg.Backend = backendMemory
+	testStore, err := newMemoryStore(cfg)
+	require.NoError(t, err)
+	return testStore
+}
+
+func getTestMarkdownStore(t testing.TB, cfg config) *markdownStore {
+	cleanupTestMarkdownStore()
+	cfg.StorePath = "test-journal"
+	cfg.Backend = backendMarkdown
+	testStore, err := newMarkdownStore(cfg)
+	require.NoError(t, err)
+	return testStore
+}
+
+func cleanupTestMarkdownStore() {
+	os.RemoveAll("test-journal")
+}
+
+// testRemoteStore talks to a daemon serving a bolt store, closing it stops both
+type testRemoteStore struct {
+	*remoteStore
+	stop func()
+}
+
+func (s testRemoteStore) Close() error {
+	err := s.remoteStore.Close()
+	s.stop()
+	return err
+}
+
+func getTestRemoteStore(t testing.TB, cfg config) testRemoteStore {
+	served := getTestBoltStore(t, cfg)
+	home, err := cfg.GetLocation()
+	require.NoError(t, err)
+	l, err := net.Listen("unix", "test.sock")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- newDaemon(served, home).serve(ctx, l)
+	}()
+	conn, err := net.Dial("unix", "test.sock")
+	require.NoError(t, err)
+	return testRemoteStore{
+		remoteStore: newRemoteStore(conn, home),
+		stop: func() {
+			cancel()
+			require.NoError(t, <-done)
+			served.Close()
+		},
+	}
+}
+
+func cleanupTestRemoteStore() {
+	cleanupTestBoltStore()
+	os.Remove("test.sock")
+}
+
+// putEntry stores the entry, failing the test when it can't, and returns its id
+func putEntry(t testing.TB, s entryStore, parentBucketName string, e entry) string {
+	id, err := s.Put(parentBucketName, e)
+	require.NoError(t, err)
+	return id
 }
@@ -0,0 +1,138 @@
+package godid
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The time expressions accepted when logging an entry at a given time, case insensitive:
+//
+//   - now
+//   - a time of today, e.g. 09:30 or 17:45:10
+//   - a date expression followed by a time, e.g. yesterday 17:30, last friday at 9:00 or 2026-10-01 12:00
+//   - a timestamp, e.g. 2026-10-01T12:00:00+03:00, in the timezone of the queries when it has no offset
+//   - a duration ago, e.g. 2h ago, 1h30m ago, 45 minutes ago or an hour ago
+//   - a date expression alone, standing for that day at the current time, e.g. yesterday or 3 days ago
+
+var (
+	// timestampLayouts are the layouts of the full timestamps, the ones without an offset being read in the timezone
+	// of the queries
+	timestampLayouts = []string{
+		time.RFC3339,
+		"2006-01-02T15:04:05",
+		"2006-01-02T15:04",
+		"2006-01-02 15:04:05Z07:00",
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04",
+	}
+
+	clockPattern         = regexp.MustCompile(`^(\d{1,2}):(\d{2})(?::(\d{2}))?$`)
+	durationAgoPattern   = regexp.MustCompile(`^((?:\d+(?:\.\d+)?[hms])+) ago$`)
+	clockUnitsAgoPattern = regexp.MustCompile(`^(\d+|an?) (second|minute|hour)s? ago$`)
+	// leadingTimePattern matches a line starting with a time of today or a timestamp, followed by the text
+	leadingTimePattern = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}[T ]\d{1,2}:\d{2}(?::\d{2})?(?:Z|[+-]\d{2}:\d{2})?|` +
+		`\d{1,2}:\d{2}(?::\d{2})?)\s+(\S.*)$`)
+
+	clockUnits = map[string]time.Duration{
+		"second": time.Second,
+		"minute": time.Minute,
+		"hour":   time.Hour,
+	}
+)
+
+// ParseTime returns the time the time expression stands for, in the timezone of the queries unless it carries an
+// offset
+func ParseTime(expr string) (time.Time, error) {
+	return parseTime(expr, now())
+}
+
+// CutTimestamp splits a line starting with a time of today, e.g. 09:30, or with a timestamp, e.g. 2026-10-01 12:00,
+// into that time and the rest of the line. The time is zero for the lines that don't start with one, the ones starting
+// with something that only looks like a time, e.g. 25:00, being left whole.
+func CutTimestamp(line string) (time.Time, string) {
+	return cutTimestamp(line, now())
+}
+
+// parseTime returns the time the time expression stands for, relative to now and in its location
+func parseTime(expr string, now time.Time) (time.Time, error) {
+	trimmed := strings.TrimSpace(expr)
+	for _, layout := range timestampLayouts {
+		if t, err := time.ParseInLocation(layout, strings.ToUpper(trimmed), now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	invalid := didErrorf("invalid time %q", expr)
+	normalized := normalizeDateExpr(expr)
+	if normalized == "now" {
+		return now, nil
+	}
+	if match := durationAgoPattern.FindStringSubmatch(normalized); match != nil {
+		d, err := time.ParseDuration(match[1])
+		if err != nil {
+			return time.Time{}, invalid
+		}
+		return now.Add(-d), nil
+	}
+	if match := clockUnitsAgoPattern.FindStringSubmatch(normalized); match != nil {
+		count := 1
+		if match[1] != "a" && match[1] != "an" {
+			var err error
+			if count, err = strconv.Atoi(match[1]); err != nil {
+				return time.Time{}, invalid
+			}
+		}
+		return now.Add(-time.Duration(count) * clockUnits[match[2]]), nil
+	}
+	words := strings.Fields(normalized)
+	if len(words) == 0 {
+		return time.Time{}, invalid
+	}
+	match := clockPattern.FindStringSubmatch(words[len(words)-1])
+	if match == nil {
+		day, err := ParseDateAt(normalized, now)
+		if err != nil {
+			return time.Time{}, invalid
+		}
+		return atClock(day, now.Hour(), now.Minute(), now.Second()), nil
+	}
+	hour, _ := strconv.Atoi(match[1])
+	minute, _ := strconv.Atoi(match[2])
+	second, _ := strconv.Atoi(match[3])
+	if hour > 23 || minute > 59 || second > 59 {
+		return time.Time{}, invalid
+	}
+	dayWords := words[:len(words)-1]
+	if len(dayWords) > 0 && dayWords[len(dayWords)-1] == "at" {
+		dayWords = dayWords[:len(dayWords)-1]
+	}
+	day := startOfDay(now)
+	if len(dayWords) > 0 {
+		var err error
+		if day, err = ParseDateAt(strings.Join(dayWords, " "), now); err != nil {
+			return time.Time{}, invalid
+		}
+	}
+	return atClock(day, hour, minute, second), nil
+}
+
+// cutTimestamp splits a line starting with a time of today or with a timestamp into that time, relative to now, and
+// the rest of the line
+func cutTimestamp(line string, now time.Time) (time.Time, string) {
+	match := leadingTimePattern.FindStringSubmatch(strings.TrimSpace(line))
+	if match == nil {
+		return time.Time{}, line
+	}
+	t, err := parseTime(match[1], now)
+	if err != nil {
+		// the line goes on with some text, not logged at a time
+		return time.Time{}, line
+	}
+	return t, match[2]
+}
+
+// atClock returns the time of day on the date of day, in its location
+func atClock(day time.Time, hour, minute, second int) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), hour, minute, second, 0, day.Location())
+}
@@ -0,0 +1,92 @@
+package godid
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTime(t *testing.T) {
+	bucharest, err := time.LoadLocation("Europe/Bucharest")
+	require.NoError(t, err)
+	// the reference is Sunday, October 18th 2026, 14:30:15 in Bucharest
+	now, err := time.Parse(time.RFC3339, "2026-10-18T14:30:15+03:00")
+	require.NoError(t, err)
+	now = now.In(bucharest)
+
+	testCases := []struct {
+		name        string
+		expr        string
+		expected    string
+		shouldError bool
+	}{
+		{name: "now", expr: "Now", expected: "2026-10-18T14:30:15+03:00"},
+		{name: "clock", expr: "09:30", expected: "2026-10-18T09:30:00+03:00"},
+		{name: "clock with seconds", expr: "9:05:10", expected: "2026-10-18T09:05:10+03:00"},
+		{name: "day and clock", expr: "yesterday 17:30", expected: "2026-10-17T17:30:00+03:00"},
+		{name: "day at clock", expr: "last friday at 9:00", expected: "2026-10-16T09:00:00+03:00"},
+		{name: "at clock", expr: "at 12:00", expected: "2026-10-18T12:00:00+03:00"},
+		{name: "date and clock in winter", expr: "2026-12-01 08:00", expected: "2026-12-01T08:00:00+02:00"},
+		{name: "timestamp", expr: "2026-10-01T12:00:00", expected: "2026-10-01T12:00:00+03:00"},
+		{name: "timestamp with offset", expr: "2026-10-01T12:00:00Z", expected: "2026-10-01T12:00:00Z"},
+		{name: "timestamp with space and offset", expr: "2026-10-01 12:00:00+01:00", expected: "2026-10-01T12:00:00+01:00"},
+		{name: "duration ago", expr: "2h ago", expected: "2026-10-18T12:30:15+03:00"},
+		{name: "combined duration ago", expr: "1h30m ago", expected: "2026-10-18T13:00:15+03:00"},
+		{name: "minutes ago", expr: "45 minutes ago", expected: "2026-10-18T13:45:15+03:00"},
+		{name: "an hour ago", expr: "an hour ago", expected: "2026-10-18T13:30:15+03:00"},
+		{name: "day alone", expr: "3 days ago", expected: "2026-10-15T14:30:15+03:00"},
+		{name: "first day of a period", expr: "last week", expected: "2026-10-05T14:30:15+03:00"},
+		{name: "bad hour", expr: "24:00", shouldError: true},
+		{name: "bad minute", expr: "yesterday 10:60", shouldError: true},
+		{name: "bad day", expr: "someday 10:00", shouldError: true},
+		{name: "empty", expr: " ", shouldError: true},
+		{name: "gibberish", expr: "soon", shouldError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			at, err := parseTime(tc.expr, now)
+			if tc.shouldError {
+				require.IsType(t, DidError{}, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, at.Format(time.RFC3339))
+		})
+	}
+}
+
+func TestCutTimestamp(t *testing.T) {
+	now, err := time.Parse(time.RFC3339, "2026-10-18T14:30:15Z")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		line     string
+		expected string
+		rest     string
+	}{
+		{name: "no time", line: "reviewed the billing migration", rest: "reviewed the billing migration"},
+		{name: "clock", line: "09:30 standup", expected: "2026-10-18T09:30:00Z", rest: "standup"},
+		{name: "clock alone", line: "09:30", rest: "09:30"},
+		{name: "date and clock", line: "2026-10-17 17:45:10  fixed the flaky test", expected: "2026-10-17T17:45:10Z", rest: "fixed the flaky test"},
+		{name: "timestamp", line: "2026-10-17T17:45:10+02:00 deployed", expected: "2026-10-17T17:45:10+02:00", rest: "deployed"},
+		{name: "date alone", line: "2026-10-17 was a good day", rest: "2026-10-17 was a good day"},
+		{name: "time in the text", line: "moved the meeting to 10:00", rest: "moved the meeting to 10:00"},
+		{name: "bad clock", line: "25:00 late night", rest: "25:00 late night"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			at, rest := cutTimestamp(tc.line, now)
+			assert.Equal(t, tc.rest, rest)
+			if tc.expected == "" {
+				assert.True(t, at.IsZero())
+				return
+			}
+			assert.Equal(t, tc.expected, at.Format(time.RFC3339))
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package godid
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+const (
+	// trashBucketName holds the trashed entries under their parent bucket, keyed like in the day buckets
+	trashBucketName = reservedBucketPrefix + "trash"
+	lastChangeKey   = "last_change"
+)
+
+// The kinds of Change
+const (
+	ChangeAdd          = "add"
+	ChangeEdit         = "edit"
+	ChangeDelete       = "delete"
+	ChangeDeleteBucket = "delete_bucket"
+)
+
+// trashedEntry is an entry kept in the trash
+type trashedEntry struct {
+	parentBucketName string
+	entry
+	deletedAt time.Time
+}
+
+func (t trashedEntry) public() TrashedEntry {
+	return TrashedEntry{
+		Entry:     t.entry.public(),
+		Bucket:    t.parentBucketName,
+		DeletedAt: t.deletedAt,
+	}
+}
+
+// trashRecord is the stored form of a trashed entry, for the stores without a table for them
+type trashRecord struct {
+	Bucket    string    `json:"bucket"`
+	ID        string    `json:"id"`
+	DeletedAt time.Time `json:"deleted_at"`
+	// Value is the entry as it was stored, see encodeValue
+	Value []byte `json:"value"`
+}
+
+func encodeTrashRecord(parentBucketName string, key, v []byte, deletedAt time.Time) ([]byte, error) {
+	return json.Marshal(trashRecord{
+		Bucket:    parentBucketName,
+		ID:        formatID(key),
+		DeletedAt: deletedAt,
+		Value:     v,
+	})
+}
+
+func decodeTrashRecord(v []byte) (trashRecord, error) {
+	var r trashRecord
+	err := json.Unmarshal(v, &r)
+	return r, err
+}
+
+func (r trashRecord) trashed(home *time.Location) (trashedEntry, error) {
+	key, err := parseID(r.ID)
+	if err != nil {
+		return trashedEntry{}, err
+	}
+	e, err := decodeEntry(key, r.Value, home)
+	if err != nil {
+		return trashedEntry{}, err
+	}
+	return trashedEntry{parentBucketName: r.Bucket, entry: e, deletedAt: r.DeletedAt}, nil
+}
+
+// sortTrash puts the most recently deleted entries first
+func sortTrash(entries []trashedEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].deletedAt.After(entries[j].deletedAt)
+	})
+}
+
+// change is the last change made to the store, as kept to undo it
+type change struct {
+	Kind   string `json:"kind"`
+	Bucket string `json:"bucket"`
+	// ID identifies the added, edited or deleted entry
+	ID        string    `json:"id,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	Content   string    `json:"content,omitempty"`
+	// Previous is the edited entry as it was before the edit, see encodeValue
+	Previous []byte `json:"previous,omitempty"`
+	// Deleted maps the parent buckets to the ids of their entries moved to the trash by deleting a bucket
+	Deleted map[string][]string `json:"deleted,omitempty"`
+}
@@ -5,10 +5,100 @@ import (
 	"time"
 )
 
+// Metadata holds the optional details of an entry
+type Metadata struct {
+	Tags     []string      `json:"tags,omitempty"`
+	Project  string        `json:"project,omitempty"`
+	Duration time.Duration `json:"duration,omitempty"`
+	Author   string        `json:"author,omitempty"`
+	// Extra holds any other detail worth keeping
+	Extra map[string]string `json:"extra,omitempty"`
+}
+
+// Entry is a logged entry as returned by the query functions
+type Entry struct {
+	// ID identifies the entry inside its bucket, it can be used to edit or delete it
+	ID        string
+	Timestamp time.Time
+	Content   string
+	Metadata
+}
+
+// Options tweak how InitWithOptions opens the store
+type Options struct {
+	// SkipMigrations opens the store without bringing it to the latest schema version, see Migrate
+	SkipMigrations bool
+	// Backend overrides the backend set in the config, e.g. memory for a throwaway store
+	Backend string
+	// ReadOnly opens the store for queries only, sharing it with the other readers instead of locking it
+	ReadOnly bool
+}
+
+// CheckProblem is an inconsistency found in the store by Check
+type CheckProblem struct {
+	// Bucket and Day locate the problem, Day being empty for the values found outside of the day buckets
+	Bucket string
+	Day    string
+	// Key is the id of the entry, or its raw key when it isn't a valid one. It's empty for the problems of a whole
+	// day bucket.
+	Key         string
+	Description string
+	// Repaired tells whether Check fixed the problem
+	Repaired bool
+}
+
+// TrashedEntry is an entry deleted into the trash, as returned by ListTrash
+type TrashedEntry struct {
+	Entry
+	// Bucket is the parent bucket the entry was deleted from, it's restored there under the same id
+	Bucket    string
+	DeletedAt time.Time
+}
+
+// Change is a change reversed by Undo
+type Change struct {
+	// Kind is one of ChangeAdd, ChangeEdit, ChangeDelete or ChangeDeleteBucket
+	Kind   string
+	Bucket string
+	// Entry is the entry the change was made to, as Undo left it, unset for a deleted bucket
+	Entry Entry
+	// Entries is the number of entries restored by undoing the deletion of a bucket
+	Entries int
+}
+
+// Revision is a version of an entry, as returned by GetEntryHistory
+type Revision struct {
+	Entry
+	// ReplacedAt is when an edit replaced this version, it's zero for the current one
+	ReplacedAt time.Time
+}
+
+// BucketStats describes a parent bucket, as returned by ListBuckets
+type BucketStats struct {
+	Name string
+	// Entries is the number of entries of the bucket and Days the number of days they were logged on
+	Entries int
+	Days    int
+	// First and Last are the times of the oldest and newest entries
+	First time.Time
+	Last  time.Time
+}
+
 // entry represents one entry in the db
 type entry struct {
+	ID        string
 	Timestamp time.Time
 	Content   []byte
+	Metadata
+}
+
+func (e entry) public() Entry {
+	return Entry{
+		ID:        e.ID,
+		Timestamp: e.Timestamp,
+		Content:   string(e.Content),
+		Metadata:  e.Metadata,
+	}
 }
 
 // aggregationFunction is a function used to aggregate entries retrieved from the store
@@ -19,7 +109,74 @@ type aggregationFunction func([]entry) (any, error)
 // entryStore is the db manager for entries
 type entryStore interface {
 	io.Closer
-	Put(string, entry) error
+	// Put stores a new entry, returning its id. The entries coming with an id, e.g. copied from another store, keep it
+	// unless it's taken.
+	Put(parentBucketName string, e entry) (string, error)
+	Get(parentBucketName string, id string) (entry, error)
+	// Update replaces the content and metadata of the entry identified by e.ID
+	Update(parentBucketName string, e entry) error
+	Delete(parentBucketName string, id string) error
 	GetRange(parentBucketName string, start, end time.Time) ([]entry, error)
 	GetRangeWithAggregation(parentBucketName string, start, end time.Time, agg aggregationFunction) (any, error)
+	// ForEach calls fn for every entry of every parent bucket, fn must not use the store
+	ForEach(fn func(parentBucketName string, e entry) error) error
+}
+
+// migrator is implemented by the stores with a versioned schema
+type migrator interface {
+	Migrate(dryRun bool) ([]MigrationResult, error)
+}
+
+// checker is implemented by the stores able to look for inconsistencies and repair them
+type checker interface {
+	Check(repair bool) ([]CheckProblem, error)
+}
+
+// trasher is implemented by the stores keeping the deleted entries in a trash inside the store, out of reach of the
+// queries
+type trasher interface {
+	// Trash moves the entry to the trash, noting when it was deleted
+	Trash(parentBucketName string, id string, deletedAt time.Time) error
+	// Untrash puts the trashed entry back in the parent bucket it was deleted from, under the same id
+	Untrash(parentBucketName string, id string) (entry, error)
+	// ListTrash returns the trashed entries, the most recently deleted first
+	ListTrash() ([]trashedEntry, error)
+	// EmptyTrash deletes the trashed entries for good, returning how many there were
+	EmptyTrash() (int, error)
+}
+
+// undoer is implemented by the stores keeping the last change made to them, so it can be undone
+type undoer interface {
+	// SetLastChange replaces the last change, nil forgetting it
+	SetLastChange(c *change) error
+	// LastChange returns the last change, nil when there's none
+	LastChange() (*change, error)
+}
+
+// historian is implemented by the stores keeping the earlier versions of the entries replaced by Update
+type historian interface {
+	// History returns the earlier versions of the entry, the oldest first
+	History(parentBucketName string, id string) ([]revision, error)
+}
+
+// historyImporter is implemented by the stores able to take in the history of an entry moved from another store
+type historyImporter interface {
+	// SetHistory replaces the earlier versions of the entry, the oldest first
+	SetHistory(parentBucketName string, id string, revisions []revision) error
+}
+
+// bucketManager is implemented by the stores able to list and rename their parent buckets. Parent buckets nest in
+// others through their path, e.g. work/payments.
+type bucketManager interface {
+	// Buckets returns the stats of the parent buckets holding entries, in name order
+	Buckets() ([]BucketStats, error)
+	// ChildBuckets returns the paths of the parent buckets nested in the given one, at any depth
+	ChildBuckets(parentBucketName string) ([]string, error)
+	// RenameBucket moves the entries of a parent bucket and of the ones nested in it to a new path, along with their
+	// trash and history, keeping their ids. The new bucket and the ones nested in it must not hold entries.
+	RenameBucket(oldName, newName string) error
+	// MergeBucket moves the entries of a parent bucket and of the ones nested in it to another path that may hold
+	// entries already, along with their trash and history, returning how many entries were moved. They keep their
+	// ids unless taken there.
+	MergeBucket(src, dst string) (int, error)
 }
@@ -0,0 +1,114 @@
+package godid
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Values are prefixed by a version byte, values without one are legacy values made only of the content
+const (
+	// valueVersionZoned marks the values that keep the zone the entry was logged in.
+	// Layout: version (1 byte) | utc offset in seconds (4 bytes) | zone name length (1 byte) | zone name | content
+	valueVersionZoned byte = 1
+	// valueVersionStructured marks the values holding the JSON encoded payload of the entry. JSON strings being UTF-8,
+	// the content was rewritten when it wasn't.
+	// Layout: version (1 byte) | payload
+	valueVersionStructured byte = 2
+	// valueVersionRawContent marks the values holding the JSON encoded payload of the entry without its content, the
+	// content following as it was logged.
+	// Layout: version (1 byte) | payload length (4 bytes) | payload | content
+	valueVersionRawContent byte = 3
+)
+
+// payload is the structured form of a stored value. Fields unknown to this version are ignored when decoding, so new
+// ones can be added freely.
+type payload struct {
+	Content string `json:"content,omitempty"`
+	Zone    string `json:"zone"`
+	Offset  int    `json:"offset"`
+	Metadata
+}
+
+// encodeValue builds the stored value of an entry, keeping the zone of its timestamp and the metadata alongside the
+// content
+func encodeValue(e entry) ([]byte, error) {
+	name, offset := e.Timestamp.Zone()
+	p, err := json.Marshal(payload{
+		Zone:     name,
+		Offset:   offset,
+		Metadata: e.Metadata,
+	})
+	if err != nil {
+		return nil, err
+	}
+	v := make([]byte, 5, 5+len(p)+len(e.Content))
+	v[0] = valueVersionRawContent
+	binary.BigEndian.PutUint32(v[1:5], uint32(len(p)))
+	v = append(v, p...)
+	return append(v, e.Content...), nil
+}
+
+// decodeValue returns the payload of any version of stored value along with the zone the entry was logged in.
+// The zone is nil for legacy values.
+func decodeValue(v []byte) (payload, *time.Location, error) {
+	if len(v) == 0 {
+		return payload{}, nil, nil
+	}
+	switch v[0] {
+	case valueVersionZoned:
+		if len(v) < 6 || len(v) < 6+int(v[5]) {
+			return payload{}, nil, errors.New("truncated entry value")
+		}
+		offset := int(int32(binary.BigEndian.Uint32(v[1:5])))
+		nameEnd := 6 + int(v[5])
+		return payload{Content: string(v[nameEnd:])}, time.FixedZone(string(v[6:nameEnd]), offset), nil
+	case valueVersionStructured:
+		var p payload
+		if err := json.Unmarshal(v[1:], &p); err != nil {
+			return payload{}, nil, fmt.Errorf("invalid entry payload: %w", err)
+		}
+		return p, time.FixedZone(p.Zone, p.Offset), nil
+	case valueVersionRawContent:
+		if len(v) < 5 || uint64(len(v)) < 5+uint64(binary.BigEndian.Uint32(v[1:5])) {
+			return payload{}, nil, errors.New("truncated entry value")
+		}
+		contentStart := 5 + int(binary.BigEndian.Uint32(v[1:5]))
+		var p payload
+		if err := json.Unmarshal(v[5:contentStart], &p); err != nil {
+			return payload{}, nil, fmt.Errorf("invalid entry payload: %w", err)
+		}
+		p.Content = string(v[contentStart:])
+		return p, time.FixedZone(p.Zone, p.Offset), nil
+	default:
+		return payload{Content: string(v)}, nil, nil
+	}
+}
+
+// decodeEntry rebuilds an entry out of its key and stored value, home being the zone of the legacy entries
+func decodeEntry(k, v []byte, home *time.Location) (entry, error) {
+	timestamp, err := decodeKey(k)
+	if err != nil {
+		return entry{}, err
+	}
+	p, zone, err := decodeValue(v)
+	if err != nil {
+		return entry{}, err
+	}
+	return entry{
+		ID:        formatID(k),
+		Timestamp: inZone(timestamp, zone, home),
+		Content:   []byte(p.Content),
+		Metadata:  p.Metadata,
+	}, nil
+}
+
+// inZone returns t in the zone the entry was logged in, falling back to the home location for legacy entries
+func inZone(t time.Time, zone, home *time.Location) time.Time {
+	if zone == nil {
+		return t.In(home)
+	}
+	return t.In(zone)
+}
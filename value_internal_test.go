@@ -0,0 +1,128 @@
+package godid
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeValue(t *testing.T) {
+	bucharest, err := time.LoadLocation("Europe/Bucharest")
+	require.NoError(t, err)
+	e := entry{
+		Timestamp: timeFromString(t, "2018-01-18T12:11:00Z").In(bucharest),
+		Content:   []byte("msg1"),
+		Metadata: Metadata{
+			Tags:     []string{"billing", "oncall"},
+			Project:  "payments",
+			Duration: 90 * time.Minute,
+			Author:   "alice",
+			Extra:    map[string]string{"ticket": "PAY-12"},
+		},
+	}
+	value, err := encodeValue(e)
+	require.NoError(t, err)
+	assert.Equal(t, valueVersionRawContent, value[0])
+	p, zone, err := decodeValue(value)
+	require.NoError(t, err)
+	assert.Equal(t, "msg1", p.Content)
+	assert.Equal(t, e.Metadata, p.Metadata)
+	require.NotNil(t, zone)
+	assert.Equal(t, "2018-01-18 14:11:00 +0200 EET", e.Timestamp.In(zone).String())
+
+	e.Content = []byte{'m', 's', 'g', 0xff, 0xfe}
+	value, err = encodeValue(e)
+	require.NoError(t, err)
+	p, _, err = decodeValue(value)
+	require.NoError(t, err)
+	assert.Equal(t, e.Content, []byte(p.Content), "the content must be kept byte for byte")
+}
+
+func TestDecodeValue(t *testing.T) {
+	testCases := []struct {
+		name             string
+		value            []byte
+		shouldError      bool
+		expectedContent  string
+		expectedMetadata Metadata
+		expectedZone     string
+	}{
+		{
+			name:            "legacy",
+			value:           []byte("msg1"),
+			expectedContent: "msg1",
+		},
+		{
+			name:  "legacy empty",
+			value: []byte{},
+		},
+		{
+			name:            "zoned",
+			value:           append([]byte{valueVersionZoned, 0, 0, 0x0e, 0x10, 3}, "CETmsg1"...),
+			expectedContent: "msg1",
+			expectedZone:    "CET",
+		},
+		{
+			name:        "truncated header",
+			value:       []byte{valueVersionZoned, 0, 0},
+			shouldError: true,
+		},
+		{
+			name:        "truncated zone",
+			value:       append([]byte{valueVersionZoned, 0, 0, 0x0e, 0x10, 10}, "CET"...),
+			shouldError: true,
+		},
+		{
+			name:             "structured",
+			value:            append([]byte{valueVersionStructured}, `{"content":"msg1","zone":"CET","offset":3600,"tags":["a"],"project":"p"}`...),
+			expectedContent:  "msg1",
+			expectedMetadata: Metadata{Tags: []string{"a"}, Project: "p"},
+			expectedZone:     "CET",
+		},
+		{
+			name:            "structured with unknown fields",
+			value:           append([]byte{valueVersionStructured}, `{"content":"msg1","zone":"CET","offset":3600,"mood":"great"}`...),
+			expectedContent: "msg1",
+			expectedZone:    "CET",
+		},
+		{
+			name:        "invalid payload",
+			value:       append([]byte{valueVersionStructured}, `{"content":`...),
+			shouldError: true,
+		},
+		{
+			name:             "raw content",
+			value:            append([]byte{valueVersionRawContent, 0, 0, 0, 41}, `{"zone":"CET","offset":3600,"tags":["a"]}msg1`...),
+			expectedContent:  "msg1",
+			expectedMetadata: Metadata{Tags: []string{"a"}},
+			expectedZone:     "CET",
+		},
+		{
+			name:        "truncated raw content payload",
+			value:       append([]byte{valueVersionRawContent, 0, 0, 1, 0}, `{"zone":"CET"}`...),
+			shouldError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, zone, err := decodeValue(tc.value)
+			if tc.shouldError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedContent, p.Content)
+			assert.Equal(t, tc.expectedMetadata, p.Metadata)
+			if tc.expectedZone == "" {
+				assert.Nil(t, zone)
+			} else {
+				name, offset := time.Now().In(zone).Zone()
+				assert.Equal(t, tc.expectedZone, name)
+				assert.Equal(t, 3600, offset)
+			}
+		})
+	}
+}